@@ -2,8 +2,8 @@
 // X cursor in a particular window. To see the new cursor, move your cursor
 // into the window created by this program.
 // Note that this only shows how to use one of the pre-defined cursors built
-// into X using the "cursor" font. Creating your own cursor with your own
-// image is a bit more complex, and probably not an instructive example.
+// into X using the "cursor" font. To use a cursor from the user's cursor
+// theme instead, see xcursor.LoadCursor.
 //
 // While this example shows how to set a cursor in an entire window, the cursor
 // value returned from xcursor.CreateCursor[Extra] can be used in pointer
@@ -0,0 +1,72 @@
+// Example present-animation shows how to use a present.Presenter to draw a
+// smooth animation: a bar sweeping across a window, moving by a fixed number
+// of pixels each vertical refresh.
+//
+// Each frame is drawn by a function that the Presenter calls once the previous
+// frame has been shown. If the X server has the Present extension, frames
+// are shown in sync with the monitor's refresh. Otherwise, the Presenter
+// copies frames to the window and uses a timer instead.
+package main
+
+import (
+	"log"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/present"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xgraphics"
+	"github.com/BurntSushi/xgbutil/xwindow"
+)
+
+// The size of the window and the width of the bar.
+const (
+	width, height = 500, 200
+	barWidth      = 40
+)
+
+func main() {
+	X, err := xgbutil.NewConn()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	win, err := xwindow.Generate(X)
+	if err != nil {
+		log.Fatal(err)
+	}
+	win.Create(X.RootWin(), 0, 0, width, height, 0)
+	win.Map()
+
+	p, err := present.NewPresenter(X, win.Id, width, height)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !p.PresentAvailable() {
+		log.Println("The Present extension is not available, so frames " +
+			"will be copied to the window with a timer.")
+	}
+
+	// Quit when the window is closed. The Presenter must be destroyed
+	// before the window, so that its pixmaps are freed.
+	win.WMGracefulClose(func(w *xwindow.Window) {
+		p.Destroy()
+		w.Destroy()
+		xevent.Quit(w.X)
+	})
+
+	// The position of the bar is computed from the MSC (which counts
+	// vertical refreshes) instead of a frame counter. That way, the bar
+	// moves at the same speed even if some frames take too long to draw
+	// and the Presenter has to skip a refresh.
+	p.Start(func(p *present.Presenter, frame *xgraphics.Image, msc uint64) {
+		barX := int(msc*4) % (width + barWidth)
+		frame.For(func(x, y int) xgraphics.BGRA {
+			if x >= barX-barWidth && x < barX {
+				return xgraphics.BGRA{B: 0xc0, G: 0x60, R: 0x20, A: 0xff}
+			}
+			return xgraphics.BGRA{B: 0xff, G: 0xff, R: 0xff, A: 0xff}
+		})
+	})
+
+	xevent.Main(X)
+}
@@ -0,0 +1,69 @@
+// Example translucent-window shows how to create a window with a 32 bit ARGB
+// visual and paint an xgraphics.Image with an alpha channel to it. When a
+// compositing manager is running, the window is translucent: it fades from
+// mostly transparent at the top to mostly opaque at the bottom.
+//
+// If the X server has no ARGB visual, the example falls back to a normal
+// window and blends the image against a solid background instead.
+package main
+
+import (
+	"image"
+	"image/color"
+	"log"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xgraphics"
+	"github.com/BurntSushi/xgbutil/xwindow"
+)
+
+func main() {
+	X, err := xgbutil.NewConn()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	width, height := 400, 200
+
+	// Create an image that fades from transparent to opaque. Note that the
+	// pixels of an xgraphics.Image are *not* premultiplied. Premultiplying
+	// is done for you when the image is drawn.
+	ximg := xgraphics.New(X, image.Rect(0, 0, width, height))
+	ximg.ForExp(func(x, y int) (r, g, b, a uint8) {
+		return 0x20, 0x60, 0xc0, uint8(0x30 + (0xc0*y)/height)
+	})
+
+	win, err := xwindow.Generate(X)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// CreateARGB fails if the X server doesn't have an ARGB visual.
+	err = win.CreateARGB(X.RootWin(), 0, 0, width, height, 0)
+	if err == nil {
+		// The image must know that it's being painted to an ARGB window
+		// before its pixmap is created in XSurfaceSet.
+		ximg.ARGB = true
+	} else {
+		log.Printf("Could not create an ARGB window, so the window will "+
+			"be opaque: %s", err)
+
+		win.Create(X.RootWin(), 0, 0, width, height, 0)
+		xgraphics.BlendBgColor(ximg, color.RGBA{0xff, 0xff, 0xff, 0xff})
+	}
+
+	// Quit when the window is closed.
+	win.WMGracefulClose(func(w *xwindow.Window) {
+		w.Destroy()
+		xevent.Quit(w.X)
+	})
+
+	// This is the same as painting to any other window.
+	ximg.XSurfaceSet(win.Id)
+	ximg.XDraw()
+	ximg.XPaint(win.Id)
+
+	win.Map()
+	xevent.Main(X)
+}
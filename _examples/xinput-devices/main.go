@@ -0,0 +1,80 @@
+// Example xinput-devices shows how to list input devices and their
+// properties with the xinput package, and how to be notified when devices
+// are plugged in or removed.
+package main
+
+import (
+	"log"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xinput"
+)
+
+// uses maps device uses to something readable.
+var uses = map[int]string{
+	xinput.MasterPointer:  "master pointer",
+	xinput.MasterKeyboard: "master keyboard",
+	xinput.SlavePointer:   "slave pointer",
+	xinput.SlaveKeyboard:  "slave keyboard",
+	xinput.FloatingSlave:  "floating slave",
+}
+
+func main() {
+	X, err := xgbutil.NewConn()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := xinput.Init(X); err != nil {
+		log.Fatal(err)
+	}
+
+	// Print every pointer and keyboard along with its properties.
+	devices, err := xinput.Devices(X)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, d := range devices {
+		log.Printf("%d: %s (%s)", d.Id, d.Name, uses[d.Use])
+		if len(d.Scrolls) > 0 {
+			log.Printf("\tsupports smooth scrolling")
+		}
+		if d.MaxTouches > 0 {
+			log.Printf("\tsupports %d touches", d.MaxTouches)
+		}
+
+		props, err := xinput.ListProperties(X, d.Id)
+		if err != nil {
+			log.Printf("\tcould not list properties: %s", err)
+			continue
+		}
+		for _, prop := range props {
+			log.Printf("\tproperty: %s", prop)
+		}
+	}
+
+	// Now report when devices are added or removed. Hierarchy events are
+	// selected on the root window for all devices, but since they aren't
+	// reported relative to a window, the callback is attached to
+	// xevent.NoWindow.
+	xinput.HierarchyChangedFun(
+		func(X *xgbutil.XUtil, ev xinput.HierarchyChangedEvent) {
+			for _, info := range ev.Changed(xinput.SlaveAdded) {
+				log.Printf("Device %d (%s) was added.",
+					info.DeviceId, uses[info.Use])
+			}
+			for _, info := range ev.Changed(xinput.SlaveRemoved) {
+				log.Printf("Device %d was removed.", info.DeviceId)
+			}
+		}).Connect(X, xevent.NoWindow)
+
+	err = xinput.SelectEvents(X, X.RootWin(), xinput.AllDevices,
+		xinput.HierarchyChanged)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Waiting for devices to be plugged in or removed...")
+	xevent.Main(X)
+}
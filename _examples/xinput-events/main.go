@@ -0,0 +1,81 @@
+// Example xinput-events shows how to use the xinput package to receive
+// XInput 2 events. It creates a window and prints smooth scrolling valuators
+// and touch events that happen inside it, along with the device that
+// generated them. It also prints raw (unaccelerated) motion of every pointer,
+// no matter where the pointer is.
+package main
+
+import (
+	"log"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xinput"
+	"github.com/BurntSushi/xgbutil/xwindow"
+)
+
+func main() {
+	X, err := xgbutil.NewConn()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Init must be called before anything else in the xinput package.
+	// It will fail if the X server doesn't support XInput 2.
+	if err := xinput.Init(X); err != nil {
+		log.Fatal(err)
+	}
+
+	win, err := xwindow.Generate(X)
+	if err != nil {
+		log.Fatal(err)
+	}
+	win.Create(X.RootWin(), 0, 0, 400, 400, 0)
+	win.Map()
+
+	// Motion events include valuators, which is where smooth scrolling
+	// shows up. Each valuator is an axis of the device.
+	xinput.MotionFun(
+		func(X *xgbutil.XUtil, ev xinput.MotionEvent) {
+			log.Printf("Motion from device %d at (%0.2f, %0.2f): %v",
+				ev.SourceId, ev.EventX, ev.EventY, ev.Valuators)
+		}).Connect(X, win.Id)
+
+	// Touch events have the touch id in Detail.
+	xinput.TouchBeginFun(
+		func(X *xgbutil.XUtil, ev xinput.TouchBeginEvent) {
+			log.Printf("Touch %d began at (%0.2f, %0.2f) on device %d",
+				ev.Detail, ev.EventX, ev.EventY, ev.SourceId)
+		}).Connect(X, win.Id)
+	xinput.TouchEndFun(
+		func(X *xgbutil.XUtil, ev xinput.TouchEndEvent) {
+			log.Printf("Touch %d ended at (%0.2f, %0.2f) on device %d",
+				ev.Detail, ev.EventX, ev.EventY, ev.SourceId)
+		}).Connect(X, win.Id)
+
+	// Raw events aren't reported relative to any window, so their callbacks
+	// are attached to xevent.NoWindow.
+	xinput.RawMotionFun(
+		func(X *xgbutil.XUtil, ev xinput.RawMotionEvent) {
+			log.Printf("Raw motion from device %d: %v",
+				ev.SourceId, ev.RawValuators)
+		}).Connect(X, xevent.NoWindow)
+
+	// Now tell X which XInput 2 events we want. Touch events must be
+	// selected all together.
+	err = xinput.SelectEvents(X, win.Id, xinput.AllMasterDevices,
+		xinput.Motion,
+		xinput.TouchBegin, xinput.TouchUpdate, xinput.TouchEnd)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Raw events can only be selected on the root window.
+	err = xinput.SelectEvents(X, X.RootWin(), xinput.AllMasterDevices,
+		xinput.RawMotion)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	xevent.Main(X)
+}
@@ -0,0 +1,391 @@
+package xgbutil
+
+/*
+conn.go contains the connection to X made by NewConn and NewConnDisplay,
+which reads events sent through the X Generic Event Extension completely.
+
+XGB reads exactly 32 bytes for every event it receives, but generic events
+(like those of XInput 2 and Present) carry 4 * their length field more bytes.
+If XGB reads only the first 32 bytes of such an event, the rest is left on
+the socket and everything read after it is garbage.
+
+So NewConnDisplay connects to X itself, and hands XGB a net.Conn (a
+genericConn) that splits what the X server sends into replies, errors and
+events, and takes the extra bytes out of long generic events before XGB sees
+them. The complete event is kept aside until the event constructor of XGB
+asks for it with GenericEventBytes, which happens right after XGB reads the
+first 32 bytes.
+
+The code for dialing X and reading the X authority file is adapted from
+conn.go and auth.go in XGB, which only work with connections made by XGB.
+Like XGB, only MIT-MAGIC-COOKIE-1 authority entries are supported. When the
+entry for the display is anything else, the connection is left to XGB, which
+fails the same way it always has (and generic events aren't read
+completely).
+*/
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/xproto"
+)
+
+// genericTokenBit is set in the tokens that replace the length of long
+// generic events. No real generic event is long enough to have it set.
+const genericTokenBit = 1 << 31
+
+var (
+	// genericEvents holds the most recent long generic events, until XGB
+	// decodes them. An event is found by its token, modulo the size of the
+	// ring. XGB decodes each event right after reading it, so only one event
+	// per connection is ever waiting.
+	genericEvents    [64]genericEvent
+	genericEventsTok uint32
+	genericEventsLck = &sync.Mutex{}
+
+	// xgbLoggerLck is held while the output of the XGB logger is filtered.
+	xgbLoggerLck = &sync.Mutex{}
+)
+
+// genericEvent is a long generic event waiting to be decoded.
+type genericEvent struct {
+	token uint32
+	data  []byte
+}
+
+// GenericEventBytes returns the complete wire representation of the generic
+// event whose first 32 bytes are 'buf', as given to an event constructor by
+// XGB. If the event isn't longer than 32 bytes or wasn't read by a connection
+// made with NewConn or NewConnDisplay, 'buf' is returned.
+// It is exported for use in the xevent package. It should not be used.
+func GenericEventBytes(buf []byte) []byte {
+	token := xgb.Get32(buf[4:])
+	if token&genericTokenBit == 0 {
+		return buf
+	}
+
+	genericEventsLck.Lock()
+	defer genericEventsLck.Unlock()
+
+	ev := &genericEvents[int(token%uint32(len(genericEvents)))]
+	if ev.token != token || ev.data == nil {
+		return buf
+	}
+	data := ev.data
+	*ev = genericEvent{}
+	return data
+}
+
+// putGenericEvent keeps a long generic event aside and returns the token
+// that replaces its length.
+func putGenericEvent(data []byte) uint32 {
+	genericEventsLck.Lock()
+	defer genericEventsLck.Unlock()
+
+	genericEventsTok = (genericEventsTok + 1) &^ genericTokenBit
+	token := genericEventsTok | genericTokenBit
+	genericEvents[int(token%uint32(len(genericEvents)))] = genericEvent{
+		token: token,
+		data:  data,
+	}
+	return token
+}
+
+// genericConn is the net.Conn given to XGB by NewConnDisplay.
+type genericConn struct {
+	net.Conn
+
+	// setup is the connection setup request, which is sent instead of the
+	// one written by XGB. (XGB doesn't know which display it is connected
+	// to, so it can't find the right authority entry.)
+	setup []byte
+
+	// setupDone is true once the reply to the setup request has been read.
+	setupDone bool
+
+	// pending is what's left of the last message read, to be read by XGB.
+	pending []byte
+}
+
+// Write writes to the connection, except that the first write (the
+// connection setup request) is replaced by conn.setup.
+func (conn *genericConn) Write(p []byte) (int, error) {
+	if conn.setup != nil {
+		setup := conn.setup
+		conn.setup = nil
+		if _, err := conn.Conn.Write(setup); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	return conn.Conn.Write(p)
+}
+
+// Read reads from the connection one message at a time, with the extra
+// bytes of long generic events taken out.
+func (conn *genericConn) Read(p []byte) (int, error) {
+	if len(conn.pending) == 0 {
+		msg, err := conn.next()
+		if err != nil {
+			return 0, err
+		}
+		conn.pending = msg
+	}
+	n := copy(p, conn.pending)
+	conn.pending = conn.pending[n:]
+	return n, nil
+}
+
+// next reads the next complete message sent by the X server. The first
+// message is the reply to the setup request, and the others are replies,
+// errors and events. Long generic events are kept aside, and only their
+// first 32 bytes are returned with a token in place of their length.
+func (conn *genericConn) next() ([]byte, error) {
+	if !conn.setupDone {
+		conn.setupDone = true
+		head := make([]byte, 8)
+		if _, err := io.ReadFull(conn.Conn, head); err != nil {
+			return nil, err
+		}
+		return conn.readMore(head, 4*int(xgb.Get16(head[6:])))
+	}
+
+	buf := make([]byte, 32)
+	if _, err := io.ReadFull(conn.Conn, buf); err != nil {
+		return nil, err
+	}
+	switch {
+	case buf[0] == 1: // a reply
+		return conn.readMore(buf, 4*int(xgb.Get32(buf[4:])))
+	case buf[0]&127 == xproto.GeGeneric:
+		length := xgb.Get32(buf[4:])
+		if length == 0 {
+			return buf, nil
+		}
+		data, err := conn.readMore(buf, 4*int(length))
+		if err != nil {
+			return nil, err
+		}
+
+		head := make([]byte, 32)
+		copy(head, data)
+		xgb.Put32(head[4:], putGenericEvent(data))
+		return head, nil
+	}
+	return buf, nil
+}
+
+// readMore reads 'extra' more bytes of the message that starts with 'head'
+// and returns the whole message.
+func (conn *genericConn) readMore(head []byte, extra int) ([]byte, error) {
+	if extra == 0 {
+		return head, nil
+	}
+	msg := make([]byte, len(head)+extra)
+	copy(msg, head)
+	if _, err := io.ReadFull(conn.Conn, msg[len(head):]); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// newXgbConn connects to the X server given in the 'display' string (like
+// xgb.NewConnDisplay), through a genericConn. It returns false if the
+// connection was made by xgb.NewConnDisplay instead, since the authority
+// entry for the display isn't one that genericConn can send.
+func newXgbConn(display string) (*xgb.Conn, bool, error) {
+	netConn, host, disp, screen, err := dial(display)
+	if err != nil {
+		return nil, false, err
+	}
+
+	authName, authData, err := readAuthority(host, disp)
+	if err != nil {
+		authName, authData = "", []byte{}
+	} else if authName != "MIT-MAGIC-COOKIE-1" || len(authData) != 16 {
+		netConn.Close()
+		c, err := xgb.NewConnDisplay(display)
+		return c, false, err
+	}
+
+	setup := make([]byte, 12+xgb.Pad(len(authName))+xgb.Pad(len(authData)))
+	setup[0] = 0x6c
+	xgb.Put16(setup[2:], 11)
+	xgb.Put16(setup[6:], uint16(len(authName)))
+	xgb.Put16(setup[8:], uint16(len(authData)))
+	copy(setup[12:], []byte(authName))
+	copy(setup[12+xgb.Pad(len(authName)):], authData)
+
+	// XGB looks for an authority entry itself, for a display it doesn't
+	// know, and complains when it can't find one. Its setup request is
+	// replaced by ours anyway, so those complaints are filtered out while it
+	// does. Everything else logged in the meantime (like messages about
+	// other connections) still gets through.
+	xgbLoggerLck.Lock()
+	out := xgb.Logger.Writer()
+	filter := &authLogFilter{out}
+	xgb.Logger.SetOutput(filter)
+	c, err := xgb.NewConnNet(&genericConn{Conn: netConn, setup: setup})
+	if xgb.Logger.Writer() == io.Writer(filter) {
+		xgb.Logger.SetOutput(out)
+	}
+	xgbLoggerLck.Unlock()
+
+	if err != nil {
+		netConn.Close()
+		return nil, false, err
+	}
+	c.DisplayNumber, _ = strconv.Atoi(disp)
+	c.DefaultScreen = screen
+	return c, true, nil
+}
+
+// authLogFilter is the output of the XGB logger while newXgbConn hands a
+// connection to XGB. It drops the messages XGB logs when it can't find an
+// authority entry, and passes everything else on to 'out'.
+type authLogFilter struct {
+	out io.Writer
+}
+
+func (f *authLogFilter) Write(p []byte) (int, error) {
+	if bytes.Contains(p, []byte("authority info")) {
+		return len(p), nil
+	}
+	return f.out.Write(p)
+}
+
+// dial connects to the X server given in the 'display' string. If 'display'
+// is empty, the DISPLAY environment variable is used. The host name, the
+// display number and the screen number are returned with the connection.
+func dial(display string) (netConn net.Conn, host, disp string,
+	screen int, err error) {
+
+	if len(display) == 0 {
+		display = os.Getenv("DISPLAY")
+	}
+	display0 := display
+	if len(display) == 0 {
+		return nil, "", "", 0, errors.New("empty display string")
+	}
+	badDisplay := errors.New("bad display string: " + display0)
+
+	colonIdx := strings.LastIndex(display, ":")
+	if colonIdx < 0 {
+		return nil, "", "", 0, badDisplay
+	}
+
+	var protocol, socket string
+	if display[0] == '/' {
+		socket = display[0:colonIdx]
+	} else {
+		slashIdx := strings.LastIndex(display, "/")
+		if slashIdx >= 0 {
+			protocol = display[0:slashIdx]
+			host = display[slashIdx+1 : colonIdx]
+		} else {
+			host = display[0:colonIdx]
+		}
+	}
+
+	display = display[colonIdx+1:]
+	disp = display
+	if dotIdx := strings.LastIndex(display, "."); dotIdx >= 0 {
+		disp = display[0:dotIdx]
+		screen, err = strconv.Atoi(display[dotIdx+1:])
+		if err != nil {
+			return nil, "", "", 0, badDisplay
+		}
+	}
+	if n, err := strconv.Atoi(disp); err != nil || n < 0 {
+		return nil, "", "", 0, badDisplay
+	}
+
+	switch {
+	case len(socket) != 0:
+		netConn, err = net.Dial("unix", socket+":"+disp)
+	case len(host) != 0 && host != "unix":
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		n, _ := strconv.Atoi(disp)
+		netConn, err = net.Dial(protocol, host+":"+strconv.Itoa(6000+n))
+	default:
+		host = ""
+		netConn, err = net.Dial("unix", "/tmp/.X11-unix/X"+disp)
+	}
+	if err != nil {
+		return nil, "", "", 0, errors.New("cannot connect to " + display0 +
+			": " + err.Error())
+	}
+	return netConn, host, disp, screen, nil
+}
+
+// readAuthority reads the X authority file for the display given. If
+// 'hostname' is empty or "localhost", the system's host name is used instead.
+func readAuthority(hostname, display string) (string, []byte, error) {
+	// As per /usr/include/X11/Xauth.h.
+	const familyLocal = 256
+	const familyWild = 65535
+
+	if len(hostname) == 0 || hostname == "localhost" {
+		var err error
+		if hostname, err = os.Hostname(); err != nil {
+			return "", nil, err
+		}
+	}
+
+	fname := os.Getenv("XAUTHORITY")
+	if len(fname) == 0 {
+		home := os.Getenv("HOME")
+		if len(home) == 0 {
+			return "", nil, errors.New(
+				"Xauthority not found: $XAUTHORITY, $HOME not set")
+		}
+		fname = home + "/.Xauthority"
+	}
+
+	r, err := os.Open(fname)
+	if err != nil {
+		return "", nil, err
+	}
+	defer r.Close()
+
+	field := func() ([]byte, error) {
+		var n uint16
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return nil, err
+		}
+		b := make([]byte, n)
+		_, err := io.ReadFull(r, b)
+		return b, err
+	}
+	for {
+		var family uint16
+		if err := binary.Read(r, binary.BigEndian, &family); err != nil {
+			return "", nil, err
+		}
+
+		var fields [4][]byte
+		for i := range fields {
+			if fields[i], err = field(); err != nil {
+				return "", nil, err
+			}
+		}
+		addr, disp := string(fields[0]), string(fields[1])
+
+		addrmatch := family == familyWild ||
+			(family == familyLocal && addr == hostname)
+		if addrmatch && (disp == "" || disp == display) {
+			return string(fields[2]), fields[3], nil
+		}
+	}
+}
@@ -25,7 +25,7 @@ Dependencies
 
 XGB is the main dependency, and is required for all packages inside xgbutil.
 
-graphics-go and freetype-go are also required if using the xgraphics package.
+freetype-go is also required if using the xgraphics package.
 
 Quick Example
 
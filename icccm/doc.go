@@ -49,6 +49,17 @@ often looks like this:
 Something similar can be said for the _NET_WM_ICON and the IconPixmap field
 in WM_HINTS.
 
+Size hints and gravity
+
+Window managers are supposed to honor the size hints in WM_NORMAL_HINTS when
+they resize a window. ConstrainSize returns the allowed size nearest to a
+proposed size:
+
+	width, height = normalHints.ConstrainSize(width, height)
+
+The Gravity functions (GravityFrame, GravityResize, etc.) position windows
+according to their window gravity, like when they're put in a frame.
+
 Naming scheme
 
 The naming scheme is precisely the same as the one found in the ewmh package.
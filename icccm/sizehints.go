@@ -0,0 +1,254 @@
+package icccm
+
+/*
+icccm/sizehints.go contains functions for applying the size hints in
+WM_NORMAL_HINTS, and for positioning windows according to their window
+gravity. These are mostly useful to window managers, which are supposed to
+honor both when a window is mapped, moved or resized.
+
+See section 4.1.2.3 of the ICCCM for the details.
+*/
+
+import (
+	"math"
+
+	"github.com/BurntSushi/xgb/xproto"
+)
+
+// ConstrainSize returns the allowed size nearest to width x height, according
+// to the size hints. The size returned is at least the minimum size and at
+// most the maximum size, is the base size plus a multiple of the resize
+// increments, and has an aspect ratio between the minimum and maximum aspect
+// ratios.
+//
+// As the ICCCM specifies, the minimum size is used as the base size (and the
+// base size as the minimum size) when only one of them is given, and the base
+// size (but not the minimum size) is subtracted from the size before its
+// aspect ratio is checked. Only the hints whose flags are set are used.
+//
+// Hints can contradict each other (like an aspect ratio that no size between
+// the minimum and maximum sizes has). When they do, the minimum and maximum
+// sizes win over the aspect ratio, and the minimum size wins over the
+// maximum size.
+//
+// A nil *NormalHints allows every size.
+func (nh *NormalHints) ConstrainSize(width, height int) (int, int) {
+	if nh == nil {
+		return imax(width, 1), imax(height, 1)
+	}
+
+	minW, minH, baseW, baseH := 1, 1, 0, 0
+	switch {
+	case nh.Flags&SizeHintPMinSize > 0:
+		minW, minH = int(nh.MinWidth), int(nh.MinHeight)
+	case nh.Flags&SizeHintPBaseSize > 0:
+		minW, minH = int(nh.BaseWidth), int(nh.BaseHeight)
+	}
+	switch {
+	case nh.Flags&SizeHintPBaseSize > 0:
+		baseW, baseH = int(nh.BaseWidth), int(nh.BaseHeight)
+	case nh.Flags&SizeHintPMinSize > 0:
+		baseW, baseH = int(nh.MinWidth), int(nh.MinHeight)
+	}
+	minW, minH = imax(minW, 1), imax(minH, 1)
+
+	maxW, maxH := math.MaxInt32, math.MaxInt32
+	if nh.Flags&SizeHintPMaxSize > 0 {
+		if nh.MaxWidth > 0 {
+			maxW = int(nh.MaxWidth)
+		}
+		if nh.MaxHeight > 0 {
+			maxH = int(nh.MaxHeight)
+		}
+	}
+
+	incW, incH := 1, 1
+	if nh.Flags&SizeHintPResizeInc > 0 {
+		if nh.WidthInc > 0 {
+			incW = int(nh.WidthInc)
+		}
+		if nh.HeightInc > 0 {
+			incH = int(nh.HeightInc)
+		}
+	}
+
+	width = snapSize(width, minW, maxW, baseW, incW)
+	height = snapSize(height, minH, maxH, baseH, incH)
+
+	// Fix the aspect ratio by growing or shrinking one side by a multiple of
+	// its increment, without going past the minimum or maximum size.
+	// (This is what twm, and most window managers since, do.)
+	if nh.Flags&SizeHintPAspect > 0 {
+		aspW, aspH := 0, 0
+		if nh.Flags&SizeHintPBaseSize > 0 {
+			aspW, aspH = int(nh.BaseWidth), int(nh.BaseHeight)
+		}
+
+		// Too tall: width / height < min_aspect.
+		num, den := int(nh.MinAspectNum), int(nh.MinAspectDen)
+		if w, h := width-aspW, height-aspH; num > 0 && den > 0 &&
+			w > 0 && h > 0 && num*h > den*w {
+
+			delta := roundUp(ceilDiv(num*h, den)-w, incW)
+			if width+delta <= maxW {
+				width += delta
+			} else {
+				delta = roundUp(h-w*den/num, incH)
+				if height-delta >= minH {
+					height -= delta
+				}
+			}
+		}
+
+		// Too wide: width / height > max_aspect.
+		num, den = int(nh.MaxAspectNum), int(nh.MaxAspectDen)
+		if w, h := width-aspW, height-aspH; num > 0 && den > 0 &&
+			w > 0 && h > 0 && w*den > num*h {
+
+			delta := roundUp(ceilDiv(w*den, num)-h, incH)
+			if height+delta <= maxH {
+				height += delta
+			} else {
+				delta = roundUp(w-num*h/den, incW)
+				if width-delta >= minW {
+					width -= delta
+				}
+			}
+		}
+	}
+	return imax(width, 1), imax(height, 1)
+}
+
+// snapSize returns the size nearest to 'size' that is 'base' plus a multiple
+// of 'inc', and is between 'min' and 'max'. (If there isn't one, the
+// nearest size to 'min' or 'max' is used. 'min' wins if it is larger than
+// 'max'.)
+func snapSize(size, min, max, base, inc int) int {
+	if size > max {
+		size = max
+	}
+	if size < min {
+		size = min
+	}
+	if inc <= 1 || size < base {
+		return size
+	}
+
+	size = base + (size-base+inc/2)/inc*inc
+	if size > max {
+		size -= inc
+	}
+	if size < min {
+		size += inc
+	}
+	return size
+}
+
+// Gravity returns the window gravity in the size hints, or NorthWest if it
+// isn't set. (Which is the default according to the ICCCM.)
+func (nh *NormalHints) Gravity() uint {
+	if nh == nil || nh.Flags&SizeHintPWinGravity == 0 || nh.WinGravity == 0 {
+		return xproto.GravityNorthWest
+	}
+	return nh.WinGravity
+}
+
+// GravityPoint returns the reference point of a rectangle for the gravity
+// given. For example, it is the top left corner for NorthWest, the middle of
+// the top side for North and the center for Center. Static (whose reference
+// point is the top left corner of a window inside its border) has the same
+// reference point as NorthWest, and should be handled by the caller.
+func GravityPoint(gravity uint, x, y, width, height int) (int, int) {
+	switch gravity {
+	case xproto.GravityNorth, xproto.GravityCenter, xproto.GravitySouth:
+		x += width / 2
+	case xproto.GravityNorthEast, xproto.GravityEast,
+		xproto.GravitySouthEast:
+
+		x += width
+	}
+	switch gravity {
+	case xproto.GravityWest, xproto.GravityCenter, xproto.GravityEast:
+		y += height / 2
+	case xproto.GravitySouthWest, xproto.GravitySouth,
+		xproto.GravitySouthEast:
+
+		y += height
+	}
+	return x, y
+}
+
+// GravityPlace is the inverse of GravityPoint: it returns the position of the
+// top left corner of a width x height rectangle whose reference point (for
+// the gravity given) is at (refx, refy).
+func GravityPlace(gravity uint, refx, refy, width, height int) (int, int) {
+	dx, dy := GravityPoint(gravity, 0, 0, width, height)
+	return refx - dx, refy - dy
+}
+
+// GravityResize returns the new position of a window that is resized from
+// width x height to newWidth x newHeight, such that its reference point for
+// the gravity given doesn't move. For example, with SouthEast gravity, the
+// bottom right corner of the window stays in place. Sizes should include the
+// window's border.
+func GravityResize(gravity uint, x, y, width, height, newWidth,
+	newHeight int) (int, int) {
+
+	refx, refy := GravityPoint(gravity, x, y, width, height)
+	return GravityPlace(gravity, refx, refy, newWidth, newHeight)
+}
+
+// GravityFrame returns the position of the frame a window manager puts a
+// window in, when the window asked to be at (x, y) with its window gravity.
+// 'width' and 'height' are the size of the window (without its border),
+// 'borderWidth' is the width of its border, and 'left', 'right', 'top' and
+// 'bottom' are the sizes of the frame around the window (like in
+// _NET_FRAME_EXTENTS). The window's border is replaced by the frame.
+//
+// As the ICCCM specifies, the reference point of the window (including its
+// border) and of the frame are the same. With Static gravity, the window
+// itself doesn't move instead.
+func GravityFrame(gravity uint, x, y, width, height, borderWidth,
+	left, right, top, bottom int) (int, int) {
+
+	if gravity == xproto.GravityStatic {
+		return x + borderWidth - left, y + borderWidth - top
+	}
+	bw2 := 2 * borderWidth
+	refx, refy := GravityPoint(gravity, x, y, width+bw2, height+bw2)
+	return GravityPlace(gravity, refx, refy,
+		width+left+right, height+top+bottom)
+}
+
+// GravityClient is the inverse of GravityFrame: it returns the position of a
+// window (with its border) that is taken out of a frame at (x, y), like
+// when the window manager exits. This is where the window would have asked to
+// be, if it wanted to be where it is.
+func GravityClient(gravity uint, x, y, width, height, borderWidth,
+	left, right, top, bottom int) (int, int) {
+
+	if gravity == xproto.GravityStatic {
+		return x + left - borderWidth, y + top - borderWidth
+	}
+	bw2 := 2 * borderWidth
+	refx, refy := GravityPoint(gravity, x, y,
+		width+left+right, height+top+bottom)
+	return GravityPlace(gravity, refx, refy, width+bw2, height+bw2)
+}
+
+// ceilDiv returns a / b rounded up, for positive 'a' and 'b'.
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
+
+// roundUp returns 'n' rounded up to a multiple of 'inc'.
+func roundUp(n, inc int) int {
+	return ceilDiv(n, inc) * inc
+}
+
+func imax(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,159 @@
+package icccm
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/xgb/xproto"
+)
+
+// TestConstrainSize checks the sizes allowed by size hints.
+func TestConstrainSize(t *testing.T) {
+	tests := []struct {
+		name                  string
+		hints                 *NormalHints
+		width, height         int
+		wantWidth, wantHeight int
+	}{
+		{"nil hints", nil, 100, 50, 100, 50},
+		{"nil hints, empty size", nil, 0, -5, 1, 1},
+		{"no flags", &NormalHints{MinWidth: 500, MaxHeight: 10},
+			100, 50, 100, 50},
+
+		// Minimum and maximum sizes.
+		{"min size", &NormalHints{Flags: SizeHintPMinSize,
+			MinWidth: 20, MinHeight: 30}, 10, 40, 20, 40},
+		{"max size", &NormalHints{Flags: SizeHintPMaxSize,
+			MaxWidth: 200, MaxHeight: 100}, 300, 50, 200, 50},
+		{"max size of 0 is ignored", &NormalHints{Flags: SizeHintPMaxSize,
+			MaxHeight: 100}, 300, 300, 300, 100},
+		{"base size as min size", &NormalHints{Flags: SizeHintPBaseSize,
+			BaseWidth: 5, BaseHeight: 8}, 1, 10, 5, 10},
+		{"min size wins over max size", &NormalHints{
+			Flags:    SizeHintPMinSize | SizeHintPMaxSize,
+			MinWidth: 50, MinHeight: 50, MaxWidth: 40, MaxHeight: 40},
+			45, 45, 50, 50},
+
+		// Resize increments.
+		{"increments", &NormalHints{
+			Flags:     SizeHintPBaseSize | SizeHintPResizeInc,
+			BaseWidth: 10, BaseHeight: 20, WidthInc: 8, HeightInc: 6},
+			30, 30, 34, 32},
+		{"increments round down", &NormalHints{
+			Flags:     SizeHintPBaseSize | SizeHintPResizeInc,
+			BaseWidth: 10, BaseHeight: 20, WidthInc: 8, HeightInc: 6},
+			29, 22, 26, 20},
+		{"increments without a base size", &NormalHints{
+			Flags: SizeHintPResizeInc, WidthInc: 10, HeightInc: 10},
+			94, 96, 90, 100},
+		{"min size as base size", &NormalHints{
+			Flags:    SizeHintPMinSize | SizeHintPResizeInc,
+			MinWidth: 15, MinHeight: 15, WidthInc: 10, HeightInc: 10},
+			31, 10, 35, 15},
+		{"increments below the max size", &NormalHints{
+			Flags:    SizeHintPMaxSize | SizeHintPResizeInc,
+			MaxWidth: 95, MaxHeight: 95, WidthInc: 10, HeightInc: 10},
+			95, 200, 90, 90},
+		{"increments above the min size", &NormalHints{
+			Flags: SizeHintPMinSize | SizeHintPBaseSize |
+				SizeHintPResizeInc,
+			MinWidth: 12, MinHeight: 12, BaseWidth: 0, BaseHeight: 0,
+			WidthInc: 10, HeightInc: 10}, 1, 14, 20, 20},
+		{"increments of 0 are ignored", &NormalHints{
+			Flags: SizeHintPResizeInc, HeightInc: 10}, 33, 33, 33, 30},
+
+		// Aspect ratios.
+		{"too tall", &NormalHints{Flags: SizeHintPAspect,
+			MinAspectNum: 1, MinAspectDen: 1, MaxAspectNum: 2,
+			MaxAspectDen: 1}, 50, 100, 100, 100},
+		{"too wide", &NormalHints{Flags: SizeHintPAspect,
+			MinAspectNum: 1, MinAspectDen: 1, MaxAspectNum: 2,
+			MaxAspectDen: 1}, 300, 100, 300, 150},
+		{"aspect ratio in range", &NormalHints{Flags: SizeHintPAspect,
+			MinAspectNum: 1, MinAspectDen: 1, MaxAspectNum: 2,
+			MaxAspectDen: 1}, 150, 100, 150, 100},
+		{"too tall, at the max width", &NormalHints{
+			Flags:    SizeHintPAspect | SizeHintPMaxSize,
+			MaxWidth: 60, MaxHeight: 200,
+			MinAspectNum: 1, MinAspectDen: 1}, 50, 100, 50, 50},
+		{"too wide, at the max height", &NormalHints{
+			Flags:    SizeHintPAspect | SizeHintPMaxSize,
+			MaxWidth: 500, MaxHeight: 100,
+			MaxAspectNum: 2, MaxAspectDen: 1}, 300, 100, 200, 100},
+		{"aspect ratio without the base size", &NormalHints{
+			Flags:     SizeHintPAspect | SizeHintPBaseSize,
+			BaseWidth: 10, BaseHeight: 10,
+			MinAspectNum: 1, MinAspectDen: 1}, 50, 100, 100, 100},
+		{"aspect ratio with increments", &NormalHints{
+			Flags:    SizeHintPAspect | SizeHintPResizeInc,
+			WidthInc: 7, HeightInc: 1,
+			MinAspectNum: 1, MinAspectDen: 1}, 10, 20, 21, 20},
+		{"min and max sizes win over the aspect ratio", &NormalHints{
+			Flags: SizeHintPAspect | SizeHintPMinSize |
+				SizeHintPMaxSize,
+			MinWidth: 100, MinHeight: 100, MaxWidth: 100, MaxHeight: 100,
+			MinAspectNum: 2, MinAspectDen: 1}, 100, 100, 100, 100},
+	}
+	for _, test := range tests {
+		w, h := test.hints.ConstrainSize(test.width, test.height)
+		if w != test.wantWidth || h != test.wantHeight {
+			t.Fatalf("%s: %dx%d was constrained to %dx%d, but %dx%d was "+
+				"expected.", test.name, test.width, test.height, w, h,
+				test.wantWidth, test.wantHeight)
+		}
+	}
+}
+
+// TestGravity checks the positions of windows and frames for each gravity.
+// The window is 100x50 at (10, 20) with a border of 2 pixels, and its frame
+// has sides of 1, 7, 20 and 5 pixels. Windows are resized to 20x10.
+func TestGravity(t *testing.T) {
+	tests := []struct {
+		gravity          uint
+		refx, refy       int
+		resizex, resizey int
+		framex, framey   int
+	}{
+		{xproto.GravityNorthWest, 10, 20, 10, 20, 10, 20},
+		{xproto.GravityNorth, 60, 20, 50, 20, 8, 20},
+		{xproto.GravityNorthEast, 110, 20, 90, 20, 6, 20},
+		{xproto.GravityWest, 10, 45, 10, 40, 10, 10},
+		{xproto.GravityCenter, 60, 45, 50, 40, 8, 10},
+		{xproto.GravityEast, 110, 45, 90, 40, 6, 10},
+		{xproto.GravitySouthWest, 10, 70, 10, 60, 10, -1},
+		{xproto.GravitySouth, 60, 70, 50, 60, 8, -1},
+		{xproto.GravitySouthEast, 110, 70, 90, 60, 6, -1},
+		{xproto.GravityStatic, 10, 20, 10, 20, 11, 2},
+	}
+	for _, test := range tests {
+		x, y := GravityPoint(test.gravity, 10, 20, 100, 50)
+		if x != test.refx || y != test.refy {
+			t.Fatalf("Gravity %d: The reference point is (%d, %d).",
+				test.gravity, x, y)
+		}
+		x, y = GravityResize(test.gravity, 10, 20, 100, 50, 20, 10)
+		if x != test.resizex || y != test.resizey {
+			t.Fatalf("Gravity %d: The window was resized at (%d, %d).",
+				test.gravity, x, y)
+		}
+
+		x, y = GravityFrame(test.gravity, 10, 20, 100, 50, 2, 1, 7, 20, 5)
+		if x != test.framex || y != test.framey {
+			t.Fatalf("Gravity %d: The frame is at (%d, %d).",
+				test.gravity, x, y)
+		}
+		x, y = GravityClient(test.gravity, x, y, 100, 50, 2, 1, 7, 20, 5)
+		if x != 10 || y != 20 {
+			t.Fatalf("Gravity %d: The window was taken out of its frame "+
+				"at (%d, %d).", test.gravity, x, y)
+		}
+	}
+
+	hints := &NormalHints{WinGravity: xproto.GravitySouth}
+	if g := hints.Gravity(); g != xproto.GravityNorthWest {
+		t.Fatalf("The gravity is %d without its flag.", g)
+	}
+	hints.Flags = SizeHintPWinGravity
+	if g := hints.Gravity(); g != xproto.GravitySouth {
+		t.Fatalf("The gravity is %d instead of South.", g)
+	}
+}
@@ -0,0 +1,193 @@
+package layout
+
+/*
+layout/bsp.go contains a layout based on a binary space partitioning tree,
+like the one used by bspwm.
+
+Each node of the tree is either a leaf, which is the tile of one window, or a
+split of its area into two parts (side by side or one above the other), each
+of which is another node. Unlike the other layouts, the tree is changed as
+windows come and go (with Insert and Remove), so that the splits users
+chose are remembered.
+*/
+
+import (
+	"github.com/BurntSushi/xgbutil/xrect"
+)
+
+// Split is the direction a BSP node splits its area in.
+type Split int
+
+const (
+	// SplitAuto splits the area along its longer side, so that wide areas are
+	// split into columns and tall areas into rows.
+	SplitAuto Split = iota
+
+	// SplitColumns splits the area into two parts side by side.
+	SplitColumns
+
+	// SplitRows splits the area into two parts, one above the other.
+	SplitRows
+)
+
+// BSP is a layout where each window is a leaf of a binary tree of splits.
+// The leaves are the windows, in order from left to right in the tree.
+// The zero value is an empty tree.
+//
+// If there are more windows than leaves when the tree is arranged, the extra
+// windows dwindle (see Spiral) into the tile of the last leaf. If there are
+// fewer, the tiles of the extra leaves are given to their siblings.
+type BSP struct {
+	Root *Node
+}
+
+// Node is a node of a BSP tree. It is a leaf if First and Second are nil.
+// Otherwise, First and Second are the two parts of its area.
+type Node struct {
+	Split Split
+
+	// Ratio is the share of the area used by First. It is 0.5 if it isn't
+	// strictly between 0 and 1.
+	Ratio float64
+
+	First, Second *Node
+}
+
+// Len returns the number of leaves (windows) in the tree.
+func (b *BSP) Len() int {
+	if b.Root == nil {
+		return 0
+	}
+	return b.Root.leaves()
+}
+
+// Insert adds a window to the tree by splitting the leaf with index 'i' in
+// two. The window at 'i' keeps the first part, and the new window (which
+// has index i+1) gets the second part. If 'i' isn't the index of a leaf,
+// the last leaf is split. The first window inserted becomes the root.
+func (b *BSP) Insert(i int, split Split, ratio float64) {
+	if b.Root == nil {
+		b.Root = &Node{}
+		return
+	}
+	if i < 0 || i >= b.Len() {
+		i = b.Len() - 1
+	}
+	leaf := b.Root.leaf(i)
+	*leaf = Node{
+		Split:  split,
+		Ratio:  ratio,
+		First:  &Node{},
+		Second: &Node{},
+	}
+}
+
+// Remove removes the window with index 'i' from the tree. Its sibling takes
+// the place of their parent, so that it gets the whole area of the parent.
+// Nothing happens if 'i' isn't the index of a leaf.
+func (b *BSP) Remove(i int) {
+	if i < 0 || i >= b.Len() {
+		return
+	}
+	if b.Root.isLeaf() {
+		b.Root = nil
+		return
+	}
+
+	// Find the parent of the leaf.
+	node := b.Root
+	for {
+		firstLeaves := node.First.leaves()
+		child, sibling := node.First, node.Second
+		if i >= firstLeaves {
+			i -= firstLeaves
+			child, sibling = node.Second, node.First
+		}
+		if child.isLeaf() {
+			*node = *sibling
+			return
+		}
+		node = child
+	}
+}
+
+// Tiles satisfies the Layout interface.
+func (b *BSP) Tiles(area xrect.Rect, n, gap int) []xrect.Rect {
+	if n <= 0 {
+		return nil
+	}
+	tiles := make([]xrect.Rect, 0, n)
+	if b.Root != nil {
+		tiles = b.Root.tiles(area, n, gap, tiles)
+	}
+
+	if len(tiles) < n {
+		last := area
+		if len(tiles) > 0 {
+			last = tiles[len(tiles)-1]
+			tiles = tiles[:len(tiles)-1]
+		}
+		tiles = append(tiles,
+			Spiral{Dwindle: true}.Tiles(last, n-len(tiles), gap)...)
+	}
+	return tiles
+}
+
+// tiles appends the tiles of the leaves of the node to 'tiles', until there
+// are 'n' tiles.
+func (nd *Node) tiles(r xrect.Rect, n, gap int,
+	tiles []xrect.Rect) []xrect.Rect {
+
+	switch {
+	case len(tiles) >= n:
+		return tiles
+	case nd.isLeaf():
+		return append(tiles, r)
+	case nd.First.leaves() >= n-len(tiles):
+		// The second part has no windows, so the first part gets it all.
+		return nd.First.tiles(r, n, gap, tiles)
+	}
+
+	split := nd.Split
+	if split == SplitAuto {
+		split = SplitRows
+		if r.Width() >= r.Height() {
+			split = SplitColumns
+		}
+	}
+	divider := columns
+	if split == SplitRows {
+		divider = rows
+	}
+
+	rt := ratio(nd.Ratio)
+	parts := divider(r, gap, []float64{rt, 1 - rt})
+	tiles = nd.First.tiles(parts[0], n, gap, tiles)
+	return nd.Second.tiles(parts[1], n, gap, tiles)
+}
+
+// isLeaf returns whether the node is a leaf.
+func (nd *Node) isLeaf() bool {
+	return nd.First == nil || nd.Second == nil
+}
+
+// leaves returns the number of leaves under the node (including itself).
+func (nd *Node) leaves() int {
+	if nd.isLeaf() {
+		return 1
+	}
+	return nd.First.leaves() + nd.Second.leaves()
+}
+
+// leaf returns the leaf with index 'i' under the node.
+func (nd *Node) leaf(i int) *Node {
+	for !nd.isLeaf() {
+		if firstLeaves := nd.First.leaves(); i < firstLeaves {
+			nd = nd.First
+		} else {
+			i -= firstLeaves
+			nd = nd.Second
+		}
+	}
+	return nd
+}
@@ -0,0 +1,35 @@
+/*
+Package layout computes window geometries for tiling window managers and
+tools that tile windows.
+
+Given a workarea (like the one from ewmh.WorkareaGet, or a head after
+xrect.ApplyStrut) and a number of windows, a Layout divides the workarea into
+one tile for each window. Arrange then fits each window into its tile,
+taking gaps, border widths and the size hints of each window into account.
+
+Layouts
+
+MasterStack puts one or more master windows on one side, and stacks the rest
+on the other side. Columns, Rows and Grid divide the area evenly. Spiral
+gives each window a share of what's left by the windows before it, like a
+Fibonacci spiral. BSP is a binary tree of splits that windows are inserted
+into and removed from, so that the layout can be changed by the user.
+
+New layouts can be added by satisfying the Layout interface.
+
+A quick example
+
+To tile the windows of a head with a master on the left taking 60% of the
+width, 8 pixel gaps and 2 pixel borders:
+
+	geoms := layout.Arrange(layout.MasterStack{Ratio: 0.6}, head,
+		len(wins), layout.Options{Gap: 8, OuterGap: 8, BorderWidth: 2},
+		hints)
+	for i, geom := range geoms {
+		wins[i].MoveResize(geom.Pieces())
+	}
+
+Where 'hints' has the WM_NORMAL_HINTS of each window. (See
+icccm.WmNormalHintsGet.)
+*/
+package layout
@@ -0,0 +1,177 @@
+package layout
+
+/*
+layout/layout.go contains the Layout interface and Arrange, which turns the
+tiles of a layout into window geometries, along with helpers for dividing
+areas that are shared by the layouts.
+*/
+
+import (
+	"math"
+
+	"github.com/BurntSushi/xgbutil/icccm"
+	"github.com/BurntSushi/xgbutil/xrect"
+)
+
+// Layout is a way of dividing an area into tiles, one for each window.
+type Layout interface {
+	// Tiles divides 'area' into 'n' tiles, with 'gap' pixels between
+	// adjacent tiles. Exactly 'n' tiles are returned, in the order of the
+	// windows they are for.
+	Tiles(area xrect.Rect, n, gap int) []xrect.Rect
+}
+
+// Options are the settings used by Arrange, which are the same for every
+// layout.
+type Options struct {
+	// Gap is the number of pixels between adjacent windows, and OuterGap is
+	// the number of pixels between windows and the edges of the workarea.
+	Gap, OuterGap int
+
+	// BorderWidth is the width of the border of each window. (Which is
+	// inside its tile.)
+	BorderWidth int
+}
+
+// Arrange divides the workarea given (usually from ewmh.WorkareaGet, or a head
+// after xrect.ApplyStrut) into geometries for 'n' windows using the layout
+// given.
+//
+// Each rectangle returned can be used to configure a window: its position is
+// that of the window's top left border corner, and its size is the size of
+// the window without its border (like in xwindow.MoveResize).
+//
+// 'hints' has the size hints of each window, or nil for windows without
+// them. (It may also be shorter than 'n'.) Windows are sized to respect
+// their size hints (see icccm.NormalHints.ConstrainSize). They are never
+// bigger than their tile (unless their minimum size is), and are centered in
+// their tile when they're smaller than it.
+func Arrange(l Layout, workarea xrect.Rect, n int, opts Options,
+	hints []*icccm.NormalHints) []xrect.Rect {
+
+	if n <= 0 {
+		return nil
+	}
+
+	x, y, w, h := workarea.Pieces()
+	g := opts.OuterGap
+	area := xrect.New(x+g, y+g, max(w-2*g, 1), max(h-2*g, 1))
+
+	// Layouts are supposed to return exactly 'n' tiles. If one doesn't,
+	// extra tiles are ignored, and windows without a tile get the whole
+	// area.
+	tiles := l.Tiles(area, n, opts.Gap)
+	bw := opts.BorderWidth
+	geoms := make([]xrect.Rect, n)
+	for i := range geoms {
+		var tile xrect.Rect = area
+		if i < len(tiles) {
+			tile = tiles[i]
+		}
+		tx, ty, tw, th := tile.Pieces()
+		cw, ch := max(tw-2*bw, 1), max(th-2*bw, 1)
+		if i < len(hints) && hints[i] != nil {
+			cw, ch = fitHints(hints[i], cw, ch)
+		}
+		geoms[i] = xrect.New(tx+(tw-cw-2*bw)/2, ty+(th-ch-2*bw)/2, cw, ch)
+	}
+	return geoms
+}
+
+// fitHints returns the size nearest to width x height allowed by the size
+// hints given, which isn't bigger than width x height. (Unless the minimum
+// size is.)
+func fitHints(nh *icccm.NormalHints, width, height int) (int, int) {
+	hints := *nh
+	if hints.Flags&icccm.SizeHintPMaxSize == 0 {
+		hints.MaxWidth, hints.MaxHeight = 0, 0
+	}
+	hints.Flags |= icccm.SizeHintPMaxSize
+	if hints.MaxWidth == 0 || int(hints.MaxWidth) > width {
+		hints.MaxWidth = uint(width)
+	}
+	if hints.MaxHeight == 0 || int(hints.MaxHeight) > height {
+		hints.MaxHeight = uint(height)
+	}
+	return hints.ConstrainSize(width, height)
+}
+
+// divide divides 'length' pixels, starting at 'start', into parts with sizes
+// proportional to 'weights' and 'gap' pixels between them. Each part is at
+// least 1 pixel long. The positions and sizes of the parts are returned.
+func divide(start, length, gap int, weights []float64) ([]int, []int) {
+	n := len(weights)
+	avail := max(length-gap*(n-1), n)
+
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+
+	pos, sizes := make([]int, n), make([]int, n)
+	cum, prev := 0.0, 0
+	for i, w := range weights {
+		cum += w
+		end := int(math.Floor(float64(avail)*cum/total + 0.5))
+		if i == n-1 {
+			end = avail
+		}
+		end = min(max(end, prev+1), avail-(n-1-i))
+		pos[i], sizes[i] = start+prev+i*gap, end-prev
+		prev = end
+	}
+	return pos, sizes
+}
+
+// evenly returns 'n' equal weights for divide.
+func evenly(n int) []float64 {
+	weights := make([]float64, n)
+	for i := range weights {
+		weights[i] = 1
+	}
+	return weights
+}
+
+// columns divides a rectangle into columns, from left to right.
+func columns(r xrect.Rect, gap int, weights []float64) []xrect.Rect {
+	x, y, w, h := r.Pieces()
+	pos, sizes := divide(x, w, gap, weights)
+	rects := make([]xrect.Rect, len(weights))
+	for i := range rects {
+		rects[i] = xrect.New(pos[i], y, sizes[i], h)
+	}
+	return rects
+}
+
+// rows divides a rectangle into rows, from top to bottom.
+func rows(r xrect.Rect, gap int, weights []float64) []xrect.Rect {
+	x, y, w, h := r.Pieces()
+	pos, sizes := divide(y, h, gap, weights)
+	rects := make([]xrect.Rect, len(weights))
+	for i := range rects {
+		rects[i] = xrect.New(x, pos[i], w, sizes[i])
+	}
+	return rects
+}
+
+// ratio returns 'r' if it's strictly between 0 and 1, or 0.5 otherwise.
+func ratio(r float64) float64 {
+	if r <= 0 || r >= 1 {
+		return 0.5
+	}
+	return r
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,162 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/xgbutil/icccm"
+	"github.com/BurntSushi/xgbutil/xrect"
+)
+
+// bspOf returns a BSP tree with 'leaves' leaves, each inserted after the
+// last one.
+func bspOf(leaves int) *BSP {
+	b := &BSP{}
+	for i := 0; i < leaves; i++ {
+		b.Insert(i-1, SplitAuto, 0.5)
+	}
+	return b
+}
+
+// layouts are the layouts checked by the tests.
+var layouts = []struct {
+	name   string
+	layout Layout
+}{
+	{"MasterStack", MasterStack{}},
+	{"MasterStack 2 right", MasterStack{Masters: 2, Side: Right}},
+	{"MasterStack top", MasterStack{Ratio: 0.7, Side: Top}},
+	{"Columns", Columns{}},
+	{"Rows", Rows{}},
+	{"Grid", Grid{}},
+	{"Spiral", Spiral{}},
+	{"Dwindle", Spiral{Ratio: 0.6, Dwindle: true}},
+	{"BSP empty", &BSP{}},
+	{"BSP 3 leaves", bspOf(3)},
+}
+
+// TestTiles checks that every layout returns exactly 'n' tiles, and that
+// without gaps they cover the whole area without overlapping.
+func TestTiles(t *testing.T) {
+	area := xrect.New(10, 20, 1000, 600)
+	for _, l := range layouts {
+		for _, n := range []int{0, 1, 2, 3, 5, 8} {
+			tiles := l.layout.Tiles(area, n, 0)
+			if len(tiles) != n {
+				t.Fatalf("%s: %d tiles were returned for %d windows.",
+					l.name, len(tiles), n)
+			}
+
+			covered := 0
+			for i, tile := range tiles {
+				if tile.Width() < 1 || tile.Height() < 1 ||
+					xrect.IntersectArea(tile, area) != area1(tile) {
+
+					t.Fatalf("%s, %d windows: Tile %d (%s) isn't inside "+
+						"of %s.", l.name, n, i, tile, area)
+				}
+				for j := 0; j < i; j++ {
+					if xrect.IntersectArea(tile, tiles[j]) > 0 {
+						t.Fatalf("%s, %d windows: Tiles %d and %d overlap.",
+							l.name, n, j, i)
+					}
+				}
+				covered += area1(tile)
+			}
+			if n > 0 && covered != area1(area) {
+				t.Fatalf("%s, %d windows: The tiles cover %d of %d pixels.",
+					l.name, n, covered, area1(area))
+			}
+		}
+	}
+}
+
+// TestTilesGap checks that tiles are 'gap' pixels apart.
+func TestTilesGap(t *testing.T) {
+	tests := []struct {
+		layout Layout
+		want   []xrect.Rect
+	}{
+		{Columns{}, []xrect.Rect{
+			xrect.New(0, 0, 95, 50), xrect.New(105, 0, 95, 50)}},
+		{Rows{}, []xrect.Rect{
+			xrect.New(0, 0, 200, 20), xrect.New(0, 30, 200, 20)}},
+		{MasterStack{Ratio: 0.5}, []xrect.Rect{
+			xrect.New(0, 0, 95, 50), xrect.New(105, 0, 95, 50)}},
+	}
+	for _, test := range tests {
+		tiles := test.layout.Tiles(xrect.New(0, 0, 200, 50), 2, 10)
+		for i, tile := range tiles {
+			if !sameRect(tile, test.want[i]) {
+				t.Fatalf("%T: Tile %d is %s, but %s was expected.",
+					test.layout, i, tile, test.want[i])
+			}
+		}
+	}
+}
+
+// fixedLayout always returns the same tiles, no matter how many windows
+// there are.
+type fixedLayout []xrect.Rect
+
+func (fl fixedLayout) Tiles(area xrect.Rect, n, gap int) []xrect.Rect {
+	return fl
+}
+
+// TestArrange checks the geometries returned by Arrange, including for
+// layouts that don't return one tile per window.
+func TestArrange(t *testing.T) {
+	workarea := xrect.New(0, 0, 110, 70)
+	tile := xrect.New(10, 10, 40, 30)
+	opts := Options{OuterGap: 5, BorderWidth: 2}
+
+	// A window that must be 10x10 pixels.
+	fixed := &icccm.NormalHints{
+		Flags:    icccm.SizeHintPMinSize | icccm.SizeHintPMaxSize,
+		MinWidth: 10, MinHeight: 10, MaxWidth: 10, MaxHeight: 10,
+	}
+
+	tests := []struct {
+		name   string
+		layout Layout
+		n      int
+		hints  []*icccm.NormalHints
+		want   []xrect.Rect
+	}{
+		{"No windows", Columns{}, 0, nil, nil},
+		{"One window", Columns{}, 1, nil, []xrect.Rect{
+			xrect.New(5, 5, 96, 56)}},
+		{"Hints", Columns{}, 2, []*icccm.NormalHints{nil, fixed},
+			[]xrect.Rect{xrect.New(5, 5, 46, 56), xrect.New(73, 28, 10, 10)}},
+		{"Too many tiles", fixedLayout{tile, tile, tile}, 1, nil,
+			[]xrect.Rect{xrect.New(10, 10, 36, 26)}},
+		{"Too few tiles", fixedLayout{tile}, 2, nil, []xrect.Rect{
+			xrect.New(10, 10, 36, 26), xrect.New(5, 5, 96, 56)}},
+		{"No tiles", fixedLayout{}, 1, nil, []xrect.Rect{
+			xrect.New(5, 5, 96, 56)}},
+	}
+	for _, test := range tests {
+		geoms := Arrange(test.layout, workarea, test.n, opts, test.hints)
+		if len(geoms) != len(test.want) {
+			t.Fatalf("%s: %d geometries were returned, but %d were "+
+				"expected.", test.name, len(geoms), len(test.want))
+		}
+		for i, geom := range geoms {
+			if !sameRect(geom, test.want[i]) {
+				t.Fatalf("%s: Geometry %d is %s, but %s was expected.",
+					test.name, i, geom, test.want[i])
+			}
+		}
+	}
+}
+
+// area1 returns the number of pixels in a rectangle.
+func area1(r xrect.Rect) int {
+	return r.Width() * r.Height()
+}
+
+// sameRect returns whether two rectangles have the same geometry.
+func sameRect(r1, r2 xrect.Rect) bool {
+	x1, y1, w1, h1 := r1.Pieces()
+	x2, y2, w2, h2 := r2.Pieces()
+	return x1 == x2 && y1 == y2 && w1 == w2 && h1 == h2
+}
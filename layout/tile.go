@@ -0,0 +1,159 @@
+package layout
+
+/*
+layout/tile.go contains the layouts that don't need any state besides their
+settings: master/stack, columns, rows, grid and spiral.
+*/
+
+import (
+	"math"
+
+	"github.com/BurntSushi/xgbutil/xrect"
+)
+
+// Side is a side of an area, like the side the master windows of MasterStack
+// are on.
+type Side int
+
+const (
+	Left Side = iota
+	Right
+	Top
+	Bottom
+)
+
+// MasterStack puts the first windows (the masters) next to each other on one
+// side of the area, and stacks the rest of the windows on the other side.
+// (Like the 'tile' layout of dwm.) When all of the windows are masters, they
+// use the whole area.
+type MasterStack struct {
+	// Masters is the number of master windows. It is 1 if it isn't positive.
+	Masters int
+
+	// Ratio is the share of the area used by the master windows. It is 0.5
+	// if it isn't strictly between 0 and 1.
+	Ratio float64
+
+	// Side is the side of the area the master windows are on. Masters on the
+	// left or right are stacked in a column, and masters on the top or
+	// bottom are put in a row.
+	Side Side
+}
+
+// Tiles satisfies the Layout interface.
+func (ms MasterStack) Tiles(area xrect.Rect, n, gap int) []xrect.Rect {
+	if n <= 0 {
+		return nil
+	}
+	masters := ms.Masters
+	if masters < 1 {
+		masters = 1
+	}
+
+	split, stack := columns, rows
+	if ms.Side == Top || ms.Side == Bottom {
+		split, stack = rows, columns
+	}
+	if masters >= n {
+		return stack(area, gap, evenly(n))
+	}
+
+	r := ratio(ms.Ratio)
+	parts := split(area, gap, []float64{r, 1 - r})
+	masterArea, stackArea := parts[0], parts[1]
+	if ms.Side == Right || ms.Side == Bottom {
+		parts = split(area, gap, []float64{1 - r, r})
+		masterArea, stackArea = parts[1], parts[0]
+	}
+	return append(stack(masterArea, gap, evenly(masters)),
+		stack(stackArea, gap, evenly(n-masters))...)
+}
+
+// Columns puts the windows in columns of equal width, from left to right.
+type Columns struct{}
+
+// Tiles satisfies the Layout interface.
+func (Columns) Tiles(area xrect.Rect, n, gap int) []xrect.Rect {
+	if n <= 0 {
+		return nil
+	}
+	return columns(area, gap, evenly(n))
+}
+
+// Rows puts the windows in rows of equal height, from top to bottom.
+type Rows struct{}
+
+// Tiles satisfies the Layout interface.
+func (Rows) Tiles(area xrect.Rect, n, gap int) []xrect.Rect {
+	if n <= 0 {
+		return nil
+	}
+	return rows(area, gap, evenly(n))
+}
+
+// Grid puts the windows in a grid with as many columns as rows (or one more
+// column than rows), filled row by row. If the last row isn't full, its
+// windows are wider so that they fill it.
+type Grid struct{}
+
+// Tiles satisfies the Layout interface.
+func (Grid) Tiles(area xrect.Rect, n, gap int) []xrect.Rect {
+	if n <= 0 {
+		return nil
+	}
+	cols := int(math.Ceil(math.Sqrt(float64(n))))
+	nrows := (n + cols - 1) / cols
+
+	tiles := make([]xrect.Rect, 0, n)
+	for i, row := range rows(area, gap, evenly(nrows)) {
+		count := cols
+		if i == nrows-1 {
+			count = n - cols*(nrows-1)
+		}
+		tiles = append(tiles, columns(row, gap, evenly(count))...)
+	}
+	return tiles
+}
+
+// Spiral gives each window a share of the area left by the windows before
+// it, splitting the area left and right and then top and bottom in turn.
+// (Like the Fibonacci layouts of awesome and dwm.) Each window takes the
+// left, top, right and bottom side of what's left in turn, so that the
+// windows spiral inwards. With Dwindle, each window takes the left or top
+// side instead, so that the windows shrink towards the bottom right corner.
+type Spiral struct {
+	// Ratio is the share of the area left that each window takes. It is 0.5
+	// if it isn't strictly between 0 and 1.
+	Ratio float64
+
+	Dwindle bool
+}
+
+// Tiles satisfies the Layout interface.
+func (sp Spiral) Tiles(area xrect.Rect, n, gap int) []xrect.Rect {
+	if n <= 0 {
+		return nil
+	}
+	r := ratio(sp.Ratio)
+	tiles := make([]xrect.Rect, 0, n)
+	rest := area
+	for i := 0; i < n-1; i++ {
+		split := columns
+		if i%2 == 1 {
+			split = rows
+		}
+
+		// Windows on the right or bottom (every other split of a spiral)
+		// take the second part.
+		if !sp.Dwindle && i%4 >= 2 {
+			parts := split(rest, gap, []float64{1 - r, r})
+			tiles = append(tiles, parts[1])
+			rest = parts[0]
+		} else {
+			parts := split(rest, gap, []float64{r, 1 - r})
+			tiles = append(tiles, parts[0])
+			rest = parts[1]
+		}
+	}
+	return append(tiles, rest)
+}
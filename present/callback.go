@@ -0,0 +1,50 @@
+package present
+
+/*
+present/callback.go defines a callback type for each Present event, in the
+same style as xevent/callback.go.
+
+Callbacks are attached to the window that the events were selected on with
+SelectInput. Init must be called before any callbacks are connected.
+*/
+
+import (
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+)
+
+type ConfigureNotifyFun func(xu *xgbutil.XUtil, event ConfigureNotifyEvent)
+
+func (callback ConfigureNotifyFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), ConfigureNotify, win, callback)
+}
+
+func (callback ConfigureNotifyFun) Run(xu *xgbutil.XUtil,
+	event interface{}) {
+	callback(xu, event.(ConfigureNotifyEvent))
+}
+
+type CompleteNotifyFun func(xu *xgbutil.XUtil, event CompleteNotifyEvent)
+
+func (callback CompleteNotifyFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), CompleteNotify, win, callback)
+}
+
+func (callback CompleteNotifyFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(CompleteNotifyEvent))
+}
+
+type IdleNotifyFun func(xu *xgbutil.XUtil, event IdleNotifyEvent)
+
+func (callback IdleNotifyFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), IdleNotify, win, callback)
+}
+
+func (callback IdleNotifyFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(IdleNotifyEvent))
+}
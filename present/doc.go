@@ -0,0 +1,68 @@
+/*
+Package present provides support for the Present extension, which shows the
+contents of pixmaps in windows in sync with the vertical refresh of the
+monitor. This avoids tearing and makes it possible to pace animations with
+the refresh rate of the screen instead of a timer.
+
+The Present extension reports when each pixmap has been shown (along with
+the media stream counter, or MSC, which counts vertical refreshes) and when
+the X server is done with each pixmap, so that it can be drawn to again.
+These are reported as events, which are delivered to callbacks in xevent's
+main event loop.
+
+XGB does not have bindings for the Present extension, so the few requests
+needed are encoded by the present package itself.
+
+Usage
+
+Most programs should use a Presenter, which takes care of the Present
+extension for you. It keeps a small pool of xgraphics.Image values: ask the
+Presenter for a Buffer, draw to it and give it back to Present. The image is
+shown at the next vertical refresh. If the Present extension isn't available,
+the Presenter falls back to copying images to the window with CopyArea.
+
+For animations, Start takes a function that draws a single frame. It is
+called in the main event loop every time the previous frame has been shown,
+so that one frame is drawn per refresh. (Or fewer, when drawing a frame takes
+longer than a refresh.)
+
+To use the extension directly, call Init first. It initializes the extension
+and registers a decoder for Present events with the xevent package. Then
+select the events you're interested in on a window with SelectInput, connect
+callbacks for them and show pixmaps with Pixmap.
+
+A quick example
+
+To show an animation that fades the window between black and white:
+
+	p, err := present.NewPresenter(X, win.Id, width, height)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p.Start(func(p *present.Presenter, frame *xgraphics.Image, msc uint64) {
+		v := uint8(msc % 256)
+		frame.For(func(x, y int) xgraphics.BGRA {
+			return xgraphics.BGRA{B: v, G: v, R: v, A: 0xff}
+		})
+	})
+	xevent.Main(X)
+
+A complete example named 'present-animation' can be found in the examples
+directory of the xgbutil package.
+
+Caveats
+
+Present events are sent through the X Generic Event Extension. IdleNotify
+events fit in the 32 bytes of a core event, but CompleteNotify and
+ConfigureNotify events are longer. XGB on its own reads exactly 32 bytes for
+every event, which leaves the rest on the socket and breaks the connection.
+Only connections made with xgbutil.NewConn or xgbutil.NewConnDisplay read
+these events completely, so SelectInput refuses to select them on other
+connections, and a Presenter on them falls back to CopyArea and a timer.
+(See the xinput package, which has the same caveat.)
+
+Since pixmaps given to the X server must have the same depth as the window,
+a Presenter for a window with a 32 bit ARGB visual (see
+xwindow.Window.CreateARGB) uses ARGB images.
+*/
+package present
@@ -0,0 +1,174 @@
+package present
+
+/*
+present/events.go contains the Present event types and the decoder that turns
+generic events read by xevent into them.
+*/
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+)
+
+// Present event types.
+const (
+	ConfigureNotify = 0
+	CompleteNotify  = 1
+	IdleNotify      = 2
+)
+
+// The request that a CompleteNotify event reports on.
+const (
+	CompleteKindPixmap    = 0
+	CompleteKindNotifyMSC = 1
+)
+
+// How a pixmap ended up on the screen, as reported by CompleteNotify.
+// CompleteModeSkip means the pixmap was never shown, because another pixmap
+// was presented for the same MSC.
+const (
+	CompleteModeCopy           = 0
+	CompleteModeFlip           = 1
+	CompleteModeSkip           = 2
+	CompleteModeSuboptimalCopy = 3
+)
+
+// ConfigureNotifyEvent is sent when a window selected with
+// EventMaskConfigureNotify changes size or position.
+type ConfigureNotifyEvent struct {
+	Sequence      uint16
+	Eid           uint32
+	Window        xproto.Window
+	X, Y          int
+	Width, Height int
+	OffX, OffY    int
+	PixmapWidth   int
+	PixmapHeight  int
+	PixmapFlags   uint32
+}
+
+// newConfigureNotifyEvent decodes a ConfigureNotify event.
+func newConfigureNotifyEvent(buf []byte) ConfigureNotifyEvent {
+	return ConfigureNotifyEvent{
+		Sequence:     xgb.Get16(buf[2:]),
+		Eid:          xgb.Get32(buf[12:]),
+		Window:       xproto.Window(xgb.Get32(buf[16:])),
+		X:            int(int16(xgb.Get16(buf[20:]))),
+		Y:            int(int16(xgb.Get16(buf[22:]))),
+		Width:        int(xgb.Get16(buf[24:])),
+		Height:       int(xgb.Get16(buf[26:])),
+		OffX:         int(int16(xgb.Get16(buf[28:]))),
+		OffY:         int(int16(xgb.Get16(buf[30:]))),
+		PixmapWidth:  int(xgb.Get16(buf[32:])),
+		PixmapHeight: int(xgb.Get16(buf[34:])),
+		PixmapFlags:  xgb.Get32(buf[36:]),
+	}
+}
+
+func (ev ConfigureNotifyEvent) String() string {
+	return fmt.Sprintf("ConfigureNotify {Sequence: %d, Eid: %d, Window: %d, "+
+		"X: %d, Y: %d, Width: %d, Height: %d, OffX: %d, OffY: %d}",
+		ev.Sequence, ev.Eid, ev.Window, ev.X, ev.Y, ev.Width, ev.Height,
+		ev.OffX, ev.OffY)
+}
+
+// CompleteNotifyEvent is sent when a Pixmap or NotifyMSC request has been
+// carried out. UST is the time (in microseconds) and MSC is the media stream
+// counter at which the pixmap was shown. (Or at which the requested MSC was
+// reached, for NotifyMSC requests.)
+type CompleteNotifyEvent struct {
+	Sequence uint16
+	Kind     int
+	Mode     int
+	Eid      uint32
+	Window   xproto.Window
+	Serial   uint32
+	UST, MSC uint64
+}
+
+// newCompleteNotifyEvent decodes a CompleteNotify event.
+func newCompleteNotifyEvent(buf []byte) CompleteNotifyEvent {
+	return CompleteNotifyEvent{
+		Sequence: xgb.Get16(buf[2:]),
+		Kind:     int(buf[10]),
+		Mode:     int(buf[11]),
+		Eid:      xgb.Get32(buf[12:]),
+		Window:   xproto.Window(xgb.Get32(buf[16:])),
+		Serial:   xgb.Get32(buf[20:]),
+		UST:      get64(buf[24:]),
+		MSC:      get64(buf[32:]),
+	}
+}
+
+func (ev CompleteNotifyEvent) String() string {
+	return fmt.Sprintf("CompleteNotify {Sequence: %d, Kind: %d, Mode: %d, "+
+		"Eid: %d, Window: %d, Serial: %d, UST: %d, MSC: %d}",
+		ev.Sequence, ev.Kind, ev.Mode, ev.Eid, ev.Window, ev.Serial,
+		ev.UST, ev.MSC)
+}
+
+// IdleNotifyEvent is sent when the X server is done with a pixmap given to
+// a Pixmap request, after which it's safe to draw to the pixmap again.
+type IdleNotifyEvent struct {
+	Sequence  uint16
+	Eid       uint32
+	Window    xproto.Window
+	Serial    uint32
+	Pixmap    xproto.Pixmap
+	IdleFence uint32
+}
+
+// newIdleNotifyEvent decodes an IdleNotify event.
+func newIdleNotifyEvent(buf []byte) IdleNotifyEvent {
+	return IdleNotifyEvent{
+		Sequence:  xgb.Get16(buf[2:]),
+		Eid:       xgb.Get32(buf[12:]),
+		Window:    xproto.Window(xgb.Get32(buf[16:])),
+		Serial:    xgb.Get32(buf[20:]),
+		Pixmap:    xproto.Pixmap(xgb.Get32(buf[24:])),
+		IdleFence: xgb.Get32(buf[28:]),
+	}
+}
+
+func (ev IdleNotifyEvent) String() string {
+	return fmt.Sprintf("IdleNotify {Sequence: %d, Eid: %d, Window: %d, "+
+		"Serial: %d, Pixmap: %d}",
+		ev.Sequence, ev.Eid, ev.Window, ev.Serial, ev.Pixmap)
+}
+
+// decode satisfies xgbutil.GenericDecodeFun. It turns a generic event sent
+// by the Present extension into one of the event types above, and returns
+// the window that callbacks should be attached to.
+func decode(xu *xgbutil.XUtil,
+	ev interface{}) (interface{}, int, xproto.Window) {
+
+	gev := ev.(xevent.GenericEvent)
+	if len(gev.Data) < 32+4*int(gev.Length) {
+		xgbutil.Logger.Printf("ERROR: Only %d bytes of the Present event "+
+			"%s were read, but %d are required.",
+			len(gev.Data), gev, 32+4*int(gev.Length))
+		return nil, 0, 0
+	}
+
+	evtype := xevent.GenericType(gev.Extension, gev.EvType)
+	switch gev.EvType {
+	case ConfigureNotify:
+		e := newConfigureNotifyEvent(gev.Data)
+		return e, evtype, e.Window
+	case CompleteNotify:
+		e := newCompleteNotifyEvent(gev.Data)
+		return e, evtype, e.Window
+	case IdleNotify:
+		e := newIdleNotifyEvent(gev.Data)
+		return e, evtype, e.Window
+	}
+
+	xgbutil.Logger.Printf("ERROR: UNSUPPORTED PRESENT EVENT TYPE: %d",
+		gev.EvType)
+	return nil, 0, 0
+}
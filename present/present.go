@@ -0,0 +1,248 @@
+package present
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+)
+
+// ExtName is the name of the Present extension as reported by the X server.
+const ExtName = "Present"
+
+// The version of the Present extension that present asks for.
+const (
+	MajorVersion = 1
+	MinorVersion = 0
+)
+
+// Options for Pixmap. OptionAsync presents the pixmap immediately if the
+// target MSC has already passed (possibly tearing), and OptionCopy forces the
+// X server to copy the pixmap instead of flipping to it. (So the pixmap is
+// idle as soon as the copy is done.)
+const (
+	OptionNone       = 0
+	OptionAsync      = 1
+	OptionCopy       = 2
+	OptionUST        = 4
+	OptionSuboptimal = 8
+)
+
+// Event masks for SelectInput.
+const (
+	EventMaskNoEvent         = 0
+	EventMaskConfigureNotify = 1
+	EventMaskCompleteNotify  = 2
+	EventMaskIdleNotify      = 4
+)
+
+// Present request numbers (minor opcodes).
+const (
+	presentQueryVersion = 0
+	presentPixmap       = 1
+	presentNotifyMSC    = 2
+	presentSelectInput  = 3
+)
+
+// Init initializes the Present extension. It must be called before any other
+// function in this package.
+// An error is returned if the extension isn't present.
+// Init also registers a decoder with xevent so that Present events are
+// dispatched to callbacks in the main event loop.
+func Init(xu *xgbutil.XUtil) error {
+	c := xu.Conn()
+	reply, err := xproto.QueryExtension(c, uint16(len(ExtName)),
+		ExtName).Reply()
+	switch {
+	case err != nil:
+		return err
+	case !reply.Present:
+		return fmt.Errorf("present.Init: No extension named %s could be "+
+			"found on the server.", ExtName)
+	}
+
+	c.ExtLock.Lock()
+	c.Extensions[ExtName] = reply.MajorOpcode
+	c.ExtLock.Unlock()
+
+	if _, _, err := QueryVersion(xu, MajorVersion, MinorVersion); err != nil {
+		return err
+	}
+
+	xevent.GenericDecoderSet(xu, reply.MajorOpcode, decode)
+	return nil
+}
+
+// QueryVersion announces the version of the Present extension supported by
+// the client and returns the version supported by the X server.
+func QueryVersion(xu *xgbutil.XUtil, major, minor int) (int, int, error) {
+	body := make([]byte, 8)
+	xgb.Put32(body[0:], uint32(major))
+	xgb.Put32(body[4:], uint32(minor))
+
+	buf, err := request(xu, presentQueryVersion, body, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(xgb.Get32(buf[8:])), int(xgb.Get32(buf[12:])), nil
+}
+
+// SelectInput tells X to report the Present events in 'mask' for the window
+// given. (See the EventMask constants.) It returns the event id that
+// identifies the selection, which can be passed to SelectInput again to
+// change the events reported. If 'eid' is 0, a new event id is allocated.
+// Selecting EventMaskNoEvent removes the selection.
+//
+// ConfigureNotify and CompleteNotify events are longer than 32 bytes, and
+// XGB on its own would break the connection when it receives one. So they
+// can only be selected on connections that read them completely (see
+// xgbutil.XUtil.GenericEventsComplete), and an error is returned otherwise.
+func SelectInput(xu *xgbutil.XUtil, eid uint32, win xproto.Window,
+	mask uint32) (uint32, error) {
+
+	long := uint32(EventMaskConfigureNotify | EventMaskCompleteNotify)
+	if mask&long > 0 && !xu.GenericEventsComplete() {
+		return 0, fmt.Errorf("present.SelectInput: ConfigureNotify and " +
+			"CompleteNotify events can only be read by connections made " +
+			"with xgbutil.NewConn or xgbutil.NewConnDisplay.")
+	}
+
+	if eid == 0 {
+		var err error
+		if eid, err = xu.Conn().NewId(); err != nil {
+			return 0, err
+		}
+	}
+
+	body := make([]byte, 12)
+	xgb.Put32(body[0:], eid)
+	xgb.Put32(body[4:], uint32(win))
+	xgb.Put32(body[8:], mask)
+
+	_, err := request(xu, presentSelectInput, body, false)
+	return eid, err
+}
+
+// Pixmap asks X to show the contents of a pixmap in a window when the
+// window's CRTC reaches the MSC (media stream counter, which counts
+// vertical blanks) 'targetMSC'. If 'targetMSC' has already passed, the
+// pixmap is shown at the next vertical blank. (Unless OptionAsync is set.)
+// 'serial' is chosen by the caller and is reported back in the
+// CompleteNotify and IdleNotify events for this request.
+// The pixmap must have the same size and depth as the window, and must not
+// be modified until an IdleNotify event for it is received.
+//
+// Pixmap is unchecked, since it's usually called once per frame. Errors
+// show up in the main event loop.
+func Pixmap(xu *xgbutil.XUtil, win xproto.Window, pix xproto.Pixmap,
+	serial uint32, options uint32, targetMSC uint64) error {
+
+	body := make([]byte, 68)
+	xgb.Put32(body[0:], uint32(win))
+	xgb.Put32(body[4:], uint32(pix))
+	xgb.Put32(body[8:], serial)
+	xgb.Put32(body[12:], 0) // valid region: whole pixmap
+	xgb.Put32(body[16:], 0) // update region: whole pixmap
+	xgb.Put16(body[20:], 0) // x offset
+	xgb.Put16(body[22:], 0) // y offset
+	xgb.Put32(body[24:], 0) // target CRTC: chosen by the server
+	xgb.Put32(body[28:], 0) // wait fence
+	xgb.Put32(body[32:], 0) // idle fence
+	xgb.Put32(body[36:], options)
+	put64(body[44:], targetMSC)
+	put64(body[52:], 0) // divisor
+	put64(body[60:], 0) // remainder
+
+	return send(xu, presentPixmap, body)
+}
+
+// NotifyMSC asks X to send a CompleteNotify event (with the kind
+// CompleteKindNotifyMSC) for the window given when its CRTC reaches
+// 'targetMSC'. This can be used to wait for a vertical blank without
+// presenting anything.
+func NotifyMSC(xu *xgbutil.XUtil, win xproto.Window, serial uint32,
+	targetMSC uint64) error {
+
+	body := make([]byte, 36)
+	xgb.Put32(body[0:], uint32(win))
+	xgb.Put32(body[4:], serial)
+	put64(body[12:], targetMSC)
+	put64(body[20:], 0) // divisor
+	put64(body[28:], 0) // remainder
+
+	return send(xu, presentNotifyMSC, body)
+}
+
+// opcode returns the major opcode of the Present extension. It is zero if
+// Init hasn't been called.
+func opcode(xu *xgbutil.XUtil) byte {
+	c := xu.Conn()
+	c.ExtLock.RLock()
+	defer c.ExtLock.RUnlock()
+
+	return c.Extensions[ExtName]
+}
+
+// encode returns a Present request with the given minor opcode and body.
+// XGB doesn't have bindings for the Present extension, so the requests are
+// encoded by hand. 'body' is padded to a multiple of 4 bytes.
+func encode(xu *xgbutil.XUtil, minor byte, body []byte) ([]byte, error) {
+	major := opcode(xu)
+	if major == 0 {
+		return nil, fmt.Errorf("The Present extension has not been " +
+			"initialized. Please call present.Init first.")
+	}
+
+	size := xgb.Pad(4 + len(body))
+	buf := make([]byte, size)
+	buf[0] = major
+	buf[1] = minor
+	xgb.Put16(buf[2:], uint16(size/4))
+	copy(buf[4:], body)
+	return buf, nil
+}
+
+// request sends a checked Present request. If 'reply' is true, the raw bytes
+// of the reply are returned.
+func request(xu *xgbutil.XUtil, minor byte, body []byte,
+	reply bool) ([]byte, error) {
+
+	buf, err := encode(xu, minor, body)
+	if err != nil {
+		return nil, err
+	}
+
+	c := xu.Conn()
+	cookie := c.NewCookie(true, reply)
+	c.NewRequest(buf, cookie)
+	if reply {
+		return cookie.Reply()
+	}
+	return nil, cookie.Check()
+}
+
+// send sends an unchecked Present request without a reply.
+func send(xu *xgbutil.XUtil, minor byte, body []byte) error {
+	buf, err := encode(xu, minor, body)
+	if err != nil {
+		return err
+	}
+
+	c := xu.Conn()
+	c.NewRequest(buf, c.NewCookie(false, false))
+	return nil
+}
+
+// put64 writes a 64 bit integer in the byte order used by XGB.
+func put64(buf []byte, v uint64) {
+	xgb.Put32(buf[0:], uint32(v))
+	xgb.Put32(buf[4:], uint32(v>>32))
+}
+
+// get64 reads a 64 bit integer in the byte order used by XGB.
+func get64(buf []byte) uint64 {
+	return uint64(xgb.Get32(buf[0:])) | uint64(xgb.Get32(buf[4:]))<<32
+}
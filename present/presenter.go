@@ -0,0 +1,367 @@
+package present
+
+/*
+present/presenter.go contains a Presenter, which shows a sequence of images
+in a window without tearing, and paces animations with the vertical refresh
+of the monitor the window is on.
+
+A Presenter keeps a small pool of xgraphics.Image buffers. An image given to
+the X server with a Pixmap request may be shown long after the request is
+sent (and, if the server flips to it, stays on the screen until the next one
+is shown), so it must not be drawn to until an IdleNotify event says it's
+free again. The pool makes sure there is always a free image to draw the next
+frame to while the previous ones are still in use.
+
+When the Present extension isn't available (or its events can't be read,
+see SelectInput), images are copied to the window with CopyArea instead and
+frames are paced with a timer.
+*/
+
+import (
+	"image"
+	"sync/atomic"
+	"time"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+	"github.com/BurntSushi/xgbutil/xgraphics"
+	"github.com/BurntSushi/xgbutil/xprop"
+)
+
+// maxBuffers is the maximum number of images in the pool of a Presenter.
+// Three are enough to draw one frame while another is waiting to be shown
+// and a third is on the screen.
+const maxBuffers = 3
+
+// frameAtom is the type of the client messages used to drive frames when the
+// Present extension isn't available.
+const frameAtom = "_XGBUTIL_PRESENT_FRAME"
+
+// FrameFun is the type of function called by a Presenter to draw each frame
+// of an animation. 'frame' is an image with the size of the window that is
+// free to be drawn to, and is presented when the function returns. (Its
+// previous contents are undefined.) 'msc' is the media stream counter at
+// which the previous frame was shown, which counts vertical refreshes and can
+// be used to advance the animation by the right amount even when frames are
+// skipped. When the Present extension isn't available, 'msc' counts the ticks
+// of the fallback timer instead.
+type FrameFun func(p *Presenter, frame *xgraphics.Image, msc uint64)
+
+// Presenter shows images in a window. Images are obtained from Buffer, drawn
+// to and then shown with Present. Alternatively, Start can be used to call a
+// function that draws each frame of an animation in sync with the vertical
+// refresh.
+//
+// The methods of a Presenter must be called from the goroutine running the
+// xevent main loop (or before it starts), since the Presenter is driven by
+// events.
+type Presenter struct {
+	X      *xgbutil.XUtil
+	Window xproto.Window
+
+	// Interval is the time between frames when the Present extension isn't
+	// available. It defaults to 1/60th of a second.
+	Interval time.Duration
+
+	width, height int
+	argb          bool
+
+	// present is true if the Present extension is used, eid is the Present
+	// event selection for the window and serial is the serial of the last
+	// Pixmap request.
+	present bool
+	eid     uint32
+	serial  uint32
+
+	// msc is the media stream counter of the last frame that was shown.
+	msc uint64
+
+	buffers []*buffer
+
+	frame     FrameFun
+	running   bool
+	waiting   bool
+	destroyed bool
+	ticks     chan struct{}
+	tickAtom  xproto.Atom
+
+	// The callbacks attached to the window. Pointers to them are attached,
+	// so that Destroy can detach them without touching other callbacks.
+	onComplete CompleteNotifyFun
+	onIdle     IdleNotifyFun
+	onTick     xevent.ClientMessageFun
+
+	// pending is 1 while a client message sent by ticker hasn't been
+	// handled yet, so that messages don't pile up when frames are slow.
+	pending int32
+}
+
+// buffer is an image in the pool of a Presenter. An image is busy between
+// being presented and the IdleNotify event for its pixmap.
+type buffer struct {
+	img  *xgraphics.Image
+	busy bool
+}
+
+// NewPresenter creates a Presenter for the window given, which should
+// already be mapped. Images have the size given, which should be the size of
+// the window. (Call Resize when the window's size changes.)
+// If the Present extension is available, it is initialized (there is no
+// need to call Init). Otherwise, or if the connection can't read Present
+// events (see SelectInput), the Presenter falls back to CopyArea.
+func NewPresenter(xu *xgbutil.XUtil, win xproto.Window,
+	width, height int) (*Presenter, error) {
+
+	geom, err := xproto.GetGeometry(xu.Conn(),
+		xproto.Drawable(win)).Reply()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Presenter{
+		X:        xu,
+		Window:   win,
+		Interval: time.Second / 60,
+		width:    width,
+		height:   height,
+		argb:     geom.Depth == 32 && xu.Screen().RootDepth != 32,
+	}
+
+	if opcode(xu) != 0 || Init(xu) == nil {
+		p.eid, err = SelectInput(xu, 0, win,
+			EventMaskCompleteNotify|EventMaskIdleNotify)
+		if err == nil {
+			p.present = true
+		}
+	}
+
+	if p.present {
+		p.onComplete, p.onIdle = p.complete, p.idle
+		xevent.ConnectGeneric(xu, opcode(xu), CompleteNotify, win,
+			&p.onComplete)
+		xevent.ConnectGeneric(xu, opcode(xu), IdleNotify, win, &p.onIdle)
+	} else {
+		p.tickAtom, err = xprop.Atm(xu, frameAtom)
+		if err != nil {
+			return nil, err
+		}
+		p.onTick = p.tick
+		xevent.ConnectCallback(xu, xevent.ClientMessage, win, &p.onTick)
+	}
+	return p, nil
+}
+
+// PresentAvailable returns true if the Presenter uses the Present extension,
+// and false if it falls back to CopyArea.
+func (p *Presenter) PresentAvailable() bool {
+	return p.present
+}
+
+// MSC returns the media stream counter at which the last frame was shown.
+func (p *Presenter) MSC() uint64 {
+	return p.msc
+}
+
+// Buffer returns an image that is free to be drawn to and presented.
+// Its contents are undefined. (It may contain a frame presented earlier.)
+// If every image in the pool is still in use by the X server, nil is
+// returned.
+func (p *Presenter) Buffer() *xgraphics.Image {
+	for _, b := range p.buffers {
+		if !b.busy {
+			return b.img
+		}
+	}
+	if len(p.buffers) >= maxBuffers {
+		return nil
+	}
+
+	img := xgraphics.New(p.X, image.Rect(0, 0, p.width, p.height))
+	img.ARGB = p.argb
+	if err := img.CreatePixmap(); err != nil {
+		xgbutil.Logger.Printf("Could not create a pixmap for a presenter "+
+			"buffer: %s", err)
+		return nil
+	}
+	p.buffers = append(p.buffers, &buffer{img: img})
+	return img
+}
+
+// Present sends an image returned by Buffer to X and shows it in the window
+// at the next vertical refresh. The image must not be modified until it is
+// returned by Buffer again.
+func (p *Presenter) Present(img *xgraphics.Image) error {
+	img.XDraw()
+	if !p.present {
+		img.XExpPaint(p.Window, 0, 0)
+		return nil
+	}
+
+	for _, b := range p.buffers {
+		if b.img == img {
+			b.busy = true
+		}
+	}
+	p.serial++
+	return Pixmap(p.X, p.Window, img.Pixmap, p.serial, OptionNone, p.msc+1)
+}
+
+// Resize changes the size of the images in the pool. Images still in use by
+// the X server are freed anyway. (The X server keeps the pixmaps around
+// until it's done with them.)
+func (p *Presenter) Resize(width, height int) {
+	p.width, p.height = width, height
+	p.freeBuffers()
+	if p.waiting {
+		p.next()
+	}
+}
+
+// Start calls 'frame' to draw each frame of an animation, presenting one
+// frame per vertical refresh. (Or fewer, if drawing a frame takes too long.)
+// Frames are drawn in the main event loop, so xevent.Main must be running.
+// Starting a Presenter that is already running replaces its frame function.
+func (p *Presenter) Start(frame FrameFun) {
+	p.frame = frame
+	if p.running {
+		return
+	}
+	p.running = true
+	if !p.present {
+		p.ticks = make(chan struct{})
+		go p.ticker(p.ticks, p.msc)
+	}
+	p.next()
+}
+
+// Stop stops the animation started by Start. The last frame stays on the
+// screen.
+func (p *Presenter) Stop() {
+	if !p.running {
+		return
+	}
+	p.running = false
+	p.waiting = false
+	if p.ticks != nil {
+		close(p.ticks)
+		p.ticks = nil
+	}
+}
+
+// Destroy stops the animation, frees the images in the pool, stops
+// listening for Present events on the window and detaches the Presenter's
+// callbacks from the window. (Other callbacks of the window are left alone.)
+func (p *Presenter) Destroy() {
+	p.Stop()
+	p.freeBuffers()
+	if p.present {
+		SelectInput(p.X, p.eid, p.Window, EventMaskNoEvent)
+		xevent.DetachGeneric(p.X, opcode(p.X), CompleteNotify, p.Window,
+			&p.onComplete)
+		xevent.DetachGeneric(p.X, opcode(p.X), IdleNotify, p.Window,
+			&p.onIdle)
+	} else {
+		xevent.DetachCallback(p.X, xevent.ClientMessage, p.Window,
+			&p.onTick)
+	}
+	p.destroyed = true
+}
+
+// next draws and presents the next frame of the animation. If there is no
+// free image, the frame is drawn when one becomes free.
+func (p *Presenter) next() {
+	if !p.running || p.destroyed {
+		return
+	}
+	img := p.Buffer()
+	if img == nil {
+		p.waiting = true
+		return
+	}
+	p.waiting = false
+
+	p.frame(p, img, p.msc)
+	if err := p.Present(img); err != nil {
+		xgbutil.Logger.Printf("Could not present frame: %s", err)
+	}
+}
+
+// complete responds to CompleteNotify events by drawing the next frame, once
+// the last frame presented has been shown (or skipped).
+func (p *Presenter) complete(xu *xgbutil.XUtil, ev CompleteNotifyEvent) {
+	if p.destroyed || ev.Eid != p.eid || ev.Kind != CompleteKindPixmap {
+		return
+	}
+	if ev.MSC > p.msc {
+		p.msc = ev.MSC
+	}
+	if ev.Serial == p.serial {
+		p.next()
+	}
+}
+
+// idle responds to IdleNotify events by returning the image with the pixmap
+// given to the pool, and drawing a frame if one was waiting for it.
+func (p *Presenter) idle(xu *xgbutil.XUtil, ev IdleNotifyEvent) {
+	if p.destroyed || ev.Eid != p.eid {
+		return
+	}
+	for _, b := range p.buffers {
+		if b.img.Pixmap == ev.Pixmap {
+			b.busy = false
+		}
+	}
+	if p.waiting {
+		p.next()
+	}
+}
+
+// tick responds to the client messages sent by ticker when the Present
+// extension isn't available.
+func (p *Presenter) tick(xu *xgbutil.XUtil, ev xevent.ClientMessageEvent) {
+	if p.destroyed || ev.Type != p.tickAtom {
+		return
+	}
+	atomic.StoreInt32(&p.pending, 0)
+	p.msc = uint64(ev.Data.Data32[0])
+	p.next()
+}
+
+// ticker sends a client message with the number of ticks so far to the
+// window every Interval, until 'stop' is closed. The client messages are
+// handled by tick in the main event loop, so that frames are drawn there too.
+// Ticks that happen while the last message is still pending are skipped.
+func (p *Presenter) ticker(stop chan struct{}, start uint64) {
+	t := time.NewTicker(p.Interval)
+	defer t.Stop()
+
+	for n := int(start); ; {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			n++
+			if !atomic.CompareAndSwapInt32(&p.pending, 0, 1) {
+				continue
+			}
+			cm, err := xevent.NewClientMessage(32, p.Window, p.tickAtom, n)
+			if err != nil {
+				xgbutil.Logger.Printf("Could not create frame message: %s",
+					err)
+				return
+			}
+			xproto.SendEvent(p.X.Conn(), false, p.Window, 0,
+				string(cm.Bytes()))
+		}
+	}
+}
+
+// freeBuffers frees the images in the pool.
+func (p *Presenter) freeBuffers() {
+	for _, b := range p.buffers {
+		b.img.Destroy()
+	}
+	p.buffers = nil
+}
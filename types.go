@@ -155,6 +155,16 @@ type EventOrError struct {
 	Err   xgb.Error
 }
 
+// GenericDecodeFun is the type of function used to turn a raw event sent
+// through the X Generic Event Extension (an xevent.GenericEvent) into a typed
+// event value. It returns the typed event, the event type that callbacks for
+// it are attached to and the window it should be dispatched to.
+// It is exported for use in the xevent package and in sub-packages that
+// support extensions using generic events (like xinput). It should not be
+// used.
+type GenericDecodeFun func(xu *XUtil,
+	ev interface{}) (event interface{}, evtype int, win xproto.Window)
+
 // MouseDragFun is the kind of function used on each dragging step
 // and at the end of a drag.
 type MouseDragFun func(xu *XUtil, rootX, rootY, eventX, eventY int)
@@ -79,3 +79,85 @@ const (
 	Watch             = 150
 	XTerm             = 152
 )
+
+// glyphNames maps the names of the cursors in the cursor font (the names
+// used by cursor themes) to their glyphs.
+var glyphNames = map[string]uint16{
+	"X_cursor":            XCursor,
+	"arrow":               Arrow,
+	"based_arrow_down":    BasedArrowDown,
+	"based_arrow_up":      BasedArrowUp,
+	"boat":                Boat,
+	"bogosity":            Bogosity,
+	"bottom_left_corner":  BottomLeftCorner,
+	"bottom_right_corner": BottomRightCorner,
+	"bottom_side":         BottomSide,
+	"bottom_tee":          BottomTee,
+	"box_spiral":          BoxSpiral,
+	"center_ptr":          CenterPtr,
+	"circle":              Circle,
+	"clock":               Clock,
+	"coffee_mug":          CoffeeMug,
+	"cross":               Cross,
+	"cross_reverse":       CrossReverse,
+	"crosshair":           Crosshair,
+	"diamond_cross":       DiamondCross,
+	"dot":                 Dot,
+	"dotbox":              DotBoxMask,
+	"double_arrow":        DoubleArrow,
+	"draft_large":         DraftLarge,
+	"draft_small":         DraftSmall,
+	"draped_box":          DrapedBox,
+	"exchange":            Exchange,
+	"fleur":               Fleur,
+	"gobbler":             Gobbler,
+	"gumby":               Gumby,
+	"hand1":               Hand1,
+	"hand2":               Hand2,
+	"heart":               Heart,
+	"icon":                Icon,
+	"iron_cross":          IronCross,
+	"left_ptr":            LeftPtr,
+	"left_side":           LeftSide,
+	"left_tee":            LeftTee,
+	"leftbutton":          LeftButton,
+	"ll_angle":            LLAngle,
+	"lr_angle":            LRAngle,
+	"man":                 Man,
+	"middlebutton":        MiddleButton,
+	"mouse":               Mouse,
+	"pencil":              Pencil,
+	"pirate":              Pirate,
+	"plus":                Plus,
+	"question_arrow":      QuestionArrow,
+	"right_ptr":           RightPtr,
+	"right_side":          RightSide,
+	"right_tee":           RightTee,
+	"rightbutton":         RightButton,
+	"rtl_logo":            RtlLogo,
+	"sailboat":            Sailboat,
+	"sb_down_arrow":       SBDownArrow,
+	"sb_h_double_arrow":   SBHDoubleArrow,
+	"sb_left_arrow":       SBLeftArrow,
+	"sb_right_arrow":      SBRightArrow,
+	"sb_up_arrow":         SBUpArrow,
+	"sb_v_double_arrow":   SBVDoubleArrow,
+	"shuttle":             Shuttle,
+	"sizing":              Sizing,
+	"spider":              Spider,
+	"spraycan":            Spraycan,
+	"star":                Star,
+	"target":              Target,
+	"tcross":              TCross,
+	"top_left_arrow":      TopLeftArrow,
+	"top_left_corner":     TopLeftCorner,
+	"top_right_corner":    TopRightCorner,
+	"top_side":            TopSide,
+	"top_tee":             TopTee,
+	"trek":                Trek,
+	"ul_angle":            ULAngle,
+	"umbrella":            Umbrella,
+	"ur_angle":            URAngle,
+	"watch":               Watch,
+	"xterm":               XTerm,
+}
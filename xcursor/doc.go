@@ -1,11 +1,37 @@
 /*
 Package xcursor provides a small interface for using cursors that are
-predefined in the X 'cursor' font.
+predefined in the X 'cursor' font, and cursors from cursor themes.
 
 All available cursors are predefined in cursordef.go.
 
 Please see the 'change-cursor' example in the examples directory of the xgbutil
 package for an example of how to change the cursor when it enters a particular
 window.
+
+Cursor themes
+
+Most desktops use a cursor theme instead of the cursor font. Cursors in a
+theme are Xcursor files, which can have full color, translucent and animated
+cursors at several sizes. LoadCursor loads a cursor by name from the user's
+theme (see Theme and Size), and falls back to the cursor font if the theme
+doesn't have it:
+
+	cursor, err := xcursor.LoadCursor(X, "left_ptr")
+
+Cursors loaded by LoadCursor are cached, and should not be freed. Themed
+cursors need the RENDER extension, which almost every X server supports.
+
+Xcursor files can also be loaded directly with LoadFile, or read with Decode
+and created with CreateImageCursor.
+
+Cursors from images
+
+FromImage creates a cursor from an xgraphics.Image, so that cursors can be
+drawn at runtime:
+
+	cursor, err := xcursor.FromImage(img, img.Bounds().Dx()/2,
+		img.Bounds().Dy()/2)
+
+Without RENDER, the cursor is limited to two colors and no translucency.
 */
 package xcursor
@@ -0,0 +1,155 @@
+package xcursor
+
+/*
+xcursor/file.go contains a decoder for Xcursor files, which is the format of
+the cursors in cursor themes.
+
+An Xcursor file starts with a table of contents, which points to chunks of
+the file. Image chunks contain a single image of the cursor, with its hot spot,
+the nominal size it was drawn for and (for animated cursors) how long it is
+shown. A file usually has images for several sizes, and animated cursors have
+several images for each size. Pixels are 32 bit ARGB values with
+premultiplied alpha, in little-endian byte order.
+*/
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+const (
+	// fileMagic is the magic number at the start of every Xcursor file.
+	fileMagic = "Xcur"
+
+	// chunkImage is the type of image chunks. (Other chunks, like comments,
+	// are skipped.)
+	chunkImage = 0xfffd0002
+
+	// maxImageSize is the maximum width and height of an image in an Xcursor
+	// file.
+	maxImageSize = 0x7fff
+)
+
+// CursorImage is a single image of a cursor, at a single size.
+type CursorImage struct {
+	// Size is the nominal size that this image was drawn for. Its width and
+	// height may be different.
+	Size int
+
+	Width, Height int
+
+	// XHot and YHot is the hot spot of the cursor, relative to the top left
+	// corner of the image.
+	XHot, YHot int
+
+	// Delay is how long this image is shown before the next one, for animated
+	// cursors.
+	Delay time.Duration
+
+	// Pix contains the pixels of the image, row by row, as ARGB values with
+	// premultiplied alpha.
+	Pix []uint32
+}
+
+// Decode reads all of the images in an Xcursor file, in the order they appear
+// in the file. (Which is also the order of the frames of animated cursors.)
+func Decode(r io.Reader) ([]*CursorImage, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 16 || string(data[:4]) != fileMagic {
+		return nil, fmt.Errorf("Not an Xcursor file.")
+	}
+
+	le := binary.LittleEndian
+	headerSize, ntoc := le.Uint32(data[4:]), le.Uint32(data[12:])
+	if uint64(headerSize)+uint64(ntoc)*12 > uint64(len(data)) {
+		return nil, fmt.Errorf("The Xcursor file is too short for its " +
+			"table of contents.")
+	}
+
+	var images []*CursorImage
+	for i := uint32(0); i < ntoc; i++ {
+		toc := data[headerSize+i*12:]
+		if le.Uint32(toc) != chunkImage {
+			continue
+		}
+		img, err := decodeImage(data, le.Uint32(toc[8:]))
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf("The Xcursor file has no images.")
+	}
+	return images, nil
+}
+
+// decodeImage reads the image chunk at 'pos'.
+func decodeImage(data []byte, pos uint32) (*CursorImage, error) {
+	le := binary.LittleEndian
+	if uint64(pos)+36 > uint64(len(data)) {
+		return nil, fmt.Errorf("Image chunk at %d is out of bounds.", pos)
+	}
+	chunk := data[pos:]
+	headerSize := le.Uint32(chunk)
+	if le.Uint32(chunk[4:]) != chunkImage || headerSize < 36 {
+		return nil, fmt.Errorf("Invalid image chunk at %d.", pos)
+	}
+
+	img := &CursorImage{
+		Size:   int(le.Uint32(chunk[8:])),
+		Width:  int(le.Uint32(chunk[16:])),
+		Height: int(le.Uint32(chunk[20:])),
+		XHot:   int(le.Uint32(chunk[24:])),
+		YHot:   int(le.Uint32(chunk[28:])),
+		Delay:  time.Duration(le.Uint32(chunk[32:])) * time.Millisecond,
+	}
+	if img.Width > maxImageSize || img.Height > maxImageSize ||
+		img.XHot > img.Width || img.YHot > img.Height {
+
+		return nil, fmt.Errorf("Invalid image chunk at %d.", pos)
+	}
+
+	n := img.Width * img.Height
+	if uint64(headerSize)+uint64(n)*4 > uint64(len(chunk)) {
+		return nil, fmt.Errorf("Image chunk at %d is too short.", pos)
+	}
+	pix := chunk[headerSize:]
+	img.Pix = make([]uint32, n)
+	for i := range img.Pix {
+		img.Pix[i] = le.Uint32(pix[i*4:])
+	}
+	return img, nil
+}
+
+// BestSize returns the images (more than one for animated cursors) with the
+// nominal size closest to 'size'.
+func BestSize(images []*CursorImage, size int) []*CursorImage {
+	best := -1
+	for _, img := range images {
+		if best < 0 || abs(img.Size-size) < abs(best-size) {
+			best = img.Size
+		}
+	}
+
+	var frames []*CursorImage
+	for _, img := range images {
+		if img.Size == best {
+			frames = append(frames, img)
+		}
+	}
+	return frames
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,136 @@
+package xcursor
+
+/*
+xcursor/image.go contains FromImage, for creating cursors from images drawn
+at runtime (like a crosshair for a color picker, or an icon dragged around
+with mousebind.Drag).
+
+When the X server supports RENDER, the cursor is an ARGB cursor that looks
+exactly like the image. Otherwise, it is a core cursor, which can only have
+two colors and no translucency. Core cursors are made of two bitmaps: a mask
+of the pixels that are drawn, and a source that picks which of the two
+colors each of them is drawn with.
+*/
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil/xgraphics"
+)
+
+// FromImage creates a cursor from the image given, with its hot spot (the
+// point of the cursor that is at the pointer's position) at (hotX, hotY),
+// relative to the top left corner of the image.
+//
+// If the X server doesn't support ARGB cursors (or the image can't be sent
+// to it as one), a two color cursor is created instead. Pixels with an alpha
+// value of at least 128 are drawn: dark pixels with the average color of the
+// dark pixels, and light pixels with the average color of the light pixels.
+// Other pixels are transparent.
+//
+// FromImage can be called on sub-images. The caller is responsible for
+// freeing the cursor.
+func FromImage(img *xgraphics.Image, hotX, hotY int) (xproto.Cursor, error) {
+	r := img.Bounds()
+	if r.Empty() {
+		return 0, fmt.Errorf("FromImage: The image is empty.")
+	}
+	if hotX < 0 || hotY < 0 || hotX >= r.Dx() || hotY >= r.Dy() {
+		return 0, fmt.Errorf("FromImage: The hot spot (%d, %d) is outside "+
+			"of the %dx%d image.", hotX, hotY, r.Dx(), r.Dy())
+	}
+
+	if renderSupported(img.X) {
+		if cursor, err := argbCursor(img, hotX, hotY); err == nil {
+			return cursor, nil
+		}
+	}
+	return bitmapCursor(img, hotX, hotY)
+}
+
+// bitmapCursor creates a two color core cursor from the image given.
+func bitmapCursor(img *xgraphics.Image, hotX,
+	hotY int) (xproto.Cursor, error) {
+
+	mask, err := img.CreateBitmap(isOpaque)
+	if err != nil {
+		return 0, err
+	}
+	defer xgraphics.FreePixmap(img.X, mask)
+
+	source, err := img.CreateBitmap(func(c xgraphics.BGRA) bool {
+		return isOpaque(c) && isDark(c)
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer xgraphics.FreePixmap(img.X, source)
+
+	fore, back := cursorColors(img)
+	cid, err := xproto.NewCursorId(img.X.Conn())
+	if err != nil {
+		return 0, err
+	}
+	err = xproto.CreateCursorChecked(img.X.Conn(), cid, source, mask,
+		fore[0], fore[1], fore[2], back[0], back[1], back[2],
+		uint16(hotX), uint16(hotY)).Check()
+	if err != nil {
+		return 0, err
+	}
+	return cid, nil
+}
+
+// cursorColors returns the average colors (as 16 bit RGB values) of the
+// opaque dark pixels and of the opaque light pixels of the image. If there
+// are none, the colors are black and white.
+func cursorColors(img *xgraphics.Image) (fore, back [3]uint16) {
+	var sums [2][4]uint64
+	r := img.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := img.At(x, y).(xgraphics.BGRA)
+			if !isOpaque(c) {
+				continue
+			}
+			i := 1
+			if isDark(c) {
+				i = 0
+			}
+			sums[i][0] += uint64(c.R)
+			sums[i][1] += uint64(c.G)
+			sums[i][2] += uint64(c.B)
+			sums[i][3]++
+		}
+	}
+
+	fore, back = [3]uint16{0, 0, 0}, [3]uint16{0xffff, 0xffff, 0xffff}
+	for i, sum := range sums {
+		if sum[3] == 0 {
+			continue
+		}
+		var rgb [3]uint16
+		for j := range rgb {
+			rgb[j] = uint16((sum[j] + sum[3]/2) / sum[3] * 0x101)
+		}
+		if i == 0 {
+			fore = rgb
+		} else {
+			back = rgb
+		}
+	}
+	return fore, back
+}
+
+// isOpaque returns whether a pixel is drawn in a two color cursor.
+func isOpaque(c xgraphics.BGRA) bool {
+	return c.A >= 0x80
+}
+
+// isDark returns whether a pixel is drawn with the foreground color in a two
+// color cursor. (Like EncodeXbm in xgraphics.)
+func isDark(c xgraphics.BGRA) bool {
+	gray := (299*uint32(c.R) + 587*uint32(c.G) + 114*uint32(c.B)) / 1000
+	return gray < 0x80
+}
@@ -0,0 +1,174 @@
+package xcursor
+
+/*
+xcursor/render.go contains functions for creating ARGB cursors (which can
+have any size and colors, and can be translucent) with the RENDER extension.
+
+Each image of a cursor is sent to X as a picture, from which the cursor is
+created. Animated cursors are created from the cursors of each of their
+frames. ARGB cursors need RENDER 0.5, and animated cursors need RENDER 0.8.
+On older servers, only the first frame of animated cursors is used.
+*/
+
+import (
+	"fmt"
+	"image"
+	"sync"
+
+	"github.com/BurntSushi/xgb/render"
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xgraphics"
+)
+
+// renderVersion is the version of RENDER supported by an X server.
+type renderVersion struct {
+	major, minor uint32
+}
+
+// atLeast returns whether the version is at least major.minor.
+func (v renderVersion) atLeast(major, minor uint32) bool {
+	return v.major > major || (v.major == major && v.minor >= minor)
+}
+
+// renderVersions caches the version of RENDER for each connection. A zero
+// version means RENDER isn't supported.
+var (
+	renderVersions    = make(map[*xgbutil.XUtil]renderVersion)
+	renderVersionsLck = &sync.Mutex{}
+)
+
+// CreateImageCursor creates an ARGB cursor from the images given. If there is
+// more than one image, an animated cursor is created, where each image is
+// shown for its Delay. (See Decode and BestSize for getting the images from
+// an Xcursor file.)
+// An error is returned if the X server doesn't support the RENDER extension.
+// The caller is responsible for freeing the cursor.
+func CreateImageCursor(xu *xgbutil.XUtil,
+	images ...*CursorImage) (xproto.Cursor, error) {
+
+	if len(images) == 0 {
+		return 0, fmt.Errorf("CreateImageCursor: No images were given.")
+	}
+	version := getRenderVersion(xu)
+	if !version.atLeast(0, 5) {
+		return 0, fmt.Errorf("CreateImageCursor: The X server doesn't " +
+			"support ARGB cursors.")
+	}
+	if len(images) == 1 || !version.atLeast(0, 8) {
+		return frameCursor(xu, images[0])
+	}
+
+	frames := make([]render.Animcursorelt, 0, len(images))
+	defer func() {
+		// The animated cursor keeps its own references to the frames.
+		for _, frame := range frames {
+			xproto.FreeCursor(xu.Conn(), frame.Cursor)
+		}
+	}()
+	for _, img := range images {
+		cursor, err := frameCursor(xu, img)
+		if err != nil {
+			return 0, err
+		}
+		frames = append(frames, render.Animcursorelt{
+			Cursor: cursor,
+			Delay:  uint32(img.Delay.Nanoseconds() / 1e6),
+		})
+	}
+
+	cid, err := xproto.NewCursorId(xu.Conn())
+	if err != nil {
+		return 0, err
+	}
+	err = render.CreateAnimCursorChecked(xu.Conn(), cid, frames).Check()
+	if err != nil {
+		return 0, err
+	}
+	return cid, nil
+}
+
+// frameCursor creates a (still) ARGB cursor from a single image.
+func frameCursor(xu *xgbutil.XUtil, img *CursorImage) (xproto.Cursor, error) {
+	if img.Width <= 0 || img.Height <= 0 ||
+		len(img.Pix) < img.Width*img.Height {
+
+		return 0, fmt.Errorf("Invalid %dx%d cursor image.",
+			img.Width, img.Height)
+	}
+
+	// xgraphics images don't have premultiplied alpha, but XPicture
+	// premultiplies them again when they are sent to X.
+	xim := xgraphics.New(xu, image.Rect(0, 0, img.Width, img.Height))
+	xim.ARGB = true
+	for i, argb := range img.Pix[:img.Width*img.Height] {
+		a := uint8(argb >> 24)
+		xim.Pix[4*i+0] = unpremultiply(uint8(argb), a)
+		xim.Pix[4*i+1] = unpremultiply(uint8(argb>>8), a)
+		xim.Pix[4*i+2] = unpremultiply(uint8(argb>>16), a)
+		xim.Pix[4*i+3] = a
+	}
+
+	return argbCursor(xim, img.XHot, img.YHot)
+}
+
+// argbCursor creates an ARGB cursor from an xgraphics image, with the hot
+// spot at (xhot, yhot) relative to the top left corner of the image.
+func argbCursor(xim *xgraphics.Image, xhot, yhot int) (xproto.Cursor, error) {
+	// XPicture returns an error if the pixels couldn't be sent, so that
+	// callers can fall back to another cursor instead of a blank one.
+	pic, err := xim.XPicture()
+	if err != nil {
+		return 0, err
+	}
+	defer pic.Destroy()
+
+	cid, err := xproto.NewCursorId(xim.X.Conn())
+	if err != nil {
+		return 0, err
+	}
+	err = render.CreateCursorChecked(xim.X.Conn(), cid, pic.Id,
+		uint16(xhot), uint16(yhot)).Check()
+	if err != nil {
+		return 0, err
+	}
+	return cid, nil
+}
+
+// unpremultiply divides a premultiplied color component by alpha.
+func unpremultiply(c, a uint8) uint8 {
+	if a == 0 {
+		return 0
+	}
+	if c >= a {
+		return 0xff
+	}
+	return uint8((uint32(c)*0xff + uint32(a)/2) / uint32(a))
+}
+
+// renderSupported returns whether the X server supports ARGB cursors.
+func renderSupported(xu *xgbutil.XUtil) bool {
+	return getRenderVersion(xu).atLeast(0, 5)
+}
+
+// getRenderVersion returns the version of RENDER supported by the X server,
+// initializing the RENDER extension if necessary.
+func getRenderVersion(xu *xgbutil.XUtil) renderVersion {
+	renderVersionsLck.Lock()
+	defer renderVersionsLck.Unlock()
+
+	if version, ok := renderVersions[xu]; ok {
+		return version
+	}
+
+	var version renderVersion
+	if err := render.Init(xu.Conn()); err == nil {
+		reply, err := render.QueryVersion(xu.Conn(), 0, 11).Reply()
+		if err == nil {
+			version = renderVersion{reply.MajorVersion, reply.MinorVersion}
+		}
+	}
+	renderVersions[xu] = version
+	return version
+}
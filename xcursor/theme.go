@@ -0,0 +1,297 @@
+package xcursor
+
+/*
+xcursor/theme.go contains functions for loading cursors from cursor themes,
+the same way Xlib (with libXcursor) does.
+
+A cursor theme is a directory with an index.theme file and a 'cursors'
+directory, which has an Xcursor file for each cursor, named after the cursor
+(like 'left_ptr' or 'xterm'). Themes are searched for in each directory of
+the cursor path (see Path). A theme can inherit cursors from other themes,
+which are listed in the Inherits key of its index.theme.
+
+The theme and the size of cursors are chosen by the user, either with the
+XCURSOR_THEME and XCURSOR_SIZE environment variables, or with the
+Xcursor.theme and Xcursor.size resources in the RESOURCE_MANAGER property
+of the root window (which is set by xrdb).
+*/
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xprop"
+)
+
+// defaultPath is the cursor path used when XCURSOR_PATH isn't set. It is the
+// same as libXcursor's.
+var defaultPath = []string{
+	"~/.local/share/icons",
+	"~/.icons",
+	"/usr/share/icons",
+	"/usr/share/pixmaps",
+}
+
+// maxInherits is how deep themes can inherit from other themes. It guards
+// against themes that inherit from each other.
+const maxInherits = 16
+
+// cursorKey identifies a cursor in the cursors map.
+type cursorKey struct {
+	xu   *xgbutil.XUtil
+	name string
+}
+
+// cursors caches the cursors loaded by LoadCursor.
+var (
+	cursors    = make(map[cursorKey]xproto.Cursor)
+	cursorsLck = &sync.Mutex{}
+)
+
+// LoadCursor returns the cursor named 'name' (like "left_ptr" or "xterm")
+// from the current cursor theme (see Theme), at the current cursor size
+// (see Size). Animated cursors are supported.
+//
+// If the cursor can't be found in the theme or can't be sent to the X server
+// (like when it doesn't support the RENDER extension), the cursor with the
+// same name in the cursor font is used instead. (If there is one. See
+// cursordef.go.)
+//
+// Cursors are cached for each connection, so loading the same cursor twice
+// is cheap. The cursor returned should not be freed.
+func LoadCursor(xu *xgbutil.XUtil, name string) (xproto.Cursor, error) {
+	cursorsLck.Lock()
+	defer cursorsLck.Unlock()
+
+	key := cursorKey{xu, name}
+	if cursor, ok := cursors[key]; ok {
+		return cursor, nil
+	}
+
+	cursor, err := loadCursor(xu, name)
+	if err != nil {
+		return 0, err
+	}
+	cursors[key] = cursor
+	return cursor, nil
+}
+
+// loadCursor creates the cursor named 'name', from the cursor theme or the
+// cursor font.
+func loadCursor(xu *xgbutil.XUtil, name string) (xproto.Cursor, error) {
+	themeErr := fmt.Errorf("The X server doesn't support ARGB cursors.")
+	if renderSupported(xu) {
+		var fileName string
+		fileName, themeErr = FindCursorFile(Theme(xu), name)
+		if themeErr == nil {
+			cursor, err := LoadFile(xu, fileName, Size(xu))
+			if err == nil {
+				return cursor, nil
+			}
+			themeErr = err
+		}
+	}
+
+	glyph, ok := glyphNames[name]
+	if !ok {
+		return 0, fmt.Errorf("Could not load cursor '%s': %s", name, themeErr)
+	}
+	return CreateCursor(xu, glyph)
+}
+
+// LoadFile creates a cursor from the Xcursor file given, using the images
+// with the nominal size closest to 'size'. If there is more than one image
+// at that size, an animated cursor is created.
+// The caller is responsible for freeing the cursor.
+func LoadFile(xu *xgbutil.XUtil, fileName string,
+	size int) (xproto.Cursor, error) {
+
+	file, err := os.Open(fileName)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	images, err := Decode(file)
+	if err != nil {
+		return 0, fmt.Errorf("Could not read '%s': %s", fileName, err)
+	}
+	return CreateImageCursor(xu, BestSize(images, size)...)
+}
+
+// FindCursorFile returns the file name of the Xcursor file for the cursor
+// named 'name' in the theme given, or in the themes it inherits from. If the
+// cursor isn't in any of them, the "default" theme is searched too.
+func FindCursorFile(theme, name string) (string, error) {
+	if fileName := findCursorFile(theme, name, 0); fileName != "" {
+		return fileName, nil
+	}
+	if theme != "default" {
+		if fileName := findCursorFile("default", name, 0); fileName != "" {
+			return fileName, nil
+		}
+	}
+	return "", fmt.Errorf("Could not find cursor '%s' in theme '%s'.",
+		name, theme)
+}
+
+// findCursorFile searches for the cursor in the theme given, and then in the
+// themes it inherits from. (Themes inherit from the themes in the first
+// index.theme found on the cursor path.) An empty string is returned if the
+// cursor couldn't be found.
+func findCursorFile(theme, name string, depth int) string {
+	if depth > maxInherits || theme == "" {
+		return ""
+	}
+
+	var inherits []string
+	haveIndex := false
+	for _, dir := range Path() {
+		themeDir := path.Join(dir, theme)
+		fileName := path.Join(themeDir, "cursors", name)
+		if info, err := os.Stat(fileName); err == nil && !info.IsDir() {
+			return fileName
+		}
+		if !haveIndex {
+			inherits, haveIndex = themeInherits(
+				path.Join(themeDir, "index.theme"))
+		}
+	}
+	for _, parent := range inherits {
+		if parent == theme {
+			continue
+		}
+		if fileName := findCursorFile(parent, name, depth+1); fileName != "" {
+			return fileName
+		}
+	}
+	return ""
+}
+
+// themeInherits reads the themes listed in the Inherits key of an
+// index.theme file. It returns false if the file couldn't be read.
+func themeInherits(fileName string) ([]string, bool) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+
+	var inherits []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "Inherits") {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) != "Inherits" {
+			continue
+		}
+		for _, theme := range strings.FieldsFunc(kv[1], isThemeSeparator) {
+			inherits = append(inherits, theme)
+		}
+	}
+	return inherits, true
+}
+
+// isThemeSeparator returns whether 'r' separates themes in an Inherits key.
+// (libXcursor accepts commas, semicolons and spaces.)
+func isThemeSeparator(r rune) bool {
+	return r == ',' || r == ';' || r == ' ' || r == '\t'
+}
+
+// Path returns the directories that cursor themes are searched for in. They
+// are taken from the colon separated XCURSOR_PATH environment variable, or
+// are ~/.local/share/icons, ~/.icons, /usr/share/icons and /usr/share/pixmaps
+// if it isn't set.
+func Path() []string {
+	dirs := defaultPath
+	if env := os.Getenv("XCURSOR_PATH"); env != "" {
+		dirs = strings.Split(env, ":")
+	}
+
+	home := os.Getenv("HOME")
+	paths := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		if strings.HasPrefix(dir, "~/") {
+			if home == "" {
+				continue
+			}
+			dir = path.Join(home, dir[2:])
+		}
+		if dir != "" {
+			paths = append(paths, dir)
+		}
+	}
+	return paths
+}
+
+// Theme returns the name of the current cursor theme. It is the value of the
+// XCURSOR_THEME environment variable, or the Xcursor.theme resource if that
+// isn't set, or "default" if neither is set.
+func Theme(xu *xgbutil.XUtil) string {
+	if theme := os.Getenv("XCURSOR_THEME"); theme != "" {
+		return theme
+	}
+	if theme := resource(xu, "Xcursor.theme"); theme != "" {
+		return theme
+	}
+	return "default"
+}
+
+// Size returns the current size of cursors, in pixels. It is the value of the
+// XCURSOR_SIZE environment variable, or the Xcursor.size resource if that
+// isn't set. Otherwise, it is computed from the Xft.dpi resource, or from the
+// size of the screen. (Like libXcursor.)
+func Size(xu *xgbutil.XUtil) int {
+	if size, err := strconv.Atoi(os.Getenv("XCURSOR_SIZE")); err == nil &&
+		size > 0 {
+
+		return size
+	}
+	if size, err := strconv.Atoi(resource(xu, "Xcursor.size")); err == nil &&
+		size > 0 {
+
+		return size
+	}
+	if dpi, err := strconv.Atoi(resource(xu, "Xft.dpi")); err == nil &&
+		dpi > 0 {
+
+		return dpi * 16 / 72
+	}
+
+	screen := xu.Screen()
+	dim := screen.WidthInPixels
+	if screen.HeightInPixels < dim {
+		dim = screen.HeightInPixels
+	}
+	return int(dim) / 48
+}
+
+// resource returns the value of the resource named 'name' in the
+// RESOURCE_MANAGER property of the root window, or an empty string if it
+// isn't there. Only resources with exactly that name are found. (Patterns
+// like '*size' aren't matched.)
+func resource(xu *xgbutil.XUtil, name string) string {
+	db, err := xprop.PropValStr(xprop.GetProperty(xu, xu.RootWin(),
+		"RESOURCE_MANAGER"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(db, "\n") {
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) == 2 && strings.TrimSpace(kv[0]) == name {
+			return strings.TrimSpace(kv[1])
+		}
+	}
+	return ""
+}
@@ -58,6 +58,16 @@ from all possible event handlers in xgbutil, use something like:
 	keybind.Detach(XUtilValue, your-window-id)
 	mousebind.Detach(XUtilValue, your-window-id)
 
+Generic events
+
+Some extensions, like XInput 2, send their events through the X Generic Event
+Extension. These events are read into xevent.GenericEvent values and decoded
+by a function registered by the package in xgbutil that supports the
+extension. (For XInput 2, this is done by xinput.Init.) The decoded events
+are then dispatched to callbacks just like core events, so callbacks are
+attached with the callback types defined in that package. For example,
+xinput.RawMotionFun.
+
 Quick example
 
 A small example that shows how to respond to ConfigureNotify events sent to
@@ -275,6 +275,8 @@ func processEventQueue(xu *xgbutil.XUtil, pingBefore, pingAfter chan struct{}) {
 		case shape.NotifyEvent:
 			e := ShapeNotifyEvent{&event}
 			runCallbacks(xu, e, ShapeNotify, e.AffectedWindow)
+		case GenericEvent:
+			runGenericCallbacks(xu, event)
 		default:
 			if event != nil {
 				xgbutil.Logger.Printf("ERROR: UNSUPPORTED EVENT TYPE: %T",
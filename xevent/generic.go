@@ -0,0 +1,146 @@
+package xevent
+
+/*
+xevent/generic.go contains support for events sent through the X Generic Event
+Extension (XGE).
+
+Generic events all share the same core event number (35) and are told apart
+by the major opcode of the extension that sent them and an extension specific
+event type. XGB does not know how to decode them, so xevent reads them into a
+GenericEvent value and hands them to a decoder registered by the extension's
+package in xgbutil (like xinput). The decoded event is then dispatched to
+callbacks just like any core event.
+
+XGB also only reads the first 32 bytes of long generic events. The rest is
+read by the connection made by xgbutil.NewConnDisplay, and is put back
+together with the first 32 bytes by NewGenericEvent.
+*/
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+)
+
+// GenericEvent is the raw representation of an event sent through the X
+// Generic Event Extension. Extension is the major opcode of the extension
+// that sent the event and EvType is the extension specific event type.
+// Data contains the complete wire representation of the event, including
+// the 32 byte header.
+//
+// N.B. XGB reads exactly 32 bytes for every event it receives. Generic events
+// with a non-zero Length carry 4 * Length more bytes, which only end up in
+// Data on connections made with xgbutil.NewConn or xgbutil.NewConnDisplay.
+// (See xgbutil.XUtil.GenericEventsComplete.)
+type GenericEvent struct {
+	Sequence  uint16
+	Extension byte
+	Length    uint32
+	EvType    uint16
+	Data      []byte
+}
+
+// GenericEventCode is the core event number of every generic event.
+const GenericEventCode = xproto.GeGeneric
+
+// NewGenericEvent constructs a GenericEvent value from the raw bytes of a
+// generic event. It satisfies xgb.NewEventFun and replaces the constructor
+// XGB uses by default, which throws away everything but the sequence number.
+func NewGenericEvent(buf []byte) xgb.Event {
+	data := xgbutil.GenericEventBytes(buf)
+	if len(data) == len(buf) {
+		data = make([]byte, len(buf))
+		copy(data, buf)
+	}
+
+	return GenericEvent{
+		Extension: data[1],
+		Sequence:  xgb.Get16(data[2:]),
+		Length:    xgb.Get32(data[4:]),
+		EvType:    xgb.Get16(data[8:]),
+		Data:      data,
+	}
+}
+
+// Bytes returns the raw bytes of the generic event.
+func (ev GenericEvent) Bytes() []byte {
+	return ev.Data
+}
+
+// SequenceId returns the sequence id attached to the generic event.
+func (ev GenericEvent) SequenceId() uint16 {
+	return ev.Sequence
+}
+
+func (ev GenericEvent) String() string {
+	return fmt.Sprintf("GenericEvent {Sequence: %d, Extension: %d, "+
+		"Length: %d, EvType: %d}",
+		ev.Sequence, ev.Extension, ev.Length, ev.EvType)
+}
+
+func init() {
+	xgb.NewEventFuncs[GenericEventCode] = NewGenericEvent
+}
+
+// GenericType returns the event type that callbacks for events of type
+// 'evtype' sent by the extension with major opcode 'extension' are
+// attached to. Core event types are all less than 128, so these never
+// collide with them.
+func GenericType(extension byte, evtype uint16) int {
+	return 1<<24 | int(extension)<<16 | int(evtype)
+}
+
+// GenericDecoderSet registers the function used to decode generic events
+// sent by the extension with major opcode 'extension'. Events from an
+// extension without a decoder are reported as unsupported by the main event
+// loop.
+// This should be called when an extension is initialized. (It is done
+// automatically by xinput.Init, for example.)
+func GenericDecoderSet(xu *xgbutil.XUtil, extension byte,
+	fun xgbutil.GenericDecodeFun) {
+
+	xu.GenericsLck.Lock()
+	defer xu.GenericsLck.Unlock()
+
+	xu.Generics[extension] = fun
+}
+
+// ConnectGeneric attaches a callback to events of type 'evtype' sent by the
+// extension with major opcode 'extension' on the window given.
+// It is exported for use in sub-packages that define callback types for
+// generic events (like xinput). You should use those instead.
+func ConnectGeneric(xu *xgbutil.XUtil, extension byte, evtype uint16,
+	win xproto.Window, fun xgbutil.Callback) {
+
+	attachCallback(xu, GenericType(extension, evtype), win, fun)
+}
+
+// DetachGeneric removes a single callback attached with ConnectGeneric, like
+// DetachCallback.
+func DetachGeneric(xu *xgbutil.XUtil, extension byte, evtype uint16,
+	win xproto.Window, fun xgbutil.Callback) {
+
+	DetachCallback(xu, GenericType(extension, evtype), win, fun)
+}
+
+// runGenericCallbacks decodes a generic event with the decoder registered
+// for its extension and executes every callback attached to the result.
+func runGenericCallbacks(xu *xgbutil.XUtil, ev GenericEvent) {
+	xu.GenericsLck.RLock()
+	decode, ok := xu.Generics[ev.Extension]
+	xu.GenericsLck.RUnlock()
+
+	if !ok {
+		xgbutil.Logger.Printf("ERROR: UNSUPPORTED GENERIC EVENT: %s", ev)
+		return
+	}
+
+	event, evtype, win := decode(xu, ev)
+	if event == nil {
+		return
+	}
+	runCallbacks(xu, event, evtype, win)
+}
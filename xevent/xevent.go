@@ -1,6 +1,8 @@
 package xevent
 
 import (
+	"reflect"
+
 	"github.com/BurntSushi/xgb"
 	"github.com/BurntSushi/xgb/xproto"
 
@@ -224,6 +226,42 @@ func Detach(xu *xgbutil.XUtil, win xproto.Window) {
 	}
 }
 
+// ConnectCallback attaches a callback to events of type 'evtype' (one of the
+// event constants, like ClientMessage) on the window given. It is like the
+// Connect method of the callback types, except that 'fun' can be any
+// Callback. Connecting a pointer to a callback function (instead of the
+// function itself) allows it to be detached later with DetachCallback.
+func ConnectCallback(xu *xgbutil.XUtil, evtype int, win xproto.Window,
+	fun xgbutil.Callback) {
+
+	attachCallback(xu, evtype, win, fun)
+}
+
+// DetachCallback removes a single callback from events of type 'evtype' on
+// the window given, and leaves the other callbacks of the window alone.
+// Callbacks are compared with ==, so only callbacks of comparable types (like
+// pointers) can be detached. (See ConnectCallback.)
+func DetachCallback(xu *xgbutil.XUtil, evtype int, win xproto.Window,
+	fun xgbutil.Callback) {
+
+	xu.CallbacksLck.Lock()
+	defer xu.CallbacksLck.Unlock()
+
+	cbs := xu.Callbacks[evtype][win]
+	newCallbacks := make([]xgbutil.Callback, 0, len(cbs))
+	for _, cb := range cbs {
+		if reflect.TypeOf(cb).Comparable() && cb == fun {
+			continue
+		}
+		newCallbacks = append(newCallbacks, cb)
+	}
+	if len(newCallbacks) == 0 {
+		delete(xu.Callbacks[evtype], win)
+		return
+	}
+	xu.Callbacks[evtype][win] = newCallbacks
+}
+
 // SendRootEvent takes a type implementing the xgb.Event interface, converts it
 // to raw X bytes, and sends it to the root window using the SendEvent request.
 func SendRootEvent(xu *xgbutil.XUtil, ev xgb.Event, evMask uint32) error {
@@ -71,6 +71,18 @@ type XUtil struct {
 	Callbacks    map[int]map[xproto.Window][]Callback
 	CallbacksLck *sync.RWMutex
 
+	// Generics is a map of extension major opcodes to functions that decode
+	// events sent through the X Generic Event Extension. Extensions that use
+	// generic events (like XInput 2) register a decoder here when they are
+	// initialized.
+	// It is exported for use in the xevent package. Do not use it.
+	Generics    map[byte]GenericDecodeFun
+	GenericsLck *sync.RWMutex
+
+	// genericsComplete is true if the connection reads generic events
+	// completely. (See GenericEventsComplete.)
+	genericsComplete bool
+
 	// Hooks are called by the XEvent main loop before processing the event
 	// itself. These are meant for instances when it's not possible / easy
 	// to use the normal Hook system. You should not modify this yourself.
@@ -188,15 +200,25 @@ func NewConn() (*XUtil, error) {
 //	NewConn("/tmp/launch-12/:0") -> net.Dial("unix", "", "/tmp/launch-12/:0")
 //	NewConn("hostname:2.1") -> net.Dial("tcp", "", "hostname:6002")
 //	NewConn("tcp/hostname:1.0") -> net.Dial("tcp", "", "hostname:6001")
+//
+// Unlike connections made by xgb.NewConnDisplay, these connections read
+// events sent through the X Generic Event Extension completely. (See
+// GenericEventsComplete.) The only exception is when the X authority entry
+// for the display isn't an MIT-MAGIC-COOKIE-1, in which case the connection
+// is made by xgb.NewConnDisplay.
 func NewConnDisplay(display string) (*XUtil, error) {
-	c, err := xgb.NewConnDisplay(display)
+	c, complete, err := newXgbConn(display)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return NewConnXgb(c)
-
+	xu, err := NewConnXgb(c)
+	if err != nil {
+		return nil, err
+	}
+	xu.genericsComplete = complete
+	return xu, nil
 }
 
 // NewConnXgb use the specific xgb.Conn to create a new XUtil.
@@ -222,6 +244,8 @@ func NewConnXgb(c *xgb.Conn) (*XUtil, error) {
 		AtomNamesLck:     &sync.RWMutex{},
 		Callbacks:        make(map[int]map[xproto.Window][]Callback, 33),
 		CallbacksLck:     &sync.RWMutex{},
+		Generics:         make(map[byte]GenericDecodeFun, 2),
+		GenericsLck:      &sync.RWMutex{},
 		Hooks:            make([]CallbackHook, 0),
 		HooksLck:         &sync.RWMutex{},
 		Keymap:           nil, // we don't have anything yet
@@ -280,6 +304,16 @@ func (xu *XUtil) Conn() *xgb.Conn {
 	return xu.conn
 }
 
+// GenericEventsComplete returns true if events sent through the X Generic
+// Event Extension (like XInput 2 and Present events) are read completely.
+// This is only the case for connections made with NewConn or NewConnDisplay:
+// XGB on its own reads 32 bytes of every event, and the connection breaks
+// as soon as it receives a longer generic event. Packages that use such
+// events check this before selecting them.
+func (xu *XUtil) GenericEventsComplete() bool {
+	return xu.genericsComplete
+}
+
 // ExtInitialized returns true if an extension has been initialized.
 // This is useful for determining whether an extension is available or not.
 func (xu *XUtil) ExtInitialized(extName string) bool {
@@ -7,6 +7,9 @@ interfaces. Wow.
 
 Note that these functions assume that the source and destination are precisely
 the same size.
+
+Large images are converted in bands of rows by multiple goroutines. (See
+parallel.go.)
 */
 
 import (
@@ -16,6 +19,8 @@ import (
 
 // convertImage converts any image implementing the image.Image interface to
 // an xgraphics.Image type. This is *slow*.
+// It isn't split up between goroutines, since some image types may not
+// be safe to read from multiple goroutines.
 func convertImage(dest *Image, src image.Image) {
 	var r, g, b, a uint32
 	var x, y, i int
@@ -37,84 +42,123 @@ func convertXImage(dest *Image, src *Image) {
 }
 
 func convertYCbCr(dest *Image, src *image.YCbCr) {
-	var r, g, b uint8
-	var x, y, i, yi, ci int
-
-	for x = dest.Rect.Min.X; x < dest.Rect.Max.X; x++ {
-		for y = dest.Rect.Min.Y; y < dest.Rect.Max.Y; y++ {
-			yi, ci = src.YOffset(x, y), src.COffset(x, y)
-			r, g, b = color.YCbCrToRGB(src.Y[yi], src.Cb[ci], src.Cr[ci])
-			i = dest.PixOffset(x, y)
-			dest.Pix[i+0] = b
-			dest.Pix[i+1] = g
-			dest.Pix[i+2] = r
-			dest.Pix[i+3] = 0xff
+	parallel(dest.Rect, func(band image.Rectangle) {
+		var r, g, b uint8
+		var x, y, i, yi, ci int
+
+		for y = band.Min.Y; y < band.Max.Y; y++ {
+			for x = band.Min.X; x < band.Max.X; x++ {
+				yi, ci = src.YOffset(x, y), src.COffset(x, y)
+				r, g, b = color.YCbCrToRGB(src.Y[yi], src.Cb[ci], src.Cr[ci])
+				i = dest.PixOffset(x, y)
+				dest.Pix[i+0] = b
+				dest.Pix[i+1] = g
+				dest.Pix[i+2] = r
+				dest.Pix[i+3] = 0xff
+			}
 		}
-	}
+	})
 }
 
 func convertRGBA(dest *Image, src *image.RGBA) {
-	var x, y, i, si int
-
-	for x = dest.Rect.Min.X; x < dest.Rect.Max.X; x++ {
-		for y = dest.Rect.Min.Y; y < dest.Rect.Max.Y; y++ {
-			si = src.PixOffset(x, y)
-			i = dest.PixOffset(x, y)
-			dest.Pix[i+0] = src.Pix[si+2]
-			dest.Pix[i+1] = src.Pix[si+1]
-			dest.Pix[i+2] = src.Pix[si+0]
-			dest.Pix[i+3] = src.Pix[si+3]
+	parallel(dest.Rect, func(band image.Rectangle) {
+		var x, y, i, si int
+
+		for y = band.Min.Y; y < band.Max.Y; y++ {
+			for x = band.Min.X; x < band.Max.X; x++ {
+				si = src.PixOffset(x, y)
+				i = dest.PixOffset(x, y)
+				dest.Pix[i+0] = src.Pix[si+2]
+				dest.Pix[i+1] = src.Pix[si+1]
+				dest.Pix[i+2] = src.Pix[si+0]
+				dest.Pix[i+3] = src.Pix[si+3]
+			}
 		}
-	}
+	})
 }
 
 func convertRGBA64(dest *Image, src *image.RGBA64) {
-	var x, y, i, si int
-
-	for x = dest.Rect.Min.X; x < dest.Rect.Max.X; x++ {
-		for y = dest.Rect.Min.Y; y < dest.Rect.Max.Y; y++ {
-			si = src.PixOffset(x, y)
-			i = dest.PixOffset(x, y)
-			dest.Pix[i+0] = src.Pix[si+4]
-			dest.Pix[i+1] = src.Pix[si+2]
-			dest.Pix[i+2] = src.Pix[si+0]
-			dest.Pix[i+3] = src.Pix[si+6]
+	parallel(dest.Rect, func(band image.Rectangle) {
+		var x, y, i, si int
+
+		for y = band.Min.Y; y < band.Max.Y; y++ {
+			for x = band.Min.X; x < band.Max.X; x++ {
+				si = src.PixOffset(x, y)
+				i = dest.PixOffset(x, y)
+				dest.Pix[i+0] = src.Pix[si+4]
+				dest.Pix[i+1] = src.Pix[si+2]
+				dest.Pix[i+2] = src.Pix[si+0]
+				dest.Pix[i+3] = src.Pix[si+6]
+			}
 		}
-	}
+	})
 }
 
 func convertNRGBA(dest *Image, src *image.NRGBA) {
-	var x, y, i, si int
-	var a uint16
-
-	for x = dest.Rect.Min.X; x < dest.Rect.Max.X; x++ {
-		for y = dest.Rect.Min.Y; y < dest.Rect.Max.Y; y++ {
-			si = src.PixOffset(x, y)
-			i = dest.PixOffset(x, y)
-			a = uint16(src.Pix[si+3])
-
-			dest.Pix[i+0] = uint8((uint16(src.Pix[si+2]) * a) / 0xff)
-			dest.Pix[i+1] = uint8((uint16(src.Pix[si+1]) * a) / 0xff)
-			dest.Pix[i+2] = uint8((uint16(src.Pix[si+0]) * a) / 0xff)
-			dest.Pix[i+3] = src.Pix[si+3]
+	parallel(dest.Rect, func(band image.Rectangle) {
+		var x, y, i, si int
+		var a uint16
+
+		for y = band.Min.Y; y < band.Max.Y; y++ {
+			for x = band.Min.X; x < band.Max.X; x++ {
+				si = src.PixOffset(x, y)
+				i = dest.PixOffset(x, y)
+				a = uint16(src.Pix[si+3])
+
+				dest.Pix[i+0] = uint8((uint16(src.Pix[si+2]) * a) / 0xff)
+				dest.Pix[i+1] = uint8((uint16(src.Pix[si+1]) * a) / 0xff)
+				dest.Pix[i+2] = uint8((uint16(src.Pix[si+0]) * a) / 0xff)
+				dest.Pix[i+3] = src.Pix[si+3]
+			}
 		}
-	}
+	})
 }
 
 func convertNRGBA64(dest *Image, src *image.NRGBA64) {
-	var x, y, i, si int
-	var a uint16
+	parallel(dest.Rect, func(band image.Rectangle) {
+		var x, y, i, si int
+		var a uint16
+
+		for y = band.Min.Y; y < band.Max.Y; y++ {
+			for x = band.Min.X; x < band.Max.X; x++ {
+				si = src.PixOffset(x, y)
+				i = dest.PixOffset(x, y)
+				a = uint16(src.Pix[si+6])
+
+				dest.Pix[i+0] = uint8((uint16(src.Pix[si+4]) * a) / 0xff)
+				dest.Pix[i+1] = uint8((uint16(src.Pix[si+2]) * a) / 0xff)
+				dest.Pix[i+2] = uint8((uint16(src.Pix[si+0]) * a) / 0xff)
+				dest.Pix[i+3] = src.Pix[si+6]
+			}
+		}
+	})
+}
 
-	for x = dest.Rect.Min.X; x < dest.Rect.Max.X; x++ {
-		for y = dest.Rect.Min.Y; y < dest.Rect.Max.Y; y++ {
-			si = src.PixOffset(x, y)
-			i = dest.PixOffset(x, y)
-			a = uint16(src.Pix[si+6])
+func convertPaletted(dest *Image, src *image.Paletted) {
+	// The palette is converted once, the same way convertImage would.
+	palette := make([]BGRA, len(src.Palette))
+	for i, c := range src.Palette {
+		r, g, b, a := c.RGBA()
+		palette[i] = BGRA{uint8(b >> 8), uint8(g >> 8), uint8(r >> 8),
+			uint8(a >> 8)}
+	}
 
-			dest.Pix[i+0] = uint8((uint16(src.Pix[si+4]) * a) / 0xff)
-			dest.Pix[i+1] = uint8((uint16(src.Pix[si+2]) * a) / 0xff)
-			dest.Pix[i+2] = uint8((uint16(src.Pix[si+0]) * a) / 0xff)
-			dest.Pix[i+3] = src.Pix[si+6]
+	parallel(dest.Rect, func(band image.Rectangle) {
+		var x, y, i int
+		var c BGRA
+		for y = band.Min.Y; y < band.Max.Y; y++ {
+			for x = band.Min.X; x < band.Max.X; x++ {
+				if pi := int(src.Pix[src.PixOffset(x, y)]); pi < len(palette) {
+					c = palette[pi]
+				} else {
+					c = BGRA{}
+				}
+				i = dest.PixOffset(x, y)
+				dest.Pix[i+0] = c.B
+				dest.Pix[i+1] = c.G
+				dest.Pix[i+2] = c.R
+				dest.Pix[i+3] = c.A
+			}
 		}
-	}
+	})
 }
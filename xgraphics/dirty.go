@@ -0,0 +1,208 @@
+package xgraphics
+
+/*
+xgraphics/dirty.go contains methods for keeping track of the regions of an
+Image that have been modified since they were last sent to X.
+
+Sending only the modified parts of an image with sub-images and XPaintRects
+is fast, but requires keeping track of what changed. Instead, Image values
+remember the regions modified by Set, SetBGRA, For, ForExp, Text and the
+blending functions, and Flush sends and paints just those regions.
+
+Modified regions are kept as a small list of rectangles. Rectangles that are
+close together are merged, since drawing a slightly bigger area is usually
+cheaper than issuing more requests. Pixels modified one at a time (by Set and
+SetBGRA) are first collected in a bounding box, which is only added to the
+list when a pixel far away from it is modified, or when the list is needed.
+Otherwise, setting every pixel of an image would merge rectangles for each of
+them.
+*/
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/BurntSushi/xgb/xproto"
+)
+
+// maxDirtyRects is the maximum number of rectangles kept for an image. When
+// there are more, the two rectangles that are cheapest to merge are merged.
+const maxDirtyRects = 8
+
+// mergeSlack is the number of unmodified pixels that are worth sending to
+// avoid sending two rectangles separately. (It should stay small. Otherwise,
+// a diagonal line would make its entire bounding box dirty.)
+const mergeSlack = 64
+
+// dirtyRegion is the list of modified rectangles of an image. It is shared
+// by an image and all of its sub-images.
+type dirtyRegion struct {
+	rects []image.Rectangle
+
+	// pixels is the bounding box of the pixels modified by Set and SetBGRA
+	// that haven't been added to rects yet.
+	pixels image.Rectangle
+}
+
+// Damage marks the rectangle given as modified, so that it is sent to X by
+// the next call to Flush. This only needs to be called after modifying the
+// Pix data of an image directly, since every other method that modifies the
+// image does it for you.
+func (im *Image) Damage(r image.Rectangle) {
+	if im.dirty == nil {
+		return
+	}
+	im.dirty.add(r.Intersect(im.Rect))
+}
+
+// damagePixel marks the pixel at (x, y) as modified. It must be inside the
+// image.
+func (im *Image) damagePixel(x, y int) {
+	if im.dirty == nil {
+		return
+	}
+	im.dirty.addPixel(x, y)
+}
+
+// Dirty returns the rectangles that have been modified since the image was
+// last flushed.
+func (im *Image) Dirty() []image.Rectangle {
+	if im.dirty == nil {
+		return nil
+	}
+	im.dirty.addPixels()
+	rects := make([]image.Rectangle, len(im.dirty.rects))
+	copy(rects, im.dirty.rects)
+	return rects
+}
+
+// Flush sends the modified regions of the image to its pixmap and paints
+// only those regions of the window given. This is like XDraw followed by
+// XPaint, except the rest of the image isn't sent or painted again.
+// If the image doesn't have a pixmap yet, XSurfaceSet is called first.
+// (A new image is considered to be entirely modified, so the first call to
+// Flush sends the whole image.)
+//
+// Like XSurfaceSet, Flush cannot be called on a sub-image. Modifications made
+// to sub-images are remembered by the original image.
+func (im *Image) Flush(wid xproto.Window) error {
+	if im.Subimg {
+		return fmt.Errorf("Flush cannot be called on sub-images. " +
+			"Please flush the original parent image.")
+	}
+	if im.Pixmap == 0 {
+		if err := im.XSurfaceSet(wid); err != nil {
+			return err
+		}
+	}
+	if im.dirty == nil {
+		return nil
+	}
+
+	im.dirty.addPixels()
+	rects := im.dirty.rects
+	im.dirty.rects = nil
+	for _, r := range rects {
+		if si, ok := im.SubImage(r).(*Image); ok {
+			si.XDraw()
+		}
+	}
+	for _, r := range rects {
+		xproto.ClearArea(im.X.Conn(), false, wid,
+			int16(r.Min.X), int16(r.Min.Y), uint16(r.Dx()), uint16(r.Dy()))
+	}
+	return nil
+}
+
+// drawn is called after the image has been sent to X. If the whole image
+// was sent, then nothing is modified anymore.
+func (im *Image) drawn() {
+	if im.dirty != nil && !im.Subimg {
+		im.dirty.rects = nil
+		im.dirty.pixels = image.Rectangle{}
+	}
+}
+
+// add adds a rectangle to the region, merging it with existing rectangles
+// when it's cheap to do so.
+func (d *dirtyRegion) add(r image.Rectangle) {
+	if r.Empty() {
+		return
+	}
+	for _, e := range d.rects {
+		if r.In(e) {
+			return
+		}
+	}
+
+	// Keep merging until 'r' isn't close to any other rectangle, since a
+	// merged rectangle may now be close to others.
+	for merged := true; merged; {
+		merged = false
+		for i, e := range d.rects {
+			if mergeCost(e, r) <= mergeSlack {
+				r = r.Union(e)
+				d.rects = append(d.rects[:i], d.rects[i+1:]...)
+				merged = true
+				break
+			}
+		}
+	}
+	d.rects = append(d.rects, r)
+
+	for len(d.rects) > maxDirtyRects {
+		bi, bj, best := 0, 1, -1
+		for i := range d.rects {
+			for j := i + 1; j < len(d.rects); j++ {
+				cost := mergeCost(d.rects[i], d.rects[j])
+				if best == -1 || cost < best {
+					bi, bj, best = i, j, cost
+				}
+			}
+		}
+		d.rects[bi] = d.rects[bi].Union(d.rects[bj])
+		d.rects = append(d.rects[:bj], d.rects[bj+1:]...)
+	}
+}
+
+// addPixel adds a pixel to the bounding box of modified pixels. If the pixel
+// is far from the box, the box is added to the region and a new one is
+// started.
+func (d *dirtyRegion) addPixel(x, y int) {
+	p := image.Rect(x, y, x+1, y+1)
+	switch {
+	case p.In(d.pixels):
+	case d.pixels.Empty():
+		d.pixels = p
+	case mergeCost(d.pixels, p) <= mergeSlack:
+		d.pixels = d.pixels.Union(p)
+	default:
+		d.add(d.pixels)
+		d.pixels = p
+	}
+}
+
+// addPixels adds the bounding box of modified pixels to the region.
+func (d *dirtyRegion) addPixels() {
+	if !d.pixels.Empty() {
+		d.add(d.pixels)
+		d.pixels = image.Rectangle{}
+	}
+}
+
+// all marks the entire rectangle given as modified.
+func (d *dirtyRegion) all(r image.Rectangle) {
+	d.rects = []image.Rectangle{r}
+	d.pixels = image.Rectangle{}
+}
+
+// mergeCost returns the number of unmodified pixels that would be sent if
+// the two rectangles given were merged.
+func mergeCost(r1, r2 image.Rectangle) int {
+	return area(r1.Union(r2)) - area(r1) - area(r2) + area(r1.Intersect(r2))
+}
+
+// area returns the number of pixels in a rectangle.
+func area(r image.Rectangle) int {
+	return r.Dx() * r.Dy()
+}
@@ -3,7 +3,7 @@ Package xgraphics defines an X image type and provides convenience functions
 for reading and writing X pixmaps and bitmaps. It is a work-in-progress, and
 while it works for some common X server configurations, it does not work for
 all X server configurations. Package xgraphics also provides some support for
-drawing text on to images using freetype-go, scaling images using graphics-go,
+drawing text on to images using freetype-go, scaling images,
 simple alpha blending, finding EWMH and ICCCM window icons and efficiently
 drawing any image into an X pixmap. (Where "efficient" means being able to
 specify sub-regions of images to draw, so that the entire image isn't sent to
@@ -20,6 +20,121 @@ responding to expose events to redraw the pixmap.) The former approach requires
 less book-keeping, but supposedly has some issues with some video cards. The
 latter approach is probably more reliable, but requires more book-keeping.
 
+When the X server supports the MIT-SHM extension and is running on the same
+machine, large images are drawn to pixmaps (XDraw) and read from drawables
+(NewDrawable) through shared memory, which is much faster than sending the
+image data over the X connection. This happens automatically, and xgraphics
+falls back to plain PutImage and GetImage requests when shared memory can't
+be used. ShmAvailable reports which approach is being used, and FreeShm
+releases the shared memory of a connection that is about to be closed.
+
+Images with an alpha channel are usually painted to windows by blending them
+against a background color first (see BlendBgColor), since the alpha channel
+is otherwise lost. Alternatively, the image's ARGB field can be set to paint
+it to a window created with xwindow's CreateARGB. When a compositing manager
+is running, such windows are shown with real translucency. (This is useful
+for things like on screen displays and notifications.) An example named
+'translucent-window' can be found in the examples directory.
+
+Images keep track of the regions that were modified since they were last sent
+to X. (This includes Set, SetBGRA, For, ForExp, Text and the blending
+functions. Damage must be called after modifying Pix directly.) Flush sends
+and paints only those regions, which is much faster than calling XDraw and
+XPaint when only small parts of a large image change:
+
+	ximg.Text(10, 10, textColor, 12, font, "Updated!")
+	ximg.Flush(win.Id)
+
+Images that are drawn many times (like icons or thumbnails) can be sent to X
+once with XPicture. The resulting Picture is composited on to windows by the
+X server using the RENDER extension, with alpha blending and an optional
+transform (to scale or rotate it) and filter. For example, to draw an icon at
+half its size and rotated by 90 degrees:
+
+	pic, err := ximg.XPicture()
+	if err != nil {
+		log.Fatal(err)
+	}
+	pic.SetTransform(xgraphics.ScaleTransform(0.5, 0.5).Then(
+		xgraphics.RotateTransform(math.Pi / 2)))
+	pic.Composite(win.Id, 10, 10)
+
+Anti-aliased lines, rectangles, rounded rectangles, circles and polygons can
+be filled or stroked with a solid color or a gradient. (Arbitrary shapes can
+be built with a Path.) They are drawn directly into the image data, clipped
+to the image (or sub-image) and mark only the area they cover as modified:
+
+	ximg.FillRoundedRectangle(0, 0, 200, 30, 6, &xgraphics.LinearGradient{
+		X0: 0, Y0: 0, X1: 0, Y1: 30,
+		Stops: []xgraphics.GradientStop{
+			{0, xgraphics.BGRA{B: 0x60, G: 0x60, R: 0x60, A: 0xff}},
+			{1, xgraphics.BGRA{B: 0x30, G: 0x30, R: 0x30, A: 0xff}},
+		},
+	})
+	ximg.StrokeRoundedRectangle(0.5, 0.5, 199, 29, 6, 1, borderColor)
+
+Text draws a single line with a single font. For anything else (like the
+title of a window in a panel, or the body of a notification), use a
+TextLayout. It wraps text to a width, aligns each line, truncates text that
+doesn't fit with an ellipsis and draws characters missing in the primary font
+with fallback fonts:
+
+	layout := xgraphics.NewTextLayout(12, font, fallbackFont)
+	layout.Width, layout.Wrap, layout.Ellipsis = 200, true, true
+	layout.MaxLines = 3
+	layout.Align = xgraphics.AlignCenter
+	ximg.TextLayout(10, 10, textColor, layout, body)
+
+Text, Extents and TextLayout cache the glyphs they rasterize for each font
+and size, so text that is redrawn often (like a clock) is cheap to draw. A
+Face can be used directly to draw text on to any draw.Image with its own
+glyph cache.
+
+Instead of opening a font file and parsing it with ParseFont, fonts
+installed on the local machine can be found by family name and style with
+FindFont:
+
+	font, err := xgraphics.FindFont("DejaVu Sans:bold")
+
+The standard font directories are indexed the first time FindFont is called,
+and the index is kept in a cache file so that later programs start quickly.
+
+When a window has neither a _NET_WM_ICON nor an icon in WM_HINTS, FindIcon
+looks up the icon of its application by WM_CLASS, through the application's
+.desktop file and the freedesktop.org icon theme given by IconTheme. Icons can
+also be looked up by name with ThemeIconPath and NewThemeIcon:
+
+	xgraphics.IconTheme = "Adwaita"
+	icon, err := xgraphics.NewThemeIcon(X, "utilities-terminal", 48)
+
+Besides finding the icons of other windows with FindIcon, xgraphics can
+publish the icon of your own windows. SetIcon sets _NET_WM_ICON to the image
+at several sizes, and creates an icon pixmap and mask for WM_HINTS, which is
+all that older window managers understand:
+
+	err := xgraphics.SetIcon(X, win.Id, iconImg)
+
+Importing xgraphics registers decoders for XPM and XBM images (which many
+older X applications use for icons, cursors and masks) with the image
+package, so NewFileName, NewBytes and image.Decode read them like any other
+image format. (Colors in XPM images may be named, as in X11's rgb.txt.)
+EncodeXpm and EncodeXbm write images in those formats.
+
+Converting large images (NewConvert) and blending them (Blend, BlendBgColor
+and Alpha) is split between multiple goroutines, one for each CPU by
+default. The results are exactly the same as with a single goroutine.
+SetWorkers changes the number of goroutines used. For and ForExp call the
+function given from a single goroutine, and ForParallel and ForExpParallel
+split the work like the other functions do. (So the functions given to them
+must be safe to call from multiple goroutines at the same time.)
+
+Images are scaled with a choice of filters (nearest, bilinear, bicubic,
+Lanczos and box) directly on BGRA data. Scale uses the bicubic filter, and
+ScaleWith takes a filter and leaves the original image alone. Lanczos is the
+sharpest choice for shrinking icons and thumbnails:
+
+	thumb := ximg.ScaleWith(64, 64, xgraphics.ScaleLanczos)
+
 Note that while text drawing functions are provided, it is not necessary to use
 them to write text on images. Namely, there is nothing X specific about them.
 They are strictly for convenience.
@@ -51,11 +166,13 @@ found in the examples directory of the xgbutil package. More involved examples,
 
 Portability
 
-The xgraphics package *assumes* a particular kind of X server configuration.
-Namely, this configuration specifies bits per pixel, image byte order, bitmap
-bit order, scanline padding and unit length, image depth and so on. Handling
-all of the possible values for each configuration option will greatly inflate
-the code, but is on the TODO list.
+An X server's configuration specifies bits per pixel, image byte order, bitmap
+bit order, scanline padding and unit length, image depth and so on. The
+xgraphics package stores image data in the format used by the most common
+configuration (depth 24 with 32 bits per pixel, least significant byte first),
+and converts image data when it is drawn to or read from an X server with a
+different configuration. This includes 16 bit and 30 bit (deep color) depths
+and big-endian servers. Only TrueColor and DirectColor visuals are supported.
 
 I am undecided (perhaps because I haven't thought about it too much) about
 whether to hide these configuration details behind multiple xgraphics.Image
@@ -82,8 +199,8 @@ convert image formats only when drawing to X and completely subvert the
 xgraphics.Image type, but this seems worse than unoptimized image drawing
 routines. (Unfortunately, both things need to be fast.)
 
-If your X server is not configured to what the xgraphics package expects,
-messages will be emitted to stderr when a new xgraphics.Image value is created.
+If your X server uses a configuration that the xgraphics package can't handle,
+messages will be emitted to stderr when an xgraphics.Image value is drawn.
 If you see any of these messages, please report them to xgbutil's project page:
 https://github.com/BurntSushi/xgbutil.
 */
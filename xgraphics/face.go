@@ -0,0 +1,264 @@
+package xgraphics
+
+/*
+xgraphics/face.go contains a Face type, which draws and measures text with a
+single font at a single size and caches the glyphs it rasterizes.
+
+Rasterizing glyphs is by far the most expensive part of drawing text, and
+freetype-go only caches glyphs in a freetype.Context. Creating a context for
+every call to Text would throw that cache away, so Text, Extents and
+TextLayout share a Face for each font and size instead. (Only the most
+recently used ones are kept. See maxFaces.) Programs that redraw the same
+text over and over (like a clock) then only rasterize each glyph once.
+
+Glyphs are cached by rune and sub-pixel offset. Like freetype-go, the
+horizontal position of a glyph is rounded to a quarter of a pixel, so each
+glyph is rasterized at most four times. (The output can differ very slightly
+from freetype.Context.DrawString, which rasterizes each glyph at the exact
+position it's first drawn at, and reuses it for nearby positions.)
+*/
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"sync"
+
+	"github.com/BurntSushi/freetype-go/freetype"
+	"github.com/BurntSushi/freetype-go/freetype/raster"
+	"github.com/BurntSushi/freetype-go/freetype/truetype"
+)
+
+// xFractions is the number of sub-pixel horizontal positions that a glyph
+// is rasterized at. It is the same as in freetype-go.
+const xFractions = 4
+
+// maxFaces is the maximum number of shared Faces kept by Text, Extents and
+// TextLayout. When there are more, the least recently used one is dropped.
+// (Programs that draw text at many different sizes, like when zooming or
+// animating text, would otherwise keep the glyphs of every size forever.)
+const maxFaces = 16
+
+// maxGlyphs is the maximum number of glyphs cached by a Face. When the cache
+// is full, it is emptied. (This only happens for text with many different
+// characters, like CJK text.)
+const maxGlyphs = 2048
+
+// Face draws and measures text with a single font and size. Glyphs are
+// rasterized once and cached, so reusing a Face is much faster than drawing
+// text with a new freetype.Context each time.
+// A Face may be used from multiple goroutines.
+type Face struct {
+	Font *truetype.Font
+	Size float64
+
+	lck    sync.Mutex
+	c      *freetype.Context
+	buf    *truetype.GlyphBuf
+	glyphs map[glyphKey]*glyph
+}
+
+// glyphKey identifies a rasterized glyph in the cache of a Face. fx is the
+// sub-pixel horizontal offset, in the range [0, xFractions).
+type glyphKey struct {
+	r  rune
+	fx int
+}
+
+// glyph is a rasterized glyph. Its mask is drawn at 'offset' relative to the
+// integer pixel position of the pen. yMin is the bottom of the glyph's
+// bounding box in 26.6 fixed point units, which is used for measuring text.
+type glyph struct {
+	mask   *image.Alpha
+	offset image.Point
+	yMin   int32
+}
+
+// faceKey identifies a shared Face in the faces cache.
+type faceKey struct {
+	font *truetype.Font
+	size float64
+}
+
+// faces caches the Faces used by Text, Extents and TextLayout, so that glyphs
+// are only rasterized once for each font and size. faceOrder has the keys of
+// the cached Faces, from least to most recently used.
+var (
+	faces     = make(map[faceKey]*Face)
+	faceOrder []faceKey
+	facesLck  = &sync.Mutex{}
+)
+
+// NewFace creates a new Face for the font and size (in points, at 72 DPI)
+// given, with an empty glyph cache.
+func NewFace(font *truetype.Font, size float64) *Face {
+	return &Face{
+		Font:   font,
+		Size:   size,
+		c:      ftContext(font, size),
+		buf:    truetype.NewGlyphBuf(),
+		glyphs: make(map[glyphKey]*glyph),
+	}
+}
+
+// face returns the shared Face for the font and size given, creating it if
+// necessary. Programs that need a Face to stay cached no matter how many
+// other sizes are used should create their own with NewFace.
+func face(font *truetype.Font, size float64) *Face {
+	facesLck.Lock()
+	defer facesLck.Unlock()
+
+	key := faceKey{font, size}
+	for i, k := range faceOrder {
+		if k == key {
+			faceOrder = append(faceOrder[:i], faceOrder[i+1:]...)
+			break
+		}
+	}
+	faceOrder = append(faceOrder, key)
+
+	if f, ok := faces[key]; ok {
+		return f
+	}
+	f := NewFace(font, size)
+	faces[key] = f
+	if len(faceOrder) > maxFaces {
+		delete(faces, faceOrder[0])
+		faceOrder = append(faceOrder[:0], faceOrder[1:]...)
+	}
+	return f
+}
+
+// DrawString draws 'text' on to 'dst' with the color (or image) 'src', with
+// the left edge of the first character on the baseline at 'pt', and returns
+// 'pt' advanced by the width of the text. It is the same as
+// freetype.Context.DrawString, except glyphs are cached.
+func (f *Face) DrawString(dst draw.Image, src image.Image, pt raster.Point,
+	text string) (raster.Point, error) {
+
+	f.lck.Lock()
+	defer f.lck.Unlock()
+
+	clip := dst.Bounds()
+	scale := f.scale()
+	prev, hasPrev := truetype.Index(0), false
+	for _, r := range text {
+		index := f.Font.Index(r)
+		if hasPrev {
+			pt.X += raster.Fix32(f.Font.Kerning(scale, prev, index)) << 2
+		}
+
+		// Round the position of the glyph to the nearest sub-pixel offset.
+		x := pt.X + 128/xFractions
+		g, err := f.glyph(r, index, x&0xff)
+		if err != nil {
+			return raster.Point{}, err
+		}
+		origin := image.Point{int(x >> 8), int(pt.Y >> 8)}
+		glyphRect := g.mask.Bounds().Add(origin.Add(g.offset))
+		if dr := clip.Intersect(glyphRect); !dr.Empty() {
+			mp := dr.Min.Sub(glyphRect.Min)
+			draw.DrawMask(dst, dr, src, image.ZP, g.mask, mp, draw.Over)
+		}
+
+		pt.X += raster.Fix32(f.Font.HMetric(scale, index).AdvanceWidth) << 2
+		prev, hasPrev = index, true
+	}
+	return pt, nil
+}
+
+// MeasureString returns the width and height of 'text' in 24.8 fixed point
+// units. It is the same as freetype.Context.MeasureString, except glyph
+// metrics are cached.
+func (f *Face) MeasureString(text string) (raster.Fix32, raster.Fix32, error) {
+	f.lck.Lock()
+	defer f.lck.Unlock()
+
+	var width, heightMax raster.Fix32
+	oneLine := f.c.PointToFix32(f.Size) & 0xff
+	height := f.c.PointToFix32(f.Size)
+	scale := f.scale()
+	prev, hasPrev := truetype.Index(0), false
+	for _, r := range text {
+		index := f.Font.Index(r)
+		if hasPrev {
+			width += raster.Fix32(f.Font.Kerning(scale, prev, index)) << 2
+		}
+
+		g, err := f.glyph(r, index, 0)
+		if err != nil {
+			return 0, 0, err
+		}
+		if ymax := oneLine - raster.Fix32(g.yMin<<2) + 0xff; ymax > heightMax {
+			heightMax = ymax
+		}
+
+		width += raster.Fix32(f.Font.HMetric(scale, index).AdvanceWidth) << 2
+		prev, hasPrev = index, true
+	}
+	if heightMax > 0 {
+		height += heightMax
+	}
+	return width, height, nil
+}
+
+// Glyphs returns the number of glyphs currently cached.
+func (f *Face) Glyphs() int {
+	f.lck.Lock()
+	defer f.lck.Unlock()
+
+	return len(f.glyphs)
+}
+
+// scale returns the number of 26.6 fixed point units in 1 em.
+func (f *Face) scale() int32 {
+	return int32(f.Size * 64)
+}
+
+// glyph returns the rasterized glyph for 'r' (whose index in the font is
+// 'index') at the sub-pixel offset 'fx', rasterizing it if it isn't cached.
+// f.lck must be held.
+func (f *Face) glyph(r rune, index truetype.Index,
+	fx raster.Fix32) (*glyph, error) {
+
+	key := glyphKey{r, int(fx) / (256 / xFractions)}
+	if g, ok := f.glyphs[key]; ok {
+		return g, nil
+	}
+	if len(f.glyphs) >= maxGlyphs {
+		f.glyphs = make(map[glyphKey]*glyph)
+	}
+
+	// Compute the integer pixel bounds of the glyph the same way freetype
+	// does, so that the glyph ends up in exactly the same place.
+	fx = raster.Fix32(key.fx * (256 / xFractions))
+	if err := f.buf.Load(f.Font, f.scale(), index, nil); err != nil {
+		return nil, err
+	}
+	b := f.buf.B
+	xmin := int(fx+raster.Fix32(b.XMin<<2)) >> 8
+	ymin := int(-raster.Fix32(b.YMax<<2)) >> 8
+	xmax := int(fx+raster.Fix32(b.XMax<<2)+0xff) >> 8
+	ymax := int(-raster.Fix32(b.YMin<<2)+0xff) >> 8
+	if xmin > xmax || ymin > ymax {
+		return nil, fmt.Errorf("Glyph for %q has a negative size.", r)
+	}
+
+	// Let freetype rasterize the glyph on to a mask that is exactly the
+	// size of its bounds.
+	mask := image.NewAlpha(image.Rect(0, 0, xmax-xmin, ymax-ymin))
+	f.c.SetClip(mask.Bounds())
+	f.c.SetDst(mask)
+	f.c.SetSrc(image.Opaque)
+	pt := raster.Point{
+		X: raster.Fix32(-xmin<<8) + fx,
+		Y: raster.Fix32(-ymin << 8),
+	}
+	if _, err := f.c.DrawString(string(r), pt); err != nil {
+		return nil, err
+	}
+
+	g := &glyph{mask, image.Point{xmin, ymin}, b.YMin}
+	f.glyphs[key] = g
+	return g, nil
+}
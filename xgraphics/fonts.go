@@ -0,0 +1,646 @@
+package xgraphics
+
+/*
+xgraphics/fonts.go contains functions for finding fonts installed on the
+local machine by family name and style, so that programs don't need to
+hard-code paths to font files.
+
+Fonts are found by scanning the standard font directories (the "fonts"
+directory in $XDG_DATA_HOME and each of $XDG_DATA_DIRS, and ~/.fonts) for
+TrueType files. The family, style, weight and slant of each font are read
+from its 'name', 'OS/2' and 'head' tables. Since reading every font file is
+slow, the resulting index is saved to a cache file in $XDG_CACHE_HOME. On
+the next start, only files that were added or modified since then are read.
+(Files that can't be read as fonts are also saved to the cache file, so
+that they aren't read again on every start either.)
+
+Only TrueType fonts (.ttf, and the first font in a .ttc collection) are
+indexed, since those are the only fonts freetype-go can parse.
+*/
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	"github.com/BurntSushi/freetype-go/freetype/truetype"
+
+	"github.com/BurntSushi/xgbutil"
+)
+
+// fontCacheVersion is the first line of the font cache file. It should be
+// changed whenever the format of the cache file changes.
+const fontCacheVersion = "xgbutil-fonts 2"
+
+// FontInfo describes a font file found by the font index.
+// Weight is the weight class of the font, from 100 (thin) to 900 (black),
+// where 400 is regular and 700 is bold.
+type FontInfo struct {
+	Path   string
+	Family string
+	Style  string
+	Weight int
+	Italic bool
+
+	// The modification time (in nanoseconds) and size of the file when it
+	// was indexed, used to tell if the cache entry is stale.
+	mtime int64
+	size  int64
+
+	// unusable is true if the file couldn't be read as a font. Such files
+	// are only in the cache file, and never in the index.
+	unusable bool
+}
+
+func (fi FontInfo) String() string {
+	return fmt.Sprintf("%s:%s (weight %d) %s",
+		fi.Family, fi.Style, fi.Weight, fi.Path)
+}
+
+// fontIndex is the index of local fonts. It's built the first time it's
+// needed. parsedFonts caches fonts parsed by FindFont, so that finding the
+// same font twice returns the same *truetype.Font. (Which also means they
+// share the same glyph cache when drawn.)
+var (
+	fontIndex    []FontInfo
+	fontIndexLck = &sync.Mutex{}
+
+	parsedFonts    = make(map[string]*truetype.Font)
+	parsedFontsLck = &sync.Mutex{}
+)
+
+// styleWeights maps words used in font queries and style names to weight
+// classes.
+var styleWeights = map[string]int{
+	"thin":       100,
+	"hairline":   100,
+	"extralight": 200,
+	"ultralight": 200,
+	"light":      300,
+	"regular":    400,
+	"normal":     400,
+	"book":       400,
+	"roman":      400,
+	"medium":     500,
+	"semibold":   600,
+	"demibold":   600,
+	"bold":       700,
+	"extrabold":  800,
+	"ultrabold":  800,
+	"black":      900,
+	"heavy":      900,
+}
+
+// FindFont returns the installed font that best matches 'query'.
+// A query is a family name optionally followed by style words separated by
+// colons, like "DejaVu Sans", "DejaVu Sans:bold" or "Liberation Serif:bold:
+// italic". Style words are weights (like "light", "bold" or "weight=600"),
+// "italic" (or "oblique") and any other word in a font's style name (like
+// "condensed"). Family names are matched case insensitively.
+//
+// When there is no font with exactly the style requested, the closest one is
+// returned. (e.g., the regular font if a family has no light font.) An error
+// is returned if no font in the family is installed.
+func FindFont(query string) (*truetype.Font, error) {
+	info, err := FindFontInfo(query)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedFontsLck.Lock()
+	defer parsedFontsLck.Unlock()
+
+	if font, ok := parsedFonts[info.Path]; ok {
+		return font, nil
+	}
+	f, err := os.Open(info.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	font, err := ParseFont(f)
+	if err != nil {
+		return nil, fmt.Errorf("FindFont: Could not parse '%s': %s",
+			info.Path, err)
+	}
+	parsedFonts[info.Path] = font
+	return font, nil
+}
+
+// FindFontInfo is the same as FindFont, except it returns information about
+// the best matching font without parsing it.
+func FindFontInfo(query string) (FontInfo, error) {
+	fonts, err := Fonts()
+	if err != nil {
+		return FontInfo{}, err
+	}
+
+	parts := strings.Split(query, ":")
+	family := strings.TrimSpace(parts[0])
+	weight, italic, words := 400, false, make(map[string]bool)
+	for _, part := range parts[1:] {
+		for _, word := range strings.Fields(strings.ToLower(part)) {
+			switch {
+			case word == "italic" || word == "oblique":
+				italic = true
+			case strings.HasPrefix(word, "weight="):
+				w, err := strconv.Atoi(word[len("weight="):])
+				if err != nil {
+					return FontInfo{}, fmt.Errorf("FindFont: Bad weight "+
+						"in font query %q: %s", query, err)
+				}
+				weight = w
+			case styleWeights[word] > 0:
+				weight = styleWeights[word]
+			default:
+				words[word] = true
+			}
+		}
+	}
+
+	best, bestScore := -1, 0
+	for i, fi := range fonts {
+		if !strings.EqualFold(fi.Family, family) {
+			continue
+		}
+		score := styleScore(fi, weight, italic, words)
+		if best == -1 || score < bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best == -1 {
+		return FontInfo{}, fmt.Errorf("FindFont: Could not find a font in "+
+			"the family '%s'.", family)
+	}
+	return fonts[best], nil
+}
+
+// styleScore returns how far the style of a font is from the style that was
+// asked for. Lower is better. Slant matters most, then extra style words
+// (like "condensed") and finally the difference in weight.
+func styleScore(fi FontInfo, weight int, italic bool,
+	words map[string]bool) int {
+
+	score := fi.Weight - weight
+	if score < 0 {
+		score = -score
+	}
+	if fi.Italic != italic {
+		score += 10000
+	}
+
+	have := make(map[string]bool)
+	for _, word := range strings.Fields(strings.ToLower(fi.Style)) {
+		have[word] = true
+		if styleWeights[word] == 0 && word != "italic" && word != "oblique" &&
+			!words[word] {
+
+			score += 1000 // the font has a style that wasn't asked for
+		}
+	}
+	for word := range words {
+		if !have[word] {
+			score += 1000 // the font doesn't have a style that was asked for
+		}
+	}
+	return score
+}
+
+// Fonts returns every font in the index of local fonts, sorted by family and
+// style. The index is built (or read from the cache file) the first time
+// Fonts, FindFont or FindFontInfo is called. The slice returned is a copy,
+// which the caller may modify.
+func Fonts() ([]FontInfo, error) {
+	fontIndexLck.Lock()
+	defer fontIndexLck.Unlock()
+
+	if fontIndex == nil {
+		if err := rescanFonts(); err != nil {
+			return nil, err
+		}
+	}
+	return append([]FontInfo(nil), fontIndex...), nil
+}
+
+// RescanFonts updates the index of local fonts. It only needs to be called
+// when fonts are installed or removed while a program is running.
+func RescanFonts() error {
+	fontIndexLck.Lock()
+	defer fontIndexLck.Unlock()
+
+	return rescanFonts()
+}
+
+// rescanFonts scans the font directories for font files, reusing the entries
+// in the cache file for files that haven't changed, and writes the cache file
+// if anything did. A cache file that can't be written only costs time on the
+// next start, so it isn't an error. fontIndexLck must be held.
+func rescanFonts() error {
+	cached := make(map[string]FontInfo)
+	for _, fi := range readFontCache() {
+		cached[fi.Path] = fi
+	}
+
+	// entries has every font file found, including the unusable ones, and
+	// is what's written to the cache file.
+	changed := false
+	seen := make(map[string]bool)
+	entries := make([]FontInfo, 0, len(cached))
+	for _, dir := range FontDirs() {
+		filepath.Walk(dir, func(path string, st os.FileInfo, err error) error {
+			if err != nil || st.IsDir() || seen[path] {
+				return nil
+			}
+			switch strings.ToLower(filepath.Ext(path)) {
+			case ".ttf", ".ttc":
+			default:
+				return nil
+			}
+			seen[path] = true
+
+			mtime, size := st.ModTime().UnixNano(), st.Size()
+			if fi, ok := cached[path]; ok && fi.mtime == mtime &&
+				fi.size == size {
+
+				entries = append(entries, fi)
+				return nil
+			}
+
+			changed = true
+			fi, err := readFontInfo(path)
+			if err != nil {
+				// Not a font we can use, which is remembered so that it
+				// isn't read again until it changes.
+				fi = FontInfo{Path: path, unusable: true}
+			}
+			fi.mtime, fi.size = mtime, size
+			entries = append(entries, fi)
+			return nil
+		})
+	}
+	if len(entries) != len(cached) {
+		changed = true // fonts were removed
+	}
+
+	index := make([]FontInfo, 0, len(entries))
+	for _, fi := range entries {
+		if !fi.unusable {
+			index = append(index, fi)
+		}
+	}
+	sort.Sort(fontInfos(index))
+	fontIndex = index
+	if changed {
+		if err := writeFontCache(entries); err != nil {
+			xgbutil.Logger.Printf("Could not write the font cache: %s", err)
+		}
+	}
+	return nil
+}
+
+// FontDirs returns the directories that are searched for fonts, in order of
+// preference.
+func FontDirs() []string {
+	var dirs []string
+	home := os.Getenv("HOME")
+	for i, dir := range dataDirs() {
+		dirs = append(dirs, filepath.Join(dir, "fonts"))
+		if i == 0 && home != "" {
+			dirs = append(dirs, filepath.Join(home, ".fonts"))
+		}
+	}
+	return dirs
+}
+
+// dataDirs returns $XDG_DATA_HOME followed by each of $XDG_DATA_DIRS, with
+// the defaults from the XDG Base Directory specification.
+func dataDirs() []string {
+	home := os.Getenv("HOME")
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" && home != "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	dataDirs := os.Getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = "/usr/local/share:/usr/share"
+	}
+
+	var dirs []string
+	if dataHome != "" {
+		dirs = append(dirs, dataHome)
+	}
+	for _, dir := range filepath.SplitList(dataDirs) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// fontCachePath returns the path of the font cache file, or an empty string
+// if neither $XDG_CACHE_HOME nor $HOME is set. (In which case there is no
+// cache file.)
+func fontCachePath() string {
+	cacheHome := os.Getenv("XDG_CACHE_HOME")
+	if cacheHome == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			return ""
+		}
+		cacheHome = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(cacheHome, "xgbutil", "fonts.cache")
+}
+
+// readFontCache reads the entries of the font cache file. A missing or
+// unreadable cache file is the same as an empty one.
+// Each line of the cache file (after the version) is a tab separated list of
+// the path, modification time, size, family, style, weight and slant of a
+// font, and whether it is unusable.
+func readFontCache() []FontInfo {
+	path := fontCachePath()
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() || scanner.Text() != fontCacheVersion {
+		return nil
+	}
+
+	var fonts []FontInfo
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 8 {
+			continue
+		}
+		fi := FontInfo{
+			Path:     fields[0],
+			Family:   fields[3],
+			Style:    fields[4],
+			Italic:   fields[6] == "1",
+			unusable: fields[7] == "1",
+		}
+		fi.mtime, _ = strconv.ParseInt(fields[1], 10, 64)
+		fi.size, _ = strconv.ParseInt(fields[2], 10, 64)
+		fi.Weight, _ = strconv.Atoi(fields[5])
+		fonts = append(fonts, fi)
+	}
+	return fonts
+}
+
+// writeFontCache writes the entries of the font index (including unusable
+// files) to the cache file. The file is
+// written to a temporary file first and then renamed, so that other
+// programs never read a partially written cache.
+func writeFontCache(fonts []FontInfo) error {
+	path := fontCachePath()
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp := fmt.Sprintf("%s.%d", path, os.Getpid())
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, fontCacheVersion)
+	flag := func(b bool) int {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	for _, fi := range fonts {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%d\t%d\t%d\n",
+			cacheField(fi.Path), fi.mtime, fi.size, cacheField(fi.Family),
+			cacheField(fi.Style), fi.Weight, flag(fi.Italic),
+			flag(fi.unusable))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// cacheField replaces the characters used to separate fields and lines in
+// the font cache file.
+func cacheField(s string) string {
+	return strings.NewReplacer("\t", " ", "\n", " ").Replace(s)
+}
+
+// fontInfos sorts fonts by family, then weight, then slant.
+type fontInfos []FontInfo
+
+func (fis fontInfos) Len() int {
+	return len(fis)
+}
+
+func (fis fontInfos) Less(i, j int) bool {
+	a, b := fis[i], fis[j]
+	switch {
+	case a.Family != b.Family:
+		return a.Family < b.Family
+	case a.Weight != b.Weight:
+		return a.Weight < b.Weight
+	case a.Italic != b.Italic:
+		return !a.Italic
+	}
+	return a.Style < b.Style
+}
+
+func (fis fontInfos) Swap(i, j int) {
+	fis[i], fis[j] = fis[j], fis[i]
+}
+
+// readFontInfo reads the family, style, weight and slant of the TrueType font
+// (or the first font of the TrueType collection) at 'path'. Only the tables
+// needed are read, so that indexing large fonts is fast.
+func readFontInfo(path string) (FontInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return FontInfo{}, err
+	}
+	defer f.Close()
+
+	tables, err := fontTables(f)
+	if err != nil {
+		return FontInfo{}, fmt.Errorf("%s: %s", path, err)
+	}
+
+	fi := FontInfo{Path: path, Weight: 400}
+	if name, ok := tables["name"]; ok {
+		fi.Family, fi.Style = fontNames(name)
+	}
+	if fi.Family == "" {
+		return FontInfo{}, fmt.Errorf("%s: Font has no family name.", path)
+	}
+	if fi.Style == "" {
+		fi.Style = "Regular"
+	}
+
+	if os2, ok := tables["OS/2"]; ok && len(os2) >= 64 {
+		fi.Weight = int(binary.BigEndian.Uint16(os2[4:]))
+		fsSelection := binary.BigEndian.Uint16(os2[62:])
+		fi.Italic = fsSelection&(1|1<<9) != 0 // italic or oblique
+	} else if head, ok := tables["head"]; ok && len(head) >= 46 {
+		macStyle := binary.BigEndian.Uint16(head[44:])
+		if macStyle&1 != 0 {
+			fi.Weight = 700
+		}
+		fi.Italic = macStyle&2 != 0
+	}
+	if fi.Weight < 1 || fi.Weight > 1000 {
+		fi.Weight = 400
+	}
+	return fi, nil
+}
+
+// fontTables reads the 'name', 'OS/2' and 'head' tables of a TrueType font.
+func fontTables(r io.ReaderAt) (map[string][]byte, error) {
+	header := make([]byte, 12)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+
+	offset := int64(0)
+	switch string(header[0:4]) {
+	case "\x00\x01\x00\x00", "true":
+	case "ttcf":
+		// Use the first font, since that's the one freetype-go parses.
+		first := make([]byte, 4)
+		if _, err := r.ReadAt(first, 12); err != nil {
+			return nil, err
+		}
+		offset = int64(binary.BigEndian.Uint32(first))
+		if _, err := r.ReadAt(header, offset); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Not a TrueType font.")
+	}
+
+	numTables := int(binary.BigEndian.Uint16(header[4:]))
+	dir := make([]byte, 16*numTables)
+	if _, err := r.ReadAt(dir, offset+12); err != nil {
+		return nil, err
+	}
+
+	tables := make(map[string][]byte)
+	for i := 0; i < numTables; i++ {
+		entry := dir[16*i:]
+		tag := string(entry[0:4])
+		switch tag {
+		case "name", "OS/2", "head":
+		default:
+			continue
+		}
+		off := int64(binary.BigEndian.Uint32(entry[8:]))
+		length := binary.BigEndian.Uint32(entry[12:])
+		if length > 1<<20 {
+			return nil, fmt.Errorf("The '%s' table is too big.", tag)
+		}
+		data := make([]byte, length)
+		if _, err := r.ReadAt(data, off); err != nil {
+			return nil, err
+		}
+		tables[tag] = data
+	}
+	return tables, nil
+}
+
+// fontNames returns the family and style names in a 'name' table. The
+// typographic family and style names (name IDs 16 and 17) are preferred,
+// since the legacy names (1 and 2) split large families into families with
+// at most four styles. English names are preferred.
+func fontNames(name []byte) (family, style string) {
+	if len(name) < 6 {
+		return "", ""
+	}
+	count := int(binary.BigEndian.Uint16(name[2:]))
+	storage := int(binary.BigEndian.Uint16(name[4:]))
+
+	// Each name ID is assigned the name with the highest priority.
+	names := make(map[uint16]string)
+	priorities := make(map[uint16]int)
+	for i := 0; i < count && 6+12*(i+1) <= len(name); i++ {
+		rec := name[6+12*i:]
+		platform := binary.BigEndian.Uint16(rec[0:])
+		encoding := binary.BigEndian.Uint16(rec[2:])
+		language := binary.BigEndian.Uint16(rec[4:])
+		id := binary.BigEndian.Uint16(rec[6:])
+		length := int(binary.BigEndian.Uint16(rec[8:]))
+		off := storage + int(binary.BigEndian.Uint16(rec[10:]))
+		if id != 1 && id != 2 && id != 16 && id != 17 {
+			continue
+		}
+		if off+length > len(name) {
+			continue
+		}
+		raw := name[off : off+length]
+
+		var s string
+		priority := 0
+		switch {
+		case platform == 3 && (encoding == 1 || encoding == 10):
+			s = decodeUTF16BE(raw)
+			priority = 2
+			if language == 0x409 { // English (United States)
+				priority = 3
+			}
+		case platform == 0:
+			s = decodeUTF16BE(raw)
+			priority = 1
+		case platform == 1 && encoding == 0 && language == 0:
+			s = string(raw) // Mac Roman, which is ASCII for most names
+			priority = 1
+		default:
+			continue
+		}
+		if s != "" && priority > priorities[id] {
+			names[id], priorities[id] = s, priority
+		}
+	}
+
+	family, style = names[16], names[17]
+	if family == "" {
+		family = names[1]
+	}
+	if style == "" {
+		style = names[2]
+	}
+	return strings.TrimSpace(family), strings.TrimSpace(style)
+}
+
+// decodeUTF16BE decodes a big-endian UTF-16 string.
+func decodeUTF16BE(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.BigEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
@@ -0,0 +1,328 @@
+package xgraphics
+
+/*
+xgraphics/format.go contains conversions between the BGRA pixels stored in
+an Image and the pixel formats used by the X server.
+
+An X server describes the layout of ZPixmap image data in a few places:
+the number of bits per pixel and the scanline padding depend upon the depth
+(see GetFormat), the positions of the red, green and blue components depend
+upon the visual (see getVisualInfo) and the byte order is global to the
+server. Images are always stored as BGRA, so that the common case (depth 24,
+32 bits per pixel, least significant byte first) can be sent to X without any
+conversion. Everything else is converted when the image is drawn or read.
+
+X expects pixels with an alpha channel to be premultiplied (i.e., each color
+component is already multiplied by alpha), while Image pixels are not. So
+pixels are premultiplied when sent to a pixmap with alpha (like the pixmaps
+of ARGB images) and divided by alpha again when read back.
+
+Only TrueColor and DirectColor visuals are supported. (Colormapped visuals
+like PseudoColor would require allocating colors.)
+*/
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xwindow"
+)
+
+// pixelFormat describes how the pixels of a particular depth and visual are
+// laid out in ZPixmap image data.
+type pixelFormat struct {
+	depth byte
+
+	// bpp is the number of bits per pixel, and pad is the number of bits
+	// that each scanline is padded to.
+	bpp, pad int
+
+	// msb is true if the X server's image byte order is most significant
+	// byte first.
+	msb bool
+
+	red, green, blue, alpha channel
+}
+
+// channel describes where a single color component is in a pixel.
+// 'max' is the largest value the component can have, and is 0 if the
+// component isn't present in the pixel. (Which is usually the case for
+// alpha.)
+type channel struct {
+	shift uint
+	max   uint32
+
+	// enc maps 8 bit values to values of the component (already shifted)
+	// and dec maps values of the component (not shifted) to 8 bit values.
+	enc [256]uint32
+	dec []uint8
+}
+
+// formatKey identifies a pixel format in the formats cache.
+type formatKey struct {
+	X      *xgbutil.XUtil
+	depth  byte
+	visual xproto.Visualid
+}
+
+// formats caches pixel formats, since they are needed every time an image is
+// drawn.
+var (
+	formats    = make(map[formatKey]*pixelFormat)
+	formatsLck = &sync.Mutex{}
+)
+
+// drawFormat returns the pixel format of pixmaps created by CreatePixmap
+// for images that aren't ARGB images.
+func drawFormat(X *xgbutil.XUtil) (*pixelFormat, error) {
+	return newPixelFormat(X, X.Screen().RootDepth, X.Screen().RootVisual)
+}
+
+// format returns the pixel format of the pixmap created by CreatePixmap for
+// this image.
+func (im *Image) format() (*pixelFormat, error) {
+	if !im.ARGB {
+		return drawFormat(im.X)
+	}
+
+	// Without an ARGB visual, 32 bit pixmaps can still be used with
+	// RENDER. newPixelFormat picks the usual masks in that case.
+	visual, _ := xwindow.ARGBVisual(im.X)
+	return newPixelFormat(im.X, 32, visual)
+}
+
+// newPixelFormat returns the pixel format for the depth and visual given.
+// If the visual is 0 (which is the case for pixmaps) or doesn't have the
+// depth given, then the first TrueColor or DirectColor visual with that
+// depth is used.
+func newPixelFormat(X *xgbutil.XUtil, depth byte,
+	visual xproto.Visualid) (*pixelFormat, error) {
+
+	formatsLck.Lock()
+	defer formatsLck.Unlock()
+
+	key := formatKey{X, depth, visual}
+	if f, ok := formats[key]; ok {
+		return f, nil
+	}
+
+	format := GetFormat(X, depth)
+	if format == nil {
+		return nil, fmt.Errorf("Could not find a pixmap format for "+
+			"depth %d.", depth)
+	}
+	switch format.BitsPerPixel {
+	case 8, 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("Depth %d has an unsupported value for "+
+			"bits-per-pixel: %d", depth, format.BitsPerPixel)
+	}
+
+	vinfo := getVisualInfo(X, depth, visual)
+	if vinfo == nil {
+		vinfo = findVisualInfo(X, depth)
+	}
+
+	var r, g, b uint32
+	switch {
+	case vinfo != nil:
+		r, g, b = vinfo.RedMask, vinfo.GreenMask, vinfo.BlueMask
+	case depth >= 24:
+		// Pixmaps can have depths that no visual has. In that case,
+		// the masks are up to us, so we use the common ones.
+		r, g, b = 0xff0000, 0xff00, 0xff
+	default:
+		return nil, fmt.Errorf("Could not find a TrueColor or DirectColor "+
+			"visual with depth %d.", depth)
+	}
+
+	// Any bits of the depth that aren't used by red, green or blue are
+	// used for alpha. (e.g., ARGB visuals with depth 32.)
+	all := uint32(1<<uint(depth) - 1)
+	if depth >= 32 {
+		all = 0xffffffff
+	}
+
+	f := &pixelFormat{
+		depth: depth,
+		bpp:   int(format.BitsPerPixel),
+		pad:   int(format.ScanlinePad),
+		msb:   X.Setup().ImageByteOrder == xproto.ImageOrderMSBFirst,
+		red:   newChannel(r),
+		green: newChannel(g),
+		blue:  newChannel(b),
+		alpha: newChannel(all &^ (r | g | b)),
+	}
+	formats[key] = f
+	return f, nil
+}
+
+// newChannel creates a channel from a mask of the bits in a pixel that
+// correspond to a color component.
+func newChannel(mask uint32) channel {
+	c := channel{}
+	if mask == 0 {
+		return c
+	}
+	for mask&1 == 0 {
+		mask >>= 1
+		c.shift++
+	}
+	if mask > 0xffff { // no sane visual has components this wide
+		return channel{}
+	}
+	c.max = mask
+
+	for v := range c.enc {
+		c.enc[v] = ((uint32(v)*c.max + 127) / 255) << c.shift
+	}
+	c.dec = make([]uint8, c.max+1)
+	for v := range c.dec {
+		c.dec[v] = uint8((uint32(v)*255 + c.max/2) / c.max)
+	}
+	return c
+}
+
+// findVisualInfo returns the first TrueColor or DirectColor visual with the
+// depth given, or nil if there isn't one.
+func findVisualInfo(X *xgbutil.XUtil, depth byte) *xproto.VisualInfo {
+	for _, depthInfo := range X.Screen().AllowedDepths {
+		if depthInfo.Depth != depth {
+			continue
+		}
+		for _, visual := range depthInfo.Visuals {
+			switch visual.Class {
+			case xproto.VisualClassTrueColor, xproto.VisualClassDirectColor:
+				return &visual
+			}
+		}
+	}
+	return nil
+}
+
+// native returns true if image data in this format has exactly the same
+// layout as the Pix data of an Image, in which case no conversion is needed.
+// Formats with alpha are never native, since they need to be premultiplied.
+func (f *pixelFormat) native() bool {
+	return f.bpp == 32 && !f.msb &&
+		f.red.shift == 16 && f.red.max == 0xff &&
+		f.green.shift == 8 && f.green.max == 0xff &&
+		f.blue.shift == 0 && f.blue.max == 0xff &&
+		f.alpha.max == 0
+}
+
+// stride returns the number of bytes in a scanline of 'width' pixels.
+func (f *pixelFormat) stride(width int) int {
+	bits := width * f.bpp
+	if f.pad > 0 && bits%f.pad != 0 {
+		bits += f.pad - bits%f.pad
+	}
+	return bits / 8
+}
+
+// pixel returns the value of the pixel starting at the beginning of 'buf'.
+func (f *pixelFormat) pixel(buf []byte) uint32 {
+	n := f.bpp / 8
+	var p uint32
+	for i := 0; i < n; i++ {
+		if f.msb {
+			p = p<<8 | uint32(buf[i])
+		} else {
+			p |= uint32(buf[i]) << uint(8*i)
+		}
+	}
+	return p
+}
+
+// setPixel writes the pixel value 'p' to the beginning of 'buf'.
+func (f *pixelFormat) setPixel(buf []byte, p uint32) {
+	n := f.bpp / 8
+	for i := 0; i < n; i++ {
+		if f.msb {
+			buf[n-1-i] = uint8(p >> uint(8*i))
+		} else {
+			buf[i] = uint8(p >> uint(8*i))
+		}
+	}
+}
+
+// encode writes the pixels of 'im' into 'dst' in this format. Each scanline
+// in 'dst' starts 'stride' bytes after the previous one.
+func (f *pixelFormat) encode(dst []byte, stride int, im *Image) {
+	width := im.Rect.Dx()
+	for y := im.Rect.Min.Y; y < im.Rect.Max.Y; y++ {
+		row := dst[(y-im.Rect.Min.Y)*stride:]
+		src := im.Pix[im.PixOffset(im.Rect.Min.X, y):]
+		if f.native() {
+			copy(row[:4*width], src)
+			continue
+		}
+
+		bytesPer := f.bpp / 8
+		for x := 0; x < width; x++ {
+			i := 4 * x
+			b, g, r, a := src[i], src[i+1], src[i+2], src[i+3]
+			if f.alpha.max != 0 && a != 0xff {
+				b, g, r = premultiply(b, a), premultiply(g, a),
+					premultiply(r, a)
+			}
+			f.setPixel(row[x*bytesPer:],
+				f.blue.enc[b]|f.green.enc[g]|f.red.enc[r]|f.alpha.enc[a])
+		}
+	}
+}
+
+// decode reads the pixels in 'src' into 'im', assuming 'src' is image data in
+// this format with the same size as 'im'. Pixels are opaque unless this
+// format has alpha.
+func (f *pixelFormat) decode(im *Image, src []byte) {
+	width := im.Rect.Dx()
+	stride := f.stride(width)
+	bytesPer := f.bpp / 8
+	for y := im.Rect.Min.Y; y < im.Rect.Max.Y; y++ {
+		row := src[(y-im.Rect.Min.Y)*stride:]
+		dst := im.Pix[im.PixOffset(im.Rect.Min.X, y):]
+		for x := 0; x < width; x++ {
+			p := f.pixel(row[x*bytesPer:])
+			i := 4 * x
+			dst[i+0] = f.blue.value(p)
+			dst[i+1] = f.green.value(p)
+			dst[i+2] = f.red.value(p)
+			dst[i+3] = f.alpha.value(p)
+			if a := dst[i+3]; f.alpha.max != 0 && a != 0xff {
+				dst[i+0] = unpremultiply(dst[i+0], a)
+				dst[i+1] = unpremultiply(dst[i+1], a)
+				dst[i+2] = unpremultiply(dst[i+2], a)
+			}
+		}
+	}
+}
+
+// value returns the 8 bit value of this component in the pixel 'p'.
+// Missing components (i.e., alpha) are always fully opaque.
+func (c *channel) value(p uint32) uint8 {
+	if c.max == 0 {
+		return 0xff
+	}
+	return c.dec[(p>>c.shift)&c.max]
+}
+
+// premultiply multiplies a color component by alpha.
+func premultiply(c, a uint8) uint8 {
+	return uint8((uint32(c)*uint32(a) + 127) / 255)
+}
+
+// unpremultiply undoes premultiply, as best it can.
+func unpremultiply(c, a uint8) uint8 {
+	if a == 0 {
+		return 0
+	}
+	v := (uint32(c)*255 + uint32(a)/2) / uint32(a)
+	if v > 0xff {
+		v = 0xff
+	}
+	return uint8(v)
+}
@@ -0,0 +1,240 @@
+package xgraphics
+
+/*
+xgraphics/icon.go contains functions for publishing a window's icon, which is
+the inverse of FindIcon.
+
+Window managers and panels that follow EWMH read icons from _NET_WM_ICON,
+which can hold the same icon at several sizes, so that each can pick the one
+closest to what it needs. Older window managers only read the icon pixmap and
+mask in WM_HINTS. The icon pixmap has the depth of the root window (so it
+can't be translucent), and the mask is a bitmap where pixels are either fully
+opaque or fully transparent.
+
+SetIcon does both. The pixmaps it creates can't be freed while a window
+manager may still read them, so they are remembered for each window and
+freed when SetIcon is called again for the same window (or by FreeIcon).
+*/
+
+import (
+	"image"
+	"sync"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/ewmh"
+	"github.com/BurntSushi/xgbutil/icccm"
+)
+
+// IconSizes are the sizes of the icons put in _NET_WM_ICON by SetIcon when
+// no sizes are given. (Sizes bigger than the image are skipped.)
+var IconSizes = []int{16, 22, 24, 32, 48, 64, 128}
+
+// maxIcccmIconSize is the size of the icon pixmap in WM_HINTS when the window
+// manager doesn't say which sizes it prefers in WM_ICON_SIZE.
+const maxIcccmIconSize = 64
+
+// iconKey identifies a window in the icons map.
+type iconKey struct {
+	X   *xgbutil.XUtil
+	win xproto.Window
+}
+
+// icons remembers the icon pixmap and mask created by SetIcon for each
+// window, so that they can be freed when the icon is replaced.
+var (
+	icons    = make(map[iconKey][2]xproto.Pixmap)
+	iconsLck = &sync.Mutex{}
+)
+
+// EwmhIcon converts the image to an icon suitable for _NET_WM_ICON, at the
+// image's size. It is the inverse of NewEwmhIcon.
+func (im *Image) EwmhIcon() *ewmh.WmIcon {
+	r := im.Rect
+	icon := &ewmh.WmIcon{
+		Width:  uint(r.Dx()),
+		Height: uint(r.Dy()),
+		Data:   make([]uint, 0, r.Dx()*r.Dy()),
+	}
+
+	var x, y, i int
+	for y = r.Min.Y; y < r.Max.Y; y++ {
+		for x = r.Min.X; x < r.Max.X; x++ {
+			i = im.PixOffset(x, y)
+			icon.Data = append(icon.Data, uint(im.Pix[i+3])<<24|
+				uint(im.Pix[i+2])<<16|uint(im.Pix[i+1])<<8|uint(im.Pix[i]))
+		}
+	}
+	return icon
+}
+
+// EwmhIcons converts the image to icons suitable for _NET_WM_ICON, one for
+// each size given. Each icon is square, and the image is scaled (with the
+// Lanczos filter) to fit inside it, keeping its aspect ratio. Sizes bigger
+// than the image are skipped. If the image is smaller than all of them, the
+// only icon is the image at its own size.
+func (im *Image) EwmhIcons(sizes ...int) []ewmh.WmIcon {
+	w, h := im.Rect.Dx(), im.Rect.Dy()
+	icons := make([]ewmh.WmIcon, 0, len(sizes))
+	seen := make(map[int]bool)
+	for _, size := range sizes {
+		if size <= 0 || (size > w && size > h) || seen[size] {
+			continue
+		}
+		seen[size] = true
+		icons = append(icons, *iconFit(im, size, size).EwmhIcon())
+	}
+	if len(icons) == 0 {
+		icons = append(icons, *im.EwmhIcon())
+	}
+	return icons
+}
+
+// IcccmIcon creates an icon pixmap and mask suitable for WM_HINTS from the
+// image. If the image is bigger than the size given, it is scaled to fit
+// inside it. (Unless either of width or height is 0.)
+// The icon pixmap has the depth of the root window, and the mask is a bitmap
+// where pixels with an alpha value of at least 128 are opaque. The caller is
+// responsible for freeing both pixmaps with FreePixmap.
+func (im *Image) IcccmIcon(width, height int) (pixmap,
+	mask xproto.Pixmap, err error) {
+
+	icon := im
+	w, h := im.Rect.Dx(), im.Rect.Dy()
+	if width > 0 && height > 0 && (w > width || h > height) {
+		icon = iconFit(im, width, height)
+	}
+
+	// Draw the image on to its own pixmap with the depth of the root window,
+	// without touching the pixmap of this image.
+	pimg := newImage(im.X, image.Rect(0, 0, icon.Rect.Dx(), icon.Rect.Dy()))
+	copyImage(pimg, icon)
+	if err = pimg.CreatePixmap(); err != nil {
+		return 0, 0, err
+	}
+	if err = pimg.XDrawChecked(); err != nil {
+		FreePixmap(im.X, pimg.Pixmap)
+		return 0, 0, err
+	}
+
+	mask, err = pimg.CreateBitmap(func(c BGRA) bool { return c.A >= 0x80 })
+	if err != nil {
+		FreePixmap(im.X, pimg.Pixmap)
+		return 0, 0, err
+	}
+	return pimg.Pixmap, mask, nil
+}
+
+// SetIcon publishes the image as the icon of the window given, for both EWMH
+// and ICCCM window managers. _NET_WM_ICON is set to the image at each of the
+// sizes given (or IconSizes if there are none). The icon pixmap and mask in
+// WM_HINTS are set to the image, shrunk if necessary to fit the largest size
+// in the root window's WM_ICON_SIZE (or 64x64 if it isn't set). Other values
+// in WM_HINTS are kept.
+//
+// The pixmaps created for WM_HINTS by a previous call to SetIcon for the same
+// window are freed.
+func SetIcon(X *xgbutil.XUtil, wid xproto.Window, im *Image,
+	sizes ...int) error {
+
+	if len(sizes) == 0 {
+		sizes = IconSizes
+	}
+	err := ewmh.WmIconSet(X, wid, im.EwmhIcons(sizes...))
+	if err != nil {
+		return err
+	}
+
+	width, height := maxIcccmIconSize, maxIcccmIconSize
+	if isize, err := icccm.WmIconSizeGet(X, X.RootWin()); err == nil &&
+		isize.MaxWidth > 0 && isize.MaxHeight > 0 {
+
+		width, height = int(isize.MaxWidth), int(isize.MaxHeight)
+	}
+	pixmap, mask, err := im.IcccmIcon(width, height)
+	if err != nil {
+		return err
+	}
+
+	hints, err := icccm.WmHintsGet(X, wid)
+	if err != nil {
+		hints = &icccm.Hints{}
+	}
+	hints.Flags |= icccm.HintIconPixmap | icccm.HintIconMask
+	hints.IconPixmap, hints.IconMask = pixmap, mask
+	if err := icccm.WmHintsSet(X, wid, hints); err != nil {
+		FreePixmap(X, pixmap)
+		FreePixmap(X, mask)
+		return err
+	}
+
+	iconsLck.Lock()
+	defer iconsLck.Unlock()
+
+	key := iconKey{X, wid}
+	if old, ok := icons[key]; ok {
+		FreePixmap(X, old[0])
+		FreePixmap(X, old[1])
+	}
+	icons[key] = [2]xproto.Pixmap{pixmap, mask}
+	return nil
+}
+
+// FreeIcon frees the icon pixmap and mask created by SetIcon for the window
+// given. It should be called when the window is destroyed. (It doesn't change
+// WM_HINTS.)
+func FreeIcon(X *xgbutil.XUtil, wid xproto.Window) {
+	iconsLck.Lock()
+	defer iconsLck.Unlock()
+
+	key := iconKey{X, wid}
+	if old, ok := icons[key]; ok {
+		FreePixmap(X, old[0])
+		FreePixmap(X, old[1])
+		delete(icons, key)
+	}
+}
+
+// iconFit returns the image scaled to fit inside width x height, keeping its
+// aspect ratio and centered. If the image already fits exactly, it is
+// returned as is.
+func iconFit(im *Image, width, height int) *Image {
+	w, h := im.Rect.Dx(), im.Rect.Dy()
+	if w == width && h == height {
+		return im
+	}
+
+	sw, sh := width, height
+	if w*height > h*width {
+		sh = (h*width + w/2) / w
+	} else {
+		sw = (w*height + h/2) / h
+	}
+	if sw < 1 {
+		sw = 1
+	}
+	if sh < 1 {
+		sh = 1
+	}
+	scaled := im.ScaleWith(sw, sh, ScaleLanczos)
+	if sw == width && sh == height {
+		return scaled
+	}
+
+	icon := newImage(im.X, image.Rect(0, 0, width, height))
+	icon.ARGB = im.ARGB
+	x, y := (width-sw)/2, (height-sh)/2
+	copyImage(icon.SubImage(image.Rect(x, y, x+sw, y+sh)).(*Image), scaled)
+	return icon
+}
+
+// copyImage copies the pixels of 'src' to 'dst', which must be the same size.
+func copyImage(dst, src *Image) {
+	for y := 0; y < src.Rect.Dy(); y++ {
+		si := src.PixOffset(src.Rect.Min.X, src.Rect.Min.Y+y)
+		di := dst.PixOffset(dst.Rect.Min.X, dst.Rect.Min.Y+y)
+		copy(dst.Pix[di:di+4*dst.Rect.Dx()], src.Pix[si:si+4*src.Rect.Dx()])
+	}
+	dst.Damage(dst.Rect)
+}
@@ -0,0 +1,438 @@
+package xgraphics
+
+/*
+xgraphics/icontheme.go contains functions for finding icons by name in the
+installed icon themes, following the freedesktop.org Icon Theme
+specification, and for finding the icon of an application by its WM_CLASS.
+
+Icon themes are directories in the "icons" directory of $XDG_DATA_HOME and
+each of $XDG_DATA_DIRS (and ~/.icons). Each theme has an index.theme file that
+lists its sub-directories and the size of the icons in each, and the themes
+it inherits from. An icon is looked up in the theme, then in the themes it
+inherits from, then in the "hicolor" theme (which every theme falls back to)
+and finally in the base directories themselves and /usr/share/pixmaps, which
+is where older applications put their icons.
+
+Within a theme, a directory with icons that have exactly the size requested
+is preferred. Otherwise, the icon from the directory with the closest size is
+used. Only PNG and XPM icons are used, since there is no SVG decoder. (See
+xpm.go.)
+
+Applications don't usually set an icon name on their windows. Instead, the
+icon is listed in the application's .desktop file, which can be found by its
+WM_CLASS: either its StartupWMClass key is the same as the class, or its file
+name is the same as the class or instance.
+*/
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/icccm"
+)
+
+// IconTheme is the name of the icon theme used to look up icons, like
+// "Adwaita" or "breeze". Icons are always looked up in "hicolor" too.
+var IconTheme = "hicolor"
+
+// iconExtensions are the extensions of the icon files that are used, in order
+// of preference.
+var iconExtensions = []string{".png", ".xpm"}
+
+// maxThemeIconSize is the size of the icon that is looked up when the largest
+// possible icon is wanted.
+const maxThemeIconSize = 256
+
+// iconTheme is a parsed index.theme file.
+type iconTheme struct {
+	name     string
+	bases    []string // the theme's directory in each base directory
+	inherits []string
+	dirs     []iconDir
+}
+
+// iconDir is a sub-directory of an icon theme.
+type iconDir struct {
+	path                   string
+	size, minSize, maxSize int
+	threshold              int
+	kind                   string // "Fixed", "Scalable" or "Threshold"
+}
+
+// themes caches parsed icon themes by name. A nil theme means that the theme
+// isn't installed.
+var (
+	themes    = make(map[string]*iconTheme)
+	themesLck = &sync.Mutex{}
+)
+
+// desktopIcons caches the icons named by .desktop files. See desktopIcon.
+var (
+	desktopIcons    map[string]string
+	desktopIconsLck = &sync.Mutex{}
+)
+
+// IconThemeDirs returns the base directories that are searched for icon
+// themes, in order of preference.
+func IconThemeDirs() []string {
+	var dirs []string
+	home := os.Getenv("HOME")
+	for i, dir := range dataDirs() {
+		dirs = append(dirs, filepath.Join(dir, "icons"))
+		if i == 0 && home != "" {
+			dirs = append(dirs, filepath.Join(home, ".icons"))
+		}
+	}
+	return dirs
+}
+
+// ThemeIconPath returns the path of the icon file with the name given in
+// IconTheme (or the themes it inherits from), with the size closest to the
+// size given. 'name' may also be an absolute path to an icon file, which is
+// returned as is.
+func ThemeIconPath(name string, size int) (string, error) {
+	if filepath.IsAbs(name) {
+		if _, err := os.Stat(name); err != nil {
+			return "", err
+		}
+		return name, nil
+	}
+
+	// Some .desktop files name icons with their extension.
+	if ext := filepath.Ext(name); ext == ".svg" || ext == ".png" ||
+		ext == ".xpm" {
+
+		name = strings.TrimSuffix(name, ext)
+	}
+
+	// Look in the theme and each of its parents, and then hicolor.
+	names := []string{IconTheme}
+	seen := map[string]bool{IconTheme: true}
+	for i := 0; i < len(names); i++ {
+		if theme := loadIconTheme(names[i]); theme != nil {
+			if path := theme.lookup(name, size); path != "" {
+				return path, nil
+			}
+			for _, parent := range theme.inherits {
+				if !seen[parent] {
+					names = append(names, parent)
+					seen[parent] = true
+				}
+			}
+		}
+		if i == len(names)-1 && !seen["hicolor"] {
+			names = append(names, "hicolor")
+			seen["hicolor"] = true
+		}
+	}
+
+	// Finally, look for unthemed icons.
+	dirs := append(IconThemeDirs(), "/usr/share/pixmaps")
+	for _, dir := range dirs {
+		if path := findIconFile(dir, name); path != "" {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("Could not find an icon named '%s' in the '%s' "+
+		"icon theme.", name, IconTheme)
+}
+
+// NewThemeIcon finds the icon with the name given with ThemeIconPath, and
+// returns it as an xgraphics.Image. The icon isn't scaled, so its size may be
+// different than the size given.
+func NewThemeIcon(X *xgbutil.XUtil, name string, size int) (*Image, error) {
+	path, err := ThemeIconPath(name, size)
+	if err != nil {
+		return nil, err
+	}
+	return NewFileName(X, path)
+}
+
+// WmClassIconName returns the name of the icon of an application with the
+// WM_CLASS given, from the application's .desktop file. If there is no such
+// file, the instance name in lower case is returned, since that is usually
+// the name of the application's icon. (The name may be an absolute path.)
+func WmClassIconName(class *icccm.WmClass) string {
+	if icon := desktopIcon(class); icon != "" {
+		return icon
+	}
+	return strings.ToLower(class.Instance)
+}
+
+// findIconTheme helps FindIcon by trying to return the icon of the window's
+// application from the icon theme.
+func findIconTheme(X *xgbutil.XUtil, class *icccm.WmClass,
+	width, height int) (*Image, error) {
+
+	size := width
+	if height > size {
+		size = height
+	}
+	if size <= 0 {
+		size = maxThemeIconSize
+	}
+
+	names := []string{WmClassIconName(class)}
+	if lower := strings.ToLower(class.Class); lower != names[0] {
+		names = append(names, lower)
+	}
+	var err error
+	for _, name := range names {
+		var icon *Image
+		if icon, err = NewThemeIcon(X, name, size); err == nil {
+			return icon, nil
+		}
+	}
+	return nil, err
+}
+
+// lookup returns the path of the icon with the name given in this theme, with
+// the size closest to 'size'. (Without looking in the parents of this theme.)
+// An empty string is returned if the theme doesn't have the icon.
+func (t *iconTheme) lookup(name string, size int) string {
+	best, bestDist := "", -1
+	for _, dir := range t.dirs {
+		dist := dir.distance(size)
+		if bestDist >= 0 && dist >= bestDist {
+			continue
+		}
+		for _, base := range t.bases {
+			path := findIconFile(filepath.Join(base, dir.path), name)
+			if path == "" {
+				continue
+			}
+			if dist == 0 {
+				return path
+			}
+			best, bestDist = path, dist
+			break
+		}
+	}
+	return best
+}
+
+// distance returns how far the size of the icons in this directory is from
+// 'size'. It is 0 if the directory has icons of that size.
+func (d iconDir) distance(size int) int {
+	min, max := d.size, d.size
+	switch d.kind {
+	case "Scalable":
+		min, max = d.minSize, d.maxSize
+	case "Threshold":
+		min, max = d.size-d.threshold, d.size+d.threshold
+	}
+	switch {
+	case size < min:
+		return min - size
+	case size > max:
+		return size - max
+	}
+	return 0
+}
+
+// findIconFile returns the path of the icon file with the name given in the
+// directory given, or an empty string if there isn't one.
+func findIconFile(dir, name string) string {
+	for _, ext := range iconExtensions {
+		path := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// loadIconTheme returns the icon theme with the name given, parsing its
+// index.theme file the first time. nil is returned if the theme isn't
+// installed.
+func loadIconTheme(name string) *iconTheme {
+	themesLck.Lock()
+	defer themesLck.Unlock()
+
+	if theme, ok := themes[name]; ok {
+		return theme
+	}
+
+	var theme *iconTheme
+	for _, dir := range IconThemeDirs() {
+		base := filepath.Join(dir, name)
+		if fi, err := os.Stat(base); err != nil || !fi.IsDir() {
+			continue
+		}
+		if theme == nil {
+			// The first index.theme found is the one that is used.
+			sections, err := readIniFile(filepath.Join(base, "index.theme"))
+			if err != nil {
+				continue
+			}
+			theme = newIconTheme(name, sections)
+		}
+		theme.bases = append(theme.bases, base)
+	}
+	themes[name] = theme
+	return theme
+}
+
+// newIconTheme creates an icon theme from the sections of its index.theme
+// file.
+func newIconTheme(name string,
+	sections map[string]map[string]string) *iconTheme {
+
+	main := sections["Icon Theme"]
+	theme := &iconTheme{name: name}
+	for _, parent := range strings.Split(main["Inherits"], ",") {
+		if parent = strings.TrimSpace(parent); parent != "" {
+			theme.inherits = append(theme.inherits, parent)
+		}
+	}
+	for _, path := range strings.Split(main["Directories"], ",") {
+		path = strings.TrimSpace(path)
+		keys, ok := sections[path]
+		if path == "" || !ok {
+			continue
+		}
+
+		// Directories with icons for high DPI displays are skipped.
+		if scale := iniInt(keys, "Scale", 1); scale != 1 {
+			continue
+		}
+		dir := iconDir{
+			path:      path,
+			size:      iniInt(keys, "Size", 0),
+			threshold: iniInt(keys, "Threshold", 2),
+			kind:      keys["Type"],
+		}
+		dir.minSize = iniInt(keys, "MinSize", dir.size)
+		dir.maxSize = iniInt(keys, "MaxSize", dir.size)
+		if dir.kind == "" {
+			dir.kind = "Threshold"
+		}
+		theme.dirs = append(theme.dirs, dir)
+	}
+	return theme
+}
+
+// desktopIcon returns the icon named in the .desktop file of the application
+// with the WM_CLASS given, or an empty string if there is no such file.
+func desktopIcon(class *icccm.WmClass) string {
+	desktopIconsLck.Lock()
+	defer desktopIconsLck.Unlock()
+
+	if desktopIcons == nil {
+		desktopIcons = readDesktopIcons()
+	}
+	keys := []string{
+		"wmclass:" + strings.ToLower(class.Class),
+		"wmclass:" + strings.ToLower(class.Instance),
+		"file:" + strings.ToLower(class.Instance),
+		"file:" + strings.ToLower(class.Class),
+	}
+	for _, key := range keys {
+		if icon, ok := desktopIcons[key]; ok {
+			return icon
+		}
+	}
+	return ""
+}
+
+// readDesktopIcons reads the Icon key of every .desktop file in the
+// "applications" directories, and returns a map from StartupWMClass keys
+// (prefixed with "wmclass:") and file names (prefixed with "file:") to icon
+// names. Both are in lower case. The last component of reverse DNS style file
+// names (like org.gnome.Nautilus) is used too. Files in directories that are
+// more preferred win.
+func readDesktopIcons() map[string]string {
+	icons := make(map[string]string)
+	add := func(key, icon string) {
+		if _, ok := icons[key]; !ok {
+			icons[key] = icon
+		}
+	}
+
+	for _, dir := range dataDirs() {
+		root := filepath.Join(dir, "applications")
+		filepath.Walk(root, func(path string, fi os.FileInfo,
+			err error) error {
+
+			if err != nil || fi.IsDir() || filepath.Ext(path) != ".desktop" {
+				return nil
+			}
+			sections, err := readIniFile(path)
+			if err != nil {
+				return nil
+			}
+			entry := sections["Desktop Entry"]
+			icon := entry["Icon"]
+			if icon == "" {
+				return nil
+			}
+
+			if wmclass := entry["StartupWMClass"]; wmclass != "" {
+				add("wmclass:"+strings.ToLower(wmclass), icon)
+			}
+			base := strings.ToLower(strings.TrimSuffix(fi.Name(), ".desktop"))
+			add("file:"+base, icon)
+			if i := strings.LastIndex(base, "."); i >= 0 {
+				add("file:"+base[i+1:], icon)
+			}
+			return nil
+		})
+	}
+	return icons
+}
+
+// readIniFile reads a file in the format of index.theme and .desktop files,
+// and returns its keys by section. Comments and localized keys are skipped.
+func readIniFile(path string) (map[string]map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sections := make(map[string]map[string]string)
+	var section map[string]string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#':
+			continue
+		case line[0] == '[' && line[len(line)-1] == ']':
+			name := line[1 : len(line)-1]
+			if section = sections[name]; section == nil {
+				section = make(map[string]string)
+				sections[name] = section
+			}
+			continue
+		}
+
+		i := strings.Index(line, "=")
+		if section == nil || i < 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:i])
+		if strings.Contains(key, "[") {
+			continue
+		}
+		if _, ok := section[key]; !ok {
+			section[key] = strings.TrimSpace(line[i+1:])
+		}
+	}
+	return sections, scanner.Err()
+}
+
+// iniInt returns the integer value of a key, or 'def' if it isn't set or
+// isn't an integer.
+func iniInt(keys map[string]string, key string, def int) int {
+	if n, err := strconv.Atoi(keys[key]); err == nil {
+		return n
+	}
+	return def
+}
@@ -6,14 +6,16 @@ xgraphics/image.go contains an implementation of the draw.Image interface.
 RGBA could feasibly be used, but the representation of image data is dependent
 upon the configuration of the X server.
 
-For the time being, I'm hard-coding a lot of that configuration for the common
-case. Namely:
+Image data is stored in the format used by the most common configuration.
+Namely:
 
 Byte order: least significant byte first
 Depth: 24
 Bits per pixel: 32
 
-This will have to be fixed for this to be truly compatible with any X server.
+This means that image data can be sent to most X servers without any
+conversion. Other configurations (like 16 bit or 30 bit depths, or big-endian
+servers) are converted when the image is drawn or read. See format.go.
 
 Most of the code is based heavily on the implementation of common images in
 the Go standard library.
@@ -29,7 +31,6 @@ import (
 	"io"
 	"os"
 
-	"github.com/BurntSushi/graphics-go/graphics"
 	"github.com/BurntSushi/xgb/xproto"
 
 	"github.com/BurntSushi/xgbutil"
@@ -64,6 +65,17 @@ type Image struct {
 	// Namely, sub-images cannot be set as surfaces and sub-images, when
 	// being drawn, only have its pixels sent to X instead of the whole image.
 	Subimg bool
+
+	// Whether this image is painted to windows with a 32 bit ARGB visual.
+	// (See xwindow.CreateARGB.) When true, the image's pixmap has a depth
+	// of 32 bits and its alpha channel is sent to X, so that a compositing
+	// manager can show the window as translucent. This must be set before
+	// the pixmap is created. (i.e., before XSurfaceSet or CreatePixmap.)
+	ARGB bool
+
+	// The regions of the image modified since they were last sent to X.
+	// See dirty.go.
+	dirty *dirtyRegion
 }
 
 // New returns a new instance of Image with colors initialized to black
@@ -85,6 +97,12 @@ func New(X *xgbutil.XUtil, r image.Rectangle) *Image {
 		}
 	}
 
+	return newImage(X, r)
+}
+
+// newImage is like New, except it never creates a new connection. The image
+// isn't associated with a connection if 'X' is nil.
+func newImage(X *xgbutil.XUtil, r image.Rectangle) *Image {
 	return &Image{
 		X:      X,
 		Pixmap: 0,
@@ -92,26 +110,32 @@ func New(X *xgbutil.XUtil, r image.Rectangle) *Image {
 		Stride: 4 * r.Dx(),
 		Rect:   r,
 		Subimg: false,
+		dirty:  &dirtyRegion{rects: []image.Rectangle{r}},
 	}
 }
 
 // Destroy frees the pixmap resource being used by this image.
 // It should be called whenever the image will no longer be drawn or painted.
+// If a new pixmap is created for the image later, the entire image is
+// considered modified. (So that Flush sends all of it.)
 func (im *Image) Destroy() {
 	if im.Pixmap != 0 {
 		xproto.FreePixmap(im.X.Conn(), im.Pixmap)
 		im.Pixmap = 0
 	}
+	if im.dirty != nil && !im.Subimg {
+		im.dirty.all(im.Rect)
+	}
 }
 
-// Scale will scale the image to the size provided.
+// Scale will scale the image to the size provided, using the bicubic filter.
+// (Use ScaleWith to pick a different filter.)
 // Note that this will destroy the current pixmap associated with this image.
 // After scaling, XSurfaceSet will need to be called for each window that
 // this image is painted to. (And obviously, XDraw and XPaint will need to
 // be called again.)
 func (im *Image) Scale(width, height int) *Image {
-	dimg := New(im.X, image.Rect(0, 0, width, height))
-	graphics.Scale(dimg, im)
+	dimg := im.ScaleWith(width, height, ScaleBicubic)
 	im.Destroy()
 
 	return dimg
@@ -162,6 +186,7 @@ func (im *Image) Set(x, y int, c color.Color) {
 		return
 	}
 
+	im.damagePixel(x, y)
 	i := im.PixOffset(x, y)
 	cc := BGRAModel.Convert(c).(BGRA)
 	im.Pix[i] = cc.B
@@ -176,6 +201,7 @@ func (im *Image) SetBGRA(x, y int, c BGRA) {
 		return
 	}
 
+	im.damagePixel(x, y)
 	i := im.PixOffset(x, y)
 	im.Pix[i] = c.B
 	im.Pix[i+1] = c.G
@@ -186,9 +212,34 @@ func (im *Image) SetBGRA(x, y int, c BGRA) {
 // For transforms every pixel color to the color returned by 'each' given
 // an (x, y) position.
 func (im *Image) For(each func(x, y int) BGRA) {
-	for x := im.Rect.Min.X; x < im.Rect.Max.X; x++ {
-		for y := im.Rect.Min.Y; y < im.Rect.Max.Y; y++ {
-			im.SetBGRA(x, y, each(x, y))
+	im.Damage(im.Rect)
+	im.forBand(im.Rect, each)
+}
+
+// ForParallel is like For, but splits large images between multiple
+// goroutines (see SetWorkers). So 'each' is called from multiple goroutines
+// at the same time, and must be safe to do so. Pixels are visited in no
+// particular order.
+func (im *Image) ForParallel(each func(x, y int) BGRA) {
+	im.Damage(im.Rect)
+	parallel(im.Rect, func(band image.Rectangle) {
+		im.forBand(band, each)
+	})
+}
+
+// forBand does the work of For on the pixels in 'band'.
+func (im *Image) forBand(band image.Rectangle, each func(x, y int) BGRA) {
+	var x, y, i int
+	var c BGRA
+	for y = band.Min.Y; y < band.Max.Y; y++ {
+		for x = band.Min.X; x < band.Max.X; x++ {
+			i = im.PixOffset(x, y)
+			c = each(x, y)
+
+			im.Pix[i+0] = c.B
+			im.Pix[i+1] = c.G
+			im.Pix[i+2] = c.R
+			im.Pix[i+3] = c.A
 		}
 	}
 }
@@ -196,10 +247,27 @@ func (im *Image) For(each func(x, y int) BGRA) {
 // ForExp is like For, but bypasses image.Color types.
 // (So it should be faster.)
 func (im *Image) ForExp(each func(x, y int) (r, g, b, a uint8)) {
+	im.Damage(im.Rect)
+	im.forExpBand(im.Rect, each)
+}
+
+// ForExpParallel is like ForExp, but splits large images between multiple
+// goroutines, like ForParallel.
+func (im *Image) ForExpParallel(each func(x, y int) (r, g, b, a uint8)) {
+	im.Damage(im.Rect)
+	parallel(im.Rect, func(band image.Rectangle) {
+		im.forExpBand(band, each)
+	})
+}
+
+// forExpBand does the work of ForExp on the pixels in 'band'.
+func (im *Image) forExpBand(band image.Rectangle,
+	each func(x, y int) (r, g, b, a uint8)) {
+
 	var x, y, i int
 	var r, g, b, a uint8
-	for x = im.Rect.Min.X; x < im.Rect.Max.X; x++ {
-		for y = im.Rect.Min.Y; y < im.Rect.Max.Y; y++ {
+	for y = band.Min.Y; y < band.Max.Y; y++ {
+		for x = band.Min.X; x < band.Max.X; x++ {
 			i = im.PixOffset(x, y)
 			r, g, b, a = each(x, y)
 
@@ -235,6 +303,8 @@ func (im *Image) SubImage(r image.Rectangle) image.Image {
 		Stride: im.Stride,
 		Rect:   r,
 		Subimg: true,
+		ARGB:   im.ARGB,
+		dirty:  im.dirty,
 	}
 }
 
@@ -260,8 +330,9 @@ func (im *Image) Window(parent xproto.Window) *xwindow.Window {
 	return win
 }
 
-// BGRA is the representation of color for each pixel in an X pixmap.
-// BUG(burntsushi): This is hard-coded when it shouldn't be.
+// BGRA is the representation of color for each pixel in an Image. It
+// corresponds to the pixel format used by X servers with a depth of 24 or 32.
+// (Image data is converted for X servers using other pixel formats.)
 type BGRA struct {
 	B, G, R, A uint8
 }
@@ -29,7 +29,13 @@ import (
 // formats. (i.e., *image.RGBA.)
 func NewConvert(X *xgbutil.XUtil, img image.Image) *Image {
 	ximg := New(X, img.Bounds())
+	convert(ximg, img)
+	return ximg
+}
 
+// convert converts any image to the BGRA format of 'ximg', which must have
+// the same bounds.
+func convert(ximg *Image, img image.Image) {
 	// I've attempted to optimize this loop.
 	// It actually takes more time to convert an image than to send the bytes
 	// over the wire. (I suspect 'copy' is super fast, which can be used in
@@ -48,6 +54,8 @@ func NewConvert(X *xgbutil.XUtil, img image.Image) *Image {
 		convertRGBA64(ximg, concrete)
 	case *image.YCbCr:
 		convertYCbCr(ximg, concrete)
+	case *image.Paletted:
+		convertPaletted(ximg, concrete)
 	case *Image:
 		convertXImage(ximg, concrete)
 	default:
@@ -55,7 +63,6 @@ func NewConvert(X *xgbutil.XUtil, img image.Image) *Image {
 			"Optimization for this image type hasn't been added yet.", img)
 		convertImage(ximg, img)
 	}
-	return ximg
 }
 
 // NewFileName uses the image package's decoder and converts a file specified
@@ -172,6 +179,8 @@ func NewIcccmIcon(X *xgbutil.XUtil, iconPixmap,
 
 // NewDrawable converts an X drawable into a xgraphics.Image.
 // This is used in NewIcccmIcon.
+// Large drawables (like screenshots of the root window) are read through
+// shared memory when the MIT-SHM extension can be used.
 func NewDrawable(X *xgbutil.XUtil, did xproto.Drawable) (*Image, error) {
 	// Get the geometry of the pixmap for use in the GetImage request.
 	pgeom, err := xwindow.RawGeometry(X, xproto.Drawable(did))
@@ -179,11 +188,9 @@ func NewDrawable(X *xgbutil.XUtil, did xproto.Drawable) (*Image, error) {
 		return nil, err
 	}
 
-	// Get the image data for each pixmap.
-	pixmapData, err := xproto.GetImage(X.Conn(), xproto.ImageFormatZPixmap,
-		did,
-		0, 0, uint16(pgeom.Width()), uint16(pgeom.Height()),
-		(1<<32)-1).Reply()
+	// Get the image data for each pixmap. Large drawables are read through
+	// shared memory when possible. (See shm.go.)
+	pixmapData, err := getImage(X, did, pgeom.Width(), pgeom.Height())
 	if err != nil {
 		return nil, err
 	}
@@ -205,8 +212,8 @@ func NewDrawable(X *xgbutil.XUtil, did xproto.Drawable) (*Image, error) {
 
 // readDrawableData uses Format information to read data from an X pixmap
 // into an xgraphics.Image.
-// readPixmapData does not take into account all information possible to read
-// X pixmaps and bitmaps. Of particular note is bit order/byte order.
+// Bitmaps are read as alpha masks, while pixmaps with any other depth are
+// converted from the pixel format of their visual. (See format.go.)
 func readDrawableData(X *xgbutil.XUtil, ximg *Image, did xproto.Drawable,
 	imgData *xproto.GetImageReply, width, height int) error {
 
@@ -231,6 +238,14 @@ func readDrawableData(X *xgbutil.XUtil, ximg *Image, did xproto.Drawable,
 			paddedWidth = width + pad - (width % pad)
 		}
 
+		// Scanlines are made of units. On servers with the most significant
+		// byte first, the bytes in each unit are reversed. Similarly, the
+		// bits in each byte are reversed if the bitmap bit order is the
+		// most significant bit first.
+		unit := int(X.Setup().BitmapFormatScanlineUnit) / 8
+		msbByte := X.Setup().ImageByteOrder == xproto.ImageOrderMSBFirst
+		msbBit := X.Setup().BitmapFormatBitOrder == xproto.ImageOrderMSBFirst
+
 		// Process one scanline at a time. Each 'y' represents a
 		// single scanline.
 		for y := 0; y < height; y++ {
@@ -239,7 +254,15 @@ func readDrawableData(X *xgbutil.XUtil, ximg *Image, did xproto.Drawable,
 			// 'i' is the index to the starting byte of the yth scanline.
 			i := y * paddedWidth / 8
 			for x := 0; x < width; x++ {
-				b := imgData.Data[i+x/8] >> uint(x%8)
+				j, bit := x/8, uint(x%8)
+				if msbByte && unit > 1 {
+					j = j - j%unit + unit - 1 - j%unit
+				}
+				if msbBit {
+					bit = 7 - bit
+				}
+
+				b := imgData.Data[i+j] >> bit
 				if b&1 > 0 { // opaque
 					ximg.Set(x, y, BGRA{0x0, 0x0, 0x0, 0xff})
 				} else { // transparent
@@ -247,41 +270,20 @@ func readDrawableData(X *xgbutil.XUtil, ximg *Image, did xproto.Drawable,
 				}
 			}
 		}
-	case 24, 32:
-		switch format.BitsPerPixel {
-		case 24:
-			bytesPer := int(format.BitsPerPixel) / 8
-			var i int
-			ximg.For(func(x, y int) BGRA {
-				i = y*width*bytesPer + x*bytesPer
-				return BGRA{
-					B: imgData.Data[i],
-					G: imgData.Data[i+1],
-					R: imgData.Data[i+2],
-					A: 0xff,
-				}
-			})
-		case 32:
-			bytesPer := int(format.BitsPerPixel) / 8
-			var i int
-			ximg.For(func(x, y int) BGRA {
-				i = y*width*bytesPer + x*bytesPer
-				return BGRA{
-					B: imgData.Data[i],
-					G: imgData.Data[i+1],
-					R: imgData.Data[i+2],
-					A: imgData.Data[i+3],
-				}
-			})
-		default:
-			return fmt.Errorf("The image returned for pixmap id %d has "+
-				"an unsupported value for bits-per-pixel: %d",
-				did, format.BitsPerPixel)
-		}
-
 	default:
-		return fmt.Errorf("The image returned for pixmap id %d has an "+
-			"unsupported value for depth: %d", did, format.Depth)
+		// Everything else is converted according to the visual of the
+		// drawable. (Or a visual with the same depth, for pixmaps.)
+		pf, err := newPixelFormat(X, imgData.Depth, imgData.Visual)
+		if err != nil {
+			return fmt.Errorf("The image returned for pixmap id %d has an "+
+				"unsupported format: %s", did, err)
+		}
+		if len(imgData.Data) < pf.stride(width)*height {
+			return fmt.Errorf("The image returned for pixmap id %d is "+
+				"too short: expected %d bytes but got %d.",
+				did, pf.stride(width)*height, len(imgData.Data))
+		}
+		pf.decode(ximg, imgData.Data)
 	}
 
 	return nil
@@ -298,15 +300,11 @@ func GetFormat(X *xgbutil.XUtil, depth byte) *xproto.Format {
 }
 
 // getVisualInfo searches SetupInfo for a VisualInfo value matching
-// the depth provided.
-// XXX: This isn't used (yet).
+// the depth and visual provided.
 func getVisualInfo(X *xgbutil.XUtil, depth byte,
 	visualid xproto.Visualid) *xproto.VisualInfo {
 
 	for _, depthInfo := range X.Screen().AllowedDepths {
-		fmt.Printf("%#v\n", depthInfo)
-		// fmt.Printf("%#v\n", depthInfo.Visuals)
-		fmt.Println("------------")
 		if depthInfo.Depth == depth {
 			for _, visual := range depthInfo.Visuals {
 				if visual.VisualId == visualid {
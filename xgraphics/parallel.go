@@ -0,0 +1,93 @@
+package xgraphics
+
+/*
+xgraphics/parallel.go contains a helper for splitting work on the pixels of an
+image across multiple goroutines.
+
+Converting a large image (like a 4K wallpaper) pixel by pixel takes much
+longer than sending it to X, so conversion, ForParallel, ForExpParallel and
+the blending functions split the image into bands of rows and work on each
+band in its own goroutine. Every pixel is computed exactly as before, and no
+two bands overlap, so the result is always byte-for-byte the same as working
+through the image with a single goroutine.
+
+Small images aren't split up, since starting goroutines would cost more than
+it saves.
+
+Only images whose pixels are read directly (xgraphics images and the image
+types of the standard library) are split up. Arbitrary images (read with At)
+may not be safe to read from multiple goroutines, and functions given by the
+caller are only called from multiple goroutines when asked for (with
+ForParallel and ForExpParallel).
+*/
+
+import (
+	"image"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// minParallelPixels is the number of pixels below which an image isn't split
+// up between multiple goroutines.
+const minParallelPixels = 256 * 256
+
+// minBandRows is the minimum number of rows in a band.
+const minBandRows = 16
+
+// workers is the maximum number of goroutines used to work on an image.
+var workers = int32(runtime.NumCPU())
+
+// SetWorkers sets the maximum number of goroutines used to convert, blend
+// and iterate over (with ForParallel and ForExpParallel) the pixels of large
+// images. If 'n' is less than 1, only a
+// single goroutine is used. The default is the number of CPUs.
+func SetWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+	atomic.StoreInt32(&workers, int32(n))
+}
+
+// Workers returns the maximum number of goroutines used to work on the pixels
+// of large images. (See SetWorkers.)
+func Workers() int {
+	return int(atomic.LoadInt32(&workers))
+}
+
+// parallel calls 'fn' for bands of rows that cover 'r', in as many goroutines
+// as allowed, and waits for all of them to return. 'fn' must only touch the
+// pixels inside the band it's given.
+func parallel(r image.Rectangle, fn func(band image.Rectangle)) {
+	n := Workers()
+	if rows := r.Dy() / minBandRows; rows < n {
+		n = rows
+	}
+	if n <= 1 || r.Dx()*r.Dy() < minParallelPixels {
+		if !r.Empty() {
+			fn(r)
+		}
+		return
+	}
+
+	wg := &sync.WaitGroup{}
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		band := r
+		band.Min.Y = r.Min.Y + i*r.Dy()/n
+		band.Max.Y = r.Min.Y + (i+1)*r.Dy()/n
+		go func() {
+			defer wg.Done()
+			fn(band)
+		}()
+	}
+	wg.Wait()
+}
+
+// serial is like parallel, but calls 'fn' once for all of 'r', in the
+// calling goroutine.
+func serial(r image.Rectangle, fn func(band image.Rectangle)) {
+	if !r.Empty() {
+		fn(r)
+	}
+}
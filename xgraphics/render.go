@@ -0,0 +1,453 @@
+package xgraphics
+
+/*
+xgraphics/render.go contains a Picture type that uses the RENDER extension to
+composite images on the X server.
+
+Blending (Blend, BlendBgColor, Alpha) and scaling (Scale) of Image values is
+done in Go, after which the result must be sent to X again. When the same
+image is drawn many times (like icons or thumbnails), it's much faster to
+send its pixels to X once as a Picture, and then have X do the blending,
+scaling and rotating every time it's drawn.
+
+Pictures require RENDER 0.6 (for transforms and filters), and
+CompositeOpacity requires RENDER 0.10 (for solid fill pictures).
+
+Compositing to a window requires the picture format of the window's visual.
+It is looked up the first time a picture is composited to a window, and
+remembered until xevent's main loop sees a DestroyNotify event for the
+window. (Since window ids are reused after windows are destroyed.)
+*/
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sync"
+
+	"github.com/BurntSushi/xgb/render"
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+)
+
+// Filters that can be used with SetFilter. X servers may support other
+// filters, but these are always available.
+const (
+	FilterNearest  = "nearest"
+	FilterBilinear = "bilinear"
+	FilterFast     = "fast"
+	FilterGood     = "good"
+	FilterBest     = "best"
+)
+
+// renderInfo contains the RENDER version and the picture formats of a
+// connection.
+type renderInfo struct {
+	// minor is the minor version of RENDER supported by the X server. (The
+	// major version is always 0.)
+	minor uint32
+
+	// argb is the picture format for 32 bit pixmaps with alpha.
+	argb render.Pictformat
+
+	// visuals maps visuals to picture formats.
+	visuals map[xproto.Visualid]render.Pictformat
+
+	// windows maps the windows that pictures have been composited to to
+	// the picture formats of their visuals.
+	windows    map[xproto.Window]render.Pictformat
+	windowsLck *sync.Mutex
+}
+
+// renderInfos maps each connection to its RENDER information. It is filled
+// in the first time a Picture is created for a connection.
+var (
+	renderInfos    = make(map[*xgbutil.XUtil]*renderInfo)
+	renderInfosLck = &sync.Mutex{}
+)
+
+// Picture is an image that has been sent to the X server, where it can be
+// composited on to windows with the RENDER extension. The pixels of a Picture
+// cannot be changed. (Create a new Picture from the updated Image instead.)
+//
+// A Picture has a transform and a filter, which are used every time it is
+// composited. By default, the picture is composited at its original size
+// using the "good" filter.
+type Picture struct {
+	X  *xgbutil.XUtil
+	Id render.Picture
+
+	// Pixmap is the 32 bit pixmap holding the picture's pixels.
+	Pixmap xproto.Pixmap
+
+	// The size of the picture, before transforming.
+	Width, Height int
+
+	// The bounding box of the transformed picture. The inverse of the
+	// transform is what is sent to X.
+	bounds image.Rectangle
+}
+
+// Transform is an affine transformation mapping points in a Picture to points
+// on the window it is composited to. The last row of the matrix is always
+// (0, 0, 1), so it is omitted.
+type Transform [2][3]float64
+
+// Identity is the transform that leaves a Picture unchanged.
+var Identity = Transform{{1, 0, 0}, {0, 1, 0}}
+
+// ScaleTransform returns a transform that scales a Picture by 'sx'
+// horizontally and 'sy' vertically.
+func ScaleTransform(sx, sy float64) Transform {
+	return Transform{{sx, 0, 0}, {0, sy, 0}}
+}
+
+// RotateTransform returns a transform that rotates a Picture clockwise by
+// 'angle' radians around its top-left corner. (Since Composite draws the
+// bounding box of the transformed picture, the result doesn't need to be
+// translated back into view.)
+func RotateTransform(angle float64) Transform {
+	sin, cos := math.Sin(angle), math.Cos(angle)
+	return Transform{{cos, -sin, 0}, {sin, cos, 0}}
+}
+
+// TranslateTransform returns a transform that moves a Picture by (dx, dy).
+func TranslateTransform(dx, dy float64) Transform {
+	return Transform{{1, 0, dx}, {0, 1, dy}}
+}
+
+// Then returns the transform that applies 't' and then 'u'.
+// For example, to double the size of a picture and then rotate it:
+//
+//	t := xgraphics.ScaleTransform(2, 2).Then(
+//		xgraphics.RotateTransform(math.Pi / 4))
+func (t Transform) Then(u Transform) Transform {
+	var r Transform
+	for i := 0; i < 2; i++ {
+		for j := 0; j < 3; j++ {
+			r[i][j] = u[i][0]*t[0][j] + u[i][1]*t[1][j]
+		}
+		r[i][2] += u[i][2]
+	}
+	return r
+}
+
+// Apply returns the point that (x, y) is transformed to.
+func (t Transform) Apply(x, y float64) (float64, float64) {
+	return t[0][0]*x + t[0][1]*y + t[0][2], t[1][0]*x + t[1][1]*y + t[1][2]
+}
+
+// inverse returns the inverse of 't', or false if 't' can't be inverted.
+// (Which is the case when it scales something to nothing.)
+func (t Transform) inverse() (Transform, bool) {
+	det := t[0][0]*t[1][1] - t[0][1]*t[1][0]
+	if math.Abs(det) < 1e-12 {
+		return Transform{}, false
+	}
+	a, b := t[1][1]/det, -t[0][1]/det
+	c, d := -t[1][0]/det, t[0][0]/det
+	return Transform{
+		{a, b, -(a*t[0][2] + b*t[1][2])},
+		{c, d, -(c*t[0][2] + d*t[1][2])},
+	}, true
+}
+
+// XPicture sends the image to X and returns it as a Picture, which can be
+// composited on to windows any number of times without sending the image
+// again. The alpha channel of the image is preserved.
+// XPicture can be called on sub-images.
+// When the Picture is no longer used, Destroy should be called.
+// An error is returned if the X server doesn't support RENDER.
+func (im *Image) XPicture() (*Picture, error) {
+	info, err := renderGet(im.X)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := im.Rect.Dx(), im.Rect.Dy()
+	pic := &Picture{X: im.X, Width: width, Height: height}
+
+	// Create a 32 bit pixmap and draw the image to it.
+	// An ARGB image value sharing the same pixels is used, but with its
+	// origin at (0, 0) so that it is drawn at the top-left of the pixmap.
+	upload := &Image{
+		X:      im.X,
+		Pix:    im.Pix,
+		Stride: im.Stride,
+		Rect:   image.Rect(0, 0, width, height),
+		Subimg: true,
+		ARGB:   true,
+	}
+	if err := upload.CreatePixmap(); err != nil {
+		return nil, err
+	}
+	pic.Pixmap = upload.Pixmap
+
+	// The draw is checked, since a Picture without its pixels is useless.
+	if err := upload.xdraw(true); err != nil {
+		pic.Destroy()
+		return nil, err
+	}
+
+	pic.Id, err = render.NewPictureId(im.X.Conn())
+	if err != nil {
+		pic.Destroy()
+		return nil, err
+	}
+	err = render.CreatePictureChecked(im.X.Conn(), pic.Id,
+		xproto.Drawable(pic.Pixmap), info.argb, 0, nil).Check()
+	if err != nil {
+		pic.Id = 0
+		pic.Destroy()
+		return nil, err
+	}
+
+	pic.SetFilter(FilterGood)
+	pic.SetTransform(Identity)
+	return pic, nil
+}
+
+// Destroy frees the picture and its pixmap on the X server.
+func (pic *Picture) Destroy() {
+	if pic.Id != 0 {
+		render.FreePicture(pic.X.Conn(), pic.Id)
+		pic.Id = 0
+	}
+	if pic.Pixmap != 0 {
+		xproto.FreePixmap(pic.X.Conn(), pic.Pixmap)
+		pic.Pixmap = 0
+	}
+}
+
+// SetFilter sets the filter used when the picture is transformed.
+// It should be one of the Filter constants, although X servers may support
+// other filters. FilterNearest is the fastest, while FilterGood and
+// FilterBest produce smoother results.
+func (pic *Picture) SetFilter(filter string) {
+	render.SetPictureFilter(pic.X.Conn(), pic.Id,
+		uint16(len(filter)), filter, nil)
+}
+
+// SetTransform sets the transform applied to the picture when it is
+// composited. See ScaleTransform, RotateTransform and TranslateTransform.
+// An error is returned if the transform can't be inverted.
+func (pic *Picture) SetTransform(t Transform) error {
+	// RENDER transforms map points in the destination to points in the
+	// picture, so the inverse of 't' is what is sent to X. It's also
+	// adjusted so that the bounding box of the transformed picture starts
+	// at (0, 0).
+	bounds := t.bounds(pic.Width, pic.Height)
+	t = t.Then(TranslateTransform(
+		-float64(bounds.Min.X), -float64(bounds.Min.Y)))
+
+	inv, ok := t.inverse()
+	if !ok {
+		return fmt.Errorf("SetTransform: The transform %v cannot be "+
+			"inverted.", t)
+	}
+
+	render.SetPictureTransform(pic.X.Conn(), pic.Id, render.Transform{
+		Matrix11: fixed(inv[0][0]),
+		Matrix12: fixed(inv[0][1]),
+		Matrix13: fixed(inv[0][2]),
+		Matrix21: fixed(inv[1][0]),
+		Matrix22: fixed(inv[1][1]),
+		Matrix23: fixed(inv[1][2]),
+		Matrix31: 0,
+		Matrix32: 0,
+		Matrix33: fixed(1),
+	})
+	pic.bounds = bounds.Sub(bounds.Min)
+	return nil
+}
+
+// Bounds returns the size of the picture after it has been transformed. This
+// is the area covered when the picture is composited.
+func (pic *Picture) Bounds() image.Rectangle {
+	return pic.bounds
+}
+
+// bounds returns the smallest rectangle containing a width x height
+// rectangle after it is transformed.
+func (t Transform) bounds(width, height int) image.Rectangle {
+	w, h := float64(width), float64(height)
+	minx, miny := math.Inf(1), math.Inf(1)
+	maxx, maxy := math.Inf(-1), math.Inf(-1)
+	for _, corner := range [][2]float64{{0, 0}, {w, 0}, {0, h}, {w, h}} {
+		x, y := t.Apply(corner[0], corner[1])
+		minx, miny = math.Min(minx, x), math.Min(miny, y)
+		maxx, maxy = math.Max(maxx, x), math.Max(maxy, y)
+	}
+	// Allow for a little rounding error, so that exact scales and rotations
+	// don't grow an extra pixel.
+	const eps = 1e-6
+	return image.Rect(
+		int(math.Floor(minx+eps)), int(math.Floor(miny+eps)),
+		int(math.Ceil(maxx-eps)), int(math.Ceil(maxy-eps)))
+}
+
+// Composite draws the picture on to a window, blending it with what is
+// already there using the picture's alpha channel. The top-left corner of
+// the (transformed) picture is placed at (x, y).
+func (pic *Picture) Composite(wid xproto.Window, x, y int) error {
+	return pic.composite(wid, x, y, 0)
+}
+
+// CompositeOpacity is like Composite, except the picture is additionally
+// made translucent. 'opacity' should be in the range [0, 1], where 0 is
+// invisible and 1 is the same as Composite.
+func (pic *Picture) CompositeOpacity(wid xproto.Window, x, y int,
+	opacity float64) error {
+
+	info, err := renderGet(pic.X)
+	if err != nil {
+		return err
+	}
+	if info.minor < 10 {
+		return fmt.Errorf("CompositeOpacity: RENDER 0.10 is required, but "+
+			"the X server only supports RENDER 0.%d.", info.minor)
+	}
+
+	mask, err := render.NewPictureId(pic.X.Conn())
+	if err != nil {
+		return err
+	}
+	alpha := uint16(math.Max(0, math.Min(1, opacity)) * 0xffff)
+	render.CreateSolidFill(pic.X.Conn(), mask, render.Color{Alpha: alpha})
+	defer render.FreePicture(pic.X.Conn(), mask)
+
+	return pic.composite(wid, x, y, mask)
+}
+
+// composite creates a picture for the window, and composites the picture
+// (with an optional mask) on to it.
+func (pic *Picture) composite(wid xproto.Window, x, y int,
+	mask render.Picture) error {
+
+	info, err := renderGet(pic.X)
+	if err != nil {
+		return err
+	}
+	format, err := info.windowFormat(pic.X, wid)
+	if err != nil {
+		return err
+	}
+
+	dst, err := render.NewPictureId(pic.X.Conn())
+	if err != nil {
+		return err
+	}
+	render.CreatePicture(pic.X.Conn(), dst, xproto.Drawable(wid), format,
+		0, nil)
+	render.Composite(pic.X.Conn(), render.PictOpOver, pic.Id, mask, dst,
+		0, 0, 0, 0, int16(x), int16(y),
+		uint16(pic.bounds.Dx()), uint16(pic.bounds.Dy()))
+	render.FreePicture(pic.X.Conn(), dst)
+	return nil
+}
+
+// renderGet returns the RENDER information for the connection given,
+// initializing the RENDER extension if necessary.
+func renderGet(X *xgbutil.XUtil) (*renderInfo, error) {
+	renderInfosLck.Lock()
+	defer renderInfosLck.Unlock()
+
+	if info, ok := renderInfos[X]; ok {
+		return info, nil
+	}
+
+	if err := render.Init(X.Conn()); err != nil {
+		return nil, err
+	}
+	version, err := render.QueryVersion(X.Conn(), 0, 11).Reply()
+	if err != nil {
+		return nil, err
+	}
+	// Transforms and filters require RENDER 0.6.
+	if version.MajorVersion == 0 && version.MinorVersion < 6 {
+		return nil, fmt.Errorf("RENDER 0.6 is required, but the X server "+
+			"only supports RENDER %d.%d.",
+			version.MajorVersion, version.MinorVersion)
+	}
+	formats, err := render.QueryPictFormats(X.Conn()).Reply()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &renderInfo{
+		minor:      version.MinorVersion,
+		visuals:    make(map[xproto.Visualid]render.Pictformat),
+		windows:    make(map[xproto.Window]render.Pictformat),
+		windowsLck: &sync.Mutex{},
+	}
+	if version.MajorVersion > 0 {
+		info.minor = 11
+	}
+	for _, f := range formats.Formats {
+		d := f.Direct
+		if f.Type == render.PictTypeDirect && f.Depth == 32 &&
+			d.AlphaShift == 24 && d.AlphaMask == 0xff &&
+			d.RedShift == 16 && d.RedMask == 0xff &&
+			d.GreenShift == 8 && d.GreenMask == 0xff &&
+			d.BlueShift == 0 && d.BlueMask == 0xff {
+
+			info.argb = f.Id
+		}
+	}
+	if info.argb == 0 {
+		return nil, fmt.Errorf("Could not find the ARGB32 picture format.")
+	}
+	for _, screen := range formats.Screens {
+		for _, depth := range screen.Depths {
+			for _, visual := range depth.Visuals {
+				info.visuals[visual.Visual] = visual.Format
+			}
+		}
+	}
+
+	// Forget the format of destroyed windows, since their ids may be used
+	// for new windows with a different visual.
+	xevent.HookFun(func(X *xgbutil.XUtil, ev interface{}) bool {
+		if e, ok := ev.(xproto.DestroyNotifyEvent); ok {
+			info.windowsLck.Lock()
+			delete(info.windows, e.Window)
+			info.windowsLck.Unlock()
+		}
+		return true
+	}).Connect(X)
+
+	renderInfos[X] = info
+	return info, nil
+}
+
+// windowFormat returns the picture format of the visual of the window given.
+// It is remembered until the window is destroyed.
+func (info *renderInfo) windowFormat(X *xgbutil.XUtil,
+	wid xproto.Window) (render.Pictformat, error) {
+
+	info.windowsLck.Lock()
+	defer info.windowsLck.Unlock()
+
+	if format, ok := info.windows[wid]; ok {
+		return format, nil
+	}
+
+	attrs, err := xproto.GetWindowAttributes(X.Conn(), wid).Reply()
+	if err != nil {
+		return 0, err
+	}
+	format, ok := info.visuals[attrs.Visual]
+	if !ok {
+		return 0, fmt.Errorf("Could not find a picture format for the "+
+			"visual of window %d.", wid)
+	}
+	info.windows[wid] = format
+	return format, nil
+}
+
+// fixed converts a float to the 16.16 fixed point format used by RENDER.
+func fixed(f float64) render.Fixed {
+	return render.Fixed(math.Floor(f*65536 + 0.5))
+}
@@ -0,0 +1,262 @@
+package xgraphics
+
+/*
+xgraphics/scale.go contains functions for scaling images with a choice of
+resampling filters.
+
+Images are scaled in two passes (horizontally, then vertically), working
+directly on BGRA data. Each destination pixel is a weighted sum of the source
+pixels under the filter's kernel, where the kernel is stretched when
+shrinking an image so that every source pixel contributes. Pixels are
+premultiplied by alpha while they are being summed, so that transparent
+pixels (whose color is meaningless) don't bleed into opaque ones. This is
+what keeps the edges of scaled icons clean.
+
+Shrinking an image by a large ratio with a wide kernel (like Lanczos) is
+expensive, since the kernel covers many source pixels. So images are first
+shrunk with the (cheap) box filter to twice the destination size, and the
+requested filter is applied to the result.
+*/
+
+import (
+	"image"
+	"math"
+)
+
+// ScaleFilter is a resampling filter used when scaling images.
+type ScaleFilter int
+
+const (
+	// ScaleNearest picks the nearest source pixel. It is the fastest, and
+	// keeps pixel art crisp, but looks blocky otherwise.
+	ScaleNearest ScaleFilter = iota
+
+	// ScaleBilinear interpolates linearly between the nearest pixels.
+	ScaleBilinear
+
+	// ScaleBicubic uses the Catmull-Rom cubic spline. It is sharper than
+	// bilinear and is the default used by Scale.
+	ScaleBicubic
+
+	// ScaleLanczos uses a Lanczos kernel with 3 lobes. It is the sharpest
+	// (and slowest), and is good for shrinking icons and thumbnails.
+	ScaleLanczos
+
+	// ScaleBox averages the source pixels covered by each destination pixel.
+	// It is fast and good for shrinking by large ratios, but blocky when
+	// enlarging.
+	ScaleBox
+)
+
+// support returns the radius of a filter's kernel, in source pixels when the
+// image isn't shrunk.
+func (f ScaleFilter) support() float64 {
+	switch f {
+	case ScaleBilinear:
+		return 1
+	case ScaleBicubic:
+		return 2
+	case ScaleLanczos:
+		return 3
+	}
+	return 0.5
+}
+
+// kernel returns the weight of a source pixel at distance 'x' from the
+// center of a destination pixel.
+func (f ScaleFilter) kernel(x float64) float64 {
+	x = math.Abs(x)
+	switch f {
+	case ScaleBilinear:
+		if x < 1 {
+			return 1 - x
+		}
+	case ScaleBicubic:
+		// Catmull-Rom (B = 0, C = 0.5)
+		if x < 1 {
+			return (1.5*x-2.5)*x*x + 1
+		}
+		if x < 2 {
+			return ((-0.5*x+2.5)*x-4)*x + 2
+		}
+	case ScaleLanczos:
+		if x == 0 {
+			return 1
+		}
+		if x < 3 {
+			px := math.Pi * x
+			return 3 * math.Sin(px) * math.Sin(px/3) / (px * px)
+		}
+	default: // ScaleBox
+		if x <= 0.5 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// ScaleWith returns a new image with the size given, scaled from this image
+// with the filter given. Unlike Scale, the receiver is left as is. (Including
+// its pixmap.) Sub-images can be scaled too.
+func (im *Image) ScaleWith(width, height int, filter ScaleFilter) *Image {
+	dst := newImage(im.X, image.Rect(0, 0, width, height))
+	dst.ARGB = im.ARGB
+	scaleInto(dst, im, filter)
+	return dst
+}
+
+// scaleInto scales 'src' to the size of 'dst' with the filter given.
+func scaleInto(dst, src *Image, filter ScaleFilter) {
+	dw, dh := dst.Rect.Dx(), dst.Rect.Dy()
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	if dw <= 0 || dh <= 0 || sw <= 0 || sh <= 0 {
+		return
+	}
+	if filter == ScaleNearest {
+		scaleNearest(dst, src)
+		return
+	}
+
+	// Shrink by large ratios with the box filter first.
+	if filter != ScaleBox && (sw > 2*dw || sh > 2*dh) {
+		mw, mh := sw, sh
+		if mw > 2*dw {
+			mw = 2 * dw
+		}
+		if mh > 2*dh {
+			mh = 2 * dh
+		}
+		mid := newImage(nil, image.Rect(0, 0, mw, mh))
+		scaleInto(mid, src, ScaleBox)
+		src = mid
+		sw, sh = mw, mh
+	}
+
+	xs := contributions(sw, dw, filter)
+	ys := contributions(sh, dh, filter)
+
+	// Horizontal pass: each row of the source is scaled to the destination
+	// width, with premultiplied colors.
+	tmp := make([]float32, 4*dw*sh)
+	for y := 0; y < sh; y++ {
+		row := src.Pix[src.PixOffset(src.Rect.Min.X, src.Rect.Min.Y+y):]
+		out := tmp[4*dw*y:]
+		for x, c := range xs {
+			var b, g, r, a float32
+			for k, w := range c.weights {
+				i := 4 * (c.first + k)
+				pa := w * float32(row[i+3])
+				b += pa * float32(row[i])
+				g += pa * float32(row[i+1])
+				r += pa * float32(row[i+2])
+				a += pa
+			}
+			out[4*x], out[4*x+1], out[4*x+2], out[4*x+3] = b, g, r, a
+		}
+	}
+
+	// Vertical pass: each column is scaled to the destination height, and
+	// colors are divided by alpha again.
+	for y, c := range ys {
+		out := dst.Pix[dst.PixOffset(dst.Rect.Min.X, dst.Rect.Min.Y+y):]
+		for x := 0; x < dw; x++ {
+			var b, g, r, a float32
+			for k, w := range c.weights {
+				i := 4 * (dw*(c.first+k) + x)
+				b += w * tmp[i]
+				g += w * tmp[i+1]
+				r += w * tmp[i+2]
+				a += w * tmp[i+3]
+			}
+			i := 4 * x
+			if a <= 0 {
+				out[i], out[i+1], out[i+2], out[i+3] = 0, 0, 0, 0
+				continue
+			}
+			out[i] = clampUint8(b / a)
+			out[i+1] = clampUint8(g / a)
+			out[i+2] = clampUint8(r / a)
+			out[i+3] = clampUint8(a)
+		}
+	}
+	dst.Damage(dst.Rect)
+}
+
+// scaleNearest scales 'src' to the size of 'dst' by copying the source pixel
+// nearest to the center of each destination pixel.
+func scaleNearest(dst, src *Image) {
+	dw, dh := dst.Rect.Dx(), dst.Rect.Dy()
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	for y := 0; y < dh; y++ {
+		sy := (2*y + 1) * sh / (2 * dh)
+		row := src.Pix[src.PixOffset(src.Rect.Min.X, src.Rect.Min.Y+sy):]
+		out := dst.Pix[dst.PixOffset(dst.Rect.Min.X, dst.Rect.Min.Y+y):]
+		for x := 0; x < dw; x++ {
+			sx := (2*x + 1) * sw / (2 * dw)
+			copy(out[4*x:4*x+4], row[4*sx:4*sx+4])
+		}
+	}
+	dst.Damage(dst.Rect)
+}
+
+// contribution is the list of source pixels (starting at 'first') and their
+// weights that make up a destination pixel in one dimension.
+type contribution struct {
+	first   int
+	weights []float32
+}
+
+// contributions computes the contribution of source pixels to each
+// destination pixel, when scaling 'srcSize' pixels to 'dstSize' pixels.
+// Weights are normalized so that they sum to 1.
+func contributions(srcSize, dstSize int, filter ScaleFilter) []contribution {
+	scale := float64(srcSize) / float64(dstSize)
+	stretch := math.Max(scale, 1) // widen the kernel when shrinking
+	support := filter.support() * stretch
+
+	cs := make([]contribution, dstSize)
+	for i := range cs {
+		center := (float64(i) + 0.5) * scale
+		first := int(math.Floor(center - support))
+		last := int(math.Ceil(center + support))
+		if first < 0 {
+			first = 0
+		}
+		if last > srcSize-1 {
+			last = srcSize - 1
+		}
+
+		weights := make([]float32, 0, last-first+1)
+		sum := 0.0
+		for j := first; j <= last; j++ {
+			w := filter.kernel((float64(j) + 0.5 - center) / stretch)
+			weights = append(weights, float32(w))
+			sum += w
+		}
+		if sum == 0 {
+			// Only possible with the box filter at exact pixel boundaries.
+			j := int(center)
+			if j > srcSize-1 {
+				j = srcSize - 1
+			}
+			cs[i] = contribution{j, []float32{1}}
+			continue
+		}
+		for k := range weights {
+			weights[k] = float32(float64(weights[k]) / sum)
+		}
+		cs[i] = contribution{first, weights}
+	}
+	return cs
+}
+
+// clampUint8 rounds 'v' to the nearest integer in the range [0, 255].
+func clampUint8(v float32) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	}
+	return uint8(v + 0.5)
+}
@@ -0,0 +1,320 @@
+package xgraphics
+
+/*
+xgraphics/shm.go contains a fast path for sending image data to X (XDraw) and
+reading image data from X (NewDrawable) using the MIT-SHM extension.
+
+Instead of writing image data to the X connection, the data is copied into a
+shared memory segment that is also attached to the X server. This avoids
+splitting large images into many PutImage requests, and avoids pushing every
+byte of a screenshot through the connection with GetImage.
+
+Shared memory only works when the client and the X server are on the same
+machine. Since xgb doesn't tell us where the X server is, we check by
+sending a single pixel through a segment and reading it back the slow way.
+If the pixel doesn't survive the trip, or if anything else goes wrong,
+xgraphics quietly falls back to PutImage and GetImage.
+
+One segment is shared by every image on the same connection. It grows as
+needed to fit the largest image sent or received, and is kept until FreeShm
+is called for the connection.
+*/
+
+import (
+	"fmt"
+	"image"
+	"sync"
+
+	"github.com/BurntSushi/xgb/shm"
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+)
+
+// shmBuffers maps each connection to its shared memory buffer. A nil buffer
+// means that MIT-SHM can't be used with that connection.
+var (
+	shmBuffers    = make(map[*xgbutil.XUtil]*shmBuffer)
+	shmBuffersLck = &sync.Mutex{}
+)
+
+// shmMinSize is the size of the smallest image data that is sent through
+// shared memory. Anything smaller fits in a single PutImage request, which
+// is just as fast.
+const shmMinSize = xgbutil.MaxReqSize - 28
+
+// shmBuffer is a shared memory segment attached to both the client and the
+// X server.
+type shmBuffer struct {
+	lck *sync.Mutex
+	seg shm.Seg
+	mem []byte
+
+	// pending is true when a PutImage request reading from 'mem' may not
+	// have been processed by the X server yet.
+	pending bool
+
+	// freed is true once FreeShm has been called, after which no segment
+	// is created anymore.
+	freed bool
+}
+
+// ShmAvailable returns whether image data can be sent and received through
+// the MIT-SHM extension on the connection given. If it returns false,
+// XDraw and NewDrawable still work, but they may be slower for large images.
+func ShmAvailable(X *xgbutil.XUtil) bool {
+	return shmGet(X) != nil
+}
+
+// FreeShm detaches and removes the shared memory segment used to send and
+// receive images on the connection given, if there is one. It should be
+// called before closing a connection that large images were sent or received
+// on, since the segment is otherwise kept until the program exits. If images
+// are sent or received on the connection afterwards, a new segment is
+// created.
+func FreeShm(X *xgbutil.XUtil) {
+	shmBuffersLck.Lock()
+	buf := shmBuffers[X]
+	delete(shmBuffers, X)
+	shmBuffersLck.Unlock()
+
+	if buf == nil {
+		return
+	}
+	buf.lck.Lock()
+	defer buf.lck.Unlock()
+
+	buf.free(X)
+	buf.freed = true
+}
+
+// shmGet returns the shared memory buffer for the connection given. It is
+// set up the first time it's requested. nil is returned if MIT-SHM can't be
+// used.
+func shmGet(X *xgbutil.XUtil) *shmBuffer {
+	shmBuffersLck.Lock()
+	defer shmBuffersLck.Unlock()
+
+	if buf, ok := shmBuffers[X]; ok {
+		return buf
+	}
+
+	buf := &shmBuffer{lck: &sync.Mutex{}}
+	if err := buf.init(X); err != nil {
+		buf.free(X)
+		buf = nil
+	}
+	shmBuffers[X] = buf
+	return buf
+}
+
+// init initializes the MIT-SHM extension and makes sure that the X server
+// can actually see our shared memory.
+func (buf *shmBuffer) init(X *xgbutil.XUtil) error {
+	if err := shm.Init(X.Conn()); err != nil {
+		return err
+	}
+	if err := buf.reserve(X, 4); err != nil {
+		return err
+	}
+
+	// Send a single pixel with a known value through shared memory, and
+	// read it back with a plain GetImage request.
+	format, err := drawFormat(X)
+	if err != nil {
+		return err
+	}
+	pid, err := xproto.NewPixmapId(X.Conn())
+	if err != nil {
+		return err
+	}
+	err = xproto.CreatePixmapChecked(X.Conn(), format.depth, pid,
+		xproto.Drawable(X.RootWin()), 1, 1).Check()
+	if err != nil {
+		return err
+	}
+	defer xproto.FreePixmap(X.Conn(), pid)
+
+	marker := New(X, image.Rect(0, 0, 1, 1))
+	marker.SetBGRA(0, 0, BGRA{B: 0x5a, G: 0xa5, R: 0x3c, A: 0xff})
+	format.encode(buf.mem, 4, marker)
+	err = shm.PutImageChecked(X.Conn(), xproto.Drawable(pid), X.GC(),
+		1, 1, 0, 0, 1, 1, 0, 0, format.depth, xproto.ImageFormatZPixmap, 0,
+		buf.seg, 0).Check()
+	if err != nil {
+		return err
+	}
+
+	reply, err := xproto.GetImage(X.Conn(), xproto.ImageFormatZPixmap,
+		xproto.Drawable(pid), 0, 0, 1, 1, (1<<32)-1).Reply()
+	if err != nil {
+		return err
+	}
+
+	// Only compare the bits that are part of the pixel.
+	mask := uint32(1<<uint(format.depth) - 1)
+	if len(reply.Data) < format.bpp/8 ||
+		format.pixel(reply.Data)&mask != format.pixel(buf.mem)&mask {
+
+		return fmt.Errorf("MIT-SHM: The X server could not see our " +
+			"shared memory. Is it running on a different machine?")
+	}
+	return nil
+}
+
+// reserve makes sure the shared memory segment is at least 'size' bytes,
+// creating a new segment if necessary.
+func (buf *shmBuffer) reserve(X *xgbutil.XUtil, size int) error {
+	if size <= len(buf.mem) {
+		return nil
+	}
+	if buf.freed {
+		return fmt.Errorf("MIT-SHM: The shared memory has been freed.")
+	}
+	buf.free(X)
+
+	// Grow in 64KB increments so that images with similar sizes don't
+	// require a new segment each time.
+	size = (size + 0xffff) &^ 0xffff
+
+	seg, err := shm.NewSegId(X.Conn())
+	if err != nil {
+		return err
+	}
+	id, mem, err := shmCreate(size)
+	if err != nil {
+		return err
+	}
+
+	// Once both sides are attached, the segment can be marked for removal.
+	// It will then be destroyed automatically when both sides detach, even
+	// if we exit without cleaning up.
+	err = shm.AttachChecked(X.Conn(), seg, uint32(id), false).Check()
+	shmRemove(id)
+	if err != nil {
+		shmDetach(mem)
+		return err
+	}
+
+	buf.seg, buf.mem, buf.pending = seg, mem, false
+	return nil
+}
+
+// free detaches the current segment (if any) from both sides.
+// The X server processes requests in order, so a pending PutImage request
+// is still done before the X server detaches.
+func (buf *shmBuffer) free(X *xgbutil.XUtil) {
+	if buf.mem == nil {
+		return
+	}
+	shm.Detach(X.Conn(), buf.seg)
+	shmDetach(buf.mem)
+	buf.seg, buf.mem, buf.pending = 0, nil, false
+}
+
+// wait blocks until the X server has processed any pending PutImage request,
+// so that the segment can be safely written to.
+func (buf *shmBuffer) wait(X *xgbutil.XUtil) {
+	if !buf.pending {
+		return
+	}
+	xproto.GetInputFocus(X.Conn()).Reply()
+	buf.pending = false
+}
+
+// xdrawShm is like xdraw, but sends the image data through shared memory.
+// It returns false if shared memory can't be used, in which case nothing has
+// been sent. 'gc' must have the depth of the image's pixmap.
+func (im *Image) xdrawShm(format *pixelFormat, gc xproto.Gcontext,
+	checked bool) (bool, error) {
+
+	width, height := im.Rect.Dx(), im.Rect.Dy()
+	stride := format.stride(width)
+	if stride*height < shmMinSize {
+		return false, nil
+	}
+
+	buf := shmGet(im.X)
+	if buf == nil {
+		return false, nil
+	}
+
+	buf.lck.Lock()
+	defer buf.lck.Unlock()
+
+	if err := buf.reserve(im.X, stride*height); err != nil {
+		xgbutil.Logger.Printf("Could not allocate shared memory for an "+
+			"image, falling back to PutImage: %s", err)
+		return false, nil
+	}
+	buf.wait(im.X)
+	format.encode(buf.mem, stride, im)
+
+	if checked {
+		return true, shm.PutImageChecked(im.X.Conn(),
+			xproto.Drawable(im.Pixmap), gc,
+			uint16(width), uint16(height), 0, 0,
+			uint16(width), uint16(height),
+			int16(im.Rect.Min.X), int16(im.Rect.Min.Y),
+			format.depth, xproto.ImageFormatZPixmap, 0, buf.seg, 0).Check()
+	}
+	shm.PutImage(im.X.Conn(),
+		xproto.Drawable(im.Pixmap), gc,
+		uint16(width), uint16(height), 0, 0,
+		uint16(width), uint16(height),
+		int16(im.Rect.Min.X), int16(im.Rect.Min.Y),
+		format.depth, xproto.ImageFormatZPixmap, 0, buf.seg, 0)
+	buf.pending = true
+	return true, nil
+}
+
+// getImage is like xproto.GetImage with a ZPixmap format and every plane,
+// except the data is read through shared memory when possible.
+func getImage(X *xgbutil.XUtil, did xproto.Drawable,
+	width, height int) (*xproto.GetImageReply, error) {
+
+	if width*height*4 >= shmMinSize {
+		if reply := getImageShm(X, did, width, height); reply != nil {
+			return reply, nil
+		}
+	}
+	return xproto.GetImage(X.Conn(), xproto.ImageFormatZPixmap, did,
+		0, 0, uint16(width), uint16(height), (1<<32)-1).Reply()
+}
+
+// getImageShm reads image data through shared memory. It returns nil if
+// shared memory can't be used or if the request failed. (In which case,
+// the caller should retry with a plain GetImage request to get a proper
+// error.)
+func getImageShm(X *xgbutil.XUtil, did xproto.Drawable,
+	width, height int) *xproto.GetImageReply {
+
+	buf := shmGet(X)
+	if buf == nil {
+		return nil
+	}
+
+	buf.lck.Lock()
+	defer buf.lck.Unlock()
+
+	// No image format uses more than 32 bits per pixel.
+	if err := buf.reserve(X, width*height*4); err != nil {
+		return nil
+	}
+	buf.wait(X)
+
+	reply, err := shm.GetImage(X.Conn(), did, 0, 0,
+		uint16(width), uint16(height), (1<<32)-1,
+		xproto.ImageFormatZPixmap, buf.seg, 0).Reply()
+	if err != nil || int(reply.Size) > len(buf.mem) {
+		return nil
+	}
+
+	data := make([]byte, reply.Size)
+	copy(data, buf.mem)
+	return &xproto.GetImageReply{
+		Depth:  reply.Depth,
+		Visual: reply.Visual,
+		Data:   data,
+	}
+}
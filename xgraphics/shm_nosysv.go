@@ -0,0 +1,22 @@
+//go:build !linux || (!amd64 && !arm && !arm64 && !riscv64)
+// +build !linux !amd64,!arm,!arm64,!riscv64
+
+package xgraphics
+
+/*
+xgraphics/shm_nosysv.go disables the MIT-SHM fast path on platforms where
+System V shared memory isn't available. Image data is always sent with
+PutImage and read with GetImage.
+*/
+
+import "errors"
+
+var errNoSysV = errors.New("System V shared memory is not supported")
+
+func shmCreate(size int) (int, []byte, error) {
+	return 0, nil, errNoSysV
+}
+
+func shmDetach(mem []byte) {}
+
+func shmRemove(id int) {}
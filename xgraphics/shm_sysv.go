@@ -0,0 +1,59 @@
+//go:build linux && (amd64 || arm || arm64 || riscv64)
+// +build linux
+// +build amd64 arm arm64 riscv64
+
+package xgraphics
+
+/*
+xgraphics/shm_sysv.go contains the System V shared memory system calls used
+by the MIT-SHM fast path. Not every platform supports them (or exposes them
+as individual system calls), so they are only built where they are known to
+work. Everywhere else, shm_nosysv.go disables the fast path.
+*/
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const (
+	ipcPrivate = 0
+	ipcCreat   = 01000
+	ipcRmid    = 0
+)
+
+// shmCreate creates a new shared memory segment of 'size' bytes that is
+// only accessible by the current user, and attaches it to our address space.
+func shmCreate(size int) (int, []byte, error) {
+	id, _, errno := syscall.Syscall(syscall.SYS_SHMGET,
+		ipcPrivate, uintptr(size), ipcCreat|0600)
+	if errno != 0 {
+		return 0, nil, errno
+	}
+
+	addr, _, errno := syscall.Syscall(syscall.SYS_SHMAT, id, 0, 0)
+	if errno != 0 {
+		shmRemove(int(id))
+		return 0, nil, errno
+	}
+
+	// The segment isn't memory managed by Go, so the address is converted
+	// through a pointer to it. (Converting it directly is fine too, but go
+	// vet can't tell.)
+	ptr := *(*unsafe.Pointer)(unsafe.Pointer(&addr))
+	mem := unsafe.Slice((*byte)(ptr), size)
+	return int(id), mem, nil
+}
+
+// shmDetach detaches a segment created with shmCreate from our address space.
+// 'mem' must not be used afterwards.
+func shmDetach(mem []byte) {
+	syscall.Syscall(syscall.SYS_SHMDT,
+		uintptr(unsafe.Pointer(&mem[0])), 0, 0)
+}
+
+// shmRemove marks a segment for removal. The segment is destroyed once every
+// process (including the X server) has detached from it.
+func shmRemove(id int) {
+	syscall.Syscall(syscall.SYS_SHMCTL, uintptr(id), ipcRmid, 0)
+}
@@ -18,6 +18,9 @@ import (
 // Note that the ParseFont helper function can be used to get a *truetype.Font
 // value without having to import freetype-go directly.
 //
+// Glyphs are cached for each font and size (see Face), so drawing the same
+// text repeatedly only rasterizes each glyph once.
+//
 // If you need more control over the 'context' used to draw text (like the DPI),
 // then you'll need to ignore this convenience method and use your own.
 func (im *Image) Text(x, y int, clr color.Color, fontSize float64,
@@ -26,15 +29,10 @@ func (im *Image) Text(x, y int, clr color.Color, fontSize float64,
 	// Create a solid color image
 	textClr := image.NewUniform(clr)
 
-	// Set up the freetype context... mostly boiler plate
-	c := ftContext(font, fontSize)
-	c.SetClip(im.Bounds())
-	c.SetDst(im)
-	c.SetSrc(textClr)
-
 	// Now let's actually draw the text...
-	pt := freetype.Pt(x, y+int(c.PointToFix32(fontSize)>>8))
-	newpt, err := c.DrawString(text, pt)
+	f := face(font, fontSize)
+	pt := freetype.Pt(x, y+int(f.c.PointToFix32(fontSize)>>8))
+	newpt, err := f.DrawString(im, textClr, pt, text)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -45,8 +43,7 @@ func (im *Image) Text(x, y int, clr color.Color, fontSize float64,
 // Extents returns the *correct* max width and height extents of a string
 // given a font. See freetype.MeasureString for the deets.
 func Extents(font *truetype.Font, fontSize float64, text string) (int, int) {
-	c := ftContext(font, fontSize)
-	w, h, err := c.MeasureString(text)
+	w, h, err := face(font, fontSize).MeasureString(text)
 	if err != nil {
 		return 0, 0
 	}
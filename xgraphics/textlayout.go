@@ -0,0 +1,343 @@
+package xgraphics
+
+/*
+xgraphics/textlayout.go contains a TextLayout type for laying out and drawing
+text that doesn't fit on a single line: word wrapping, alignment, truncating
+with an ellipsis and falling back to other fonts for glyphs that are missing
+in the primary font.
+
+Text is laid out by measuring the advance width of each glyph (plus kerning)
+with the same metrics used by freetype-go when drawing, so a line measured to
+fit in a width will also be drawn within it. Text is drawn with the same
+cached Faces as Text.
+*/
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"github.com/BurntSushi/freetype-go/freetype"
+	"github.com/BurntSushi/freetype-go/freetype/raster"
+	"github.com/BurntSushi/freetype-go/freetype/truetype"
+)
+
+// Align describes how each line of text is positioned horizontally in the
+// width of a TextLayout.
+type Align int
+
+const (
+	AlignLeft Align = iota
+	AlignCenter
+	AlignRight
+)
+
+// ellipsis is appended to text that has been truncated. If none of the fonts
+// of a layout have a glyph for it, ellipsisASCII is used instead.
+const (
+	ellipsis      = '…'
+	ellipsisASCII = "..."
+)
+
+// TextLayout describes how to lay out text in a box.
+//
+// Fonts is the font fallback chain: each character is drawn with the first
+// font that has a glyph for it. (If none of them do, the first font is used
+// anyway.) There must be at least one font, or Lines, Extents and TextLayout
+// return an error.
+//
+// Width is the width of the box in pixels. When Wrap is true, lines are
+// broken between words (or within words that are wider than Width) so that
+// they fit. When Ellipsis is true, lines that still don't fit are truncated
+// and end with "…". A Width of 0 means lines are never wrapped or truncated.
+//
+// MaxLines is the maximum number of lines. Lines after it are dropped, and if
+// Ellipsis is true, the last line kept ends with "…". A MaxLines of 0 means
+// there is no maximum.
+//
+// LineSpacing is the distance between the baselines of consecutive lines, as
+// a multiple of the font size. If it's 0, 1.2 is used.
+//
+// Newlines in the text always start a new line.
+type TextLayout struct {
+	Fonts       []*truetype.Font
+	Size        float64
+	Width       int
+	Wrap        bool
+	Ellipsis    bool
+	MaxLines    int
+	Align       Align
+	LineSpacing float64
+}
+
+// TextLine is a single line of text laid out by a TextLayout. X is the
+// offset of the line from the left of the box (which depends upon the
+// alignment), and Width is the width of the line in pixels.
+type TextLine struct {
+	Text  string
+	X     int
+	Width int
+}
+
+// textRun is a part of a line drawn with a single font.
+type textRun struct {
+	font *truetype.Font
+	text string
+}
+
+// NewTextLayout returns a layout for text of the size given, using the fonts
+// given as a fallback chain. Text isn't wrapped or truncated until Width is
+// set.
+func NewTextLayout(size float64, fonts ...*truetype.Font) *TextLayout {
+	return &TextLayout{
+		Fonts: fonts,
+		Size:  size,
+	}
+}
+
+// Lines lays out the text given and returns each line.
+func (l *TextLayout) Lines(text string) ([]TextLine, error) {
+	if len(l.Fonts) == 0 {
+		return nil, fmt.Errorf("The text layout has no fonts.")
+	}
+	for _, font := range l.Fonts {
+		if font == nil {
+			return nil, fmt.Errorf("The text layout has a nil font.")
+		}
+	}
+
+	var lines []string
+	truncated := false
+	for _, para := range strings.Split(text, "\n") {
+		if l.MaxLines > 0 && len(lines) >= l.MaxLines {
+			truncated = true
+			break
+		}
+		if l.Wrap && l.Width > 0 {
+			lines = append(lines, l.wrap(para)...)
+		} else {
+			lines = append(lines, para)
+		}
+	}
+	if l.MaxLines > 0 && len(lines) > l.MaxLines {
+		lines = lines[:l.MaxLines]
+		truncated = true
+	}
+
+	tlines := make([]TextLine, len(lines))
+	for i, line := range lines {
+		if l.Ellipsis {
+			last := i == len(lines)-1
+			if last && truncated {
+				line = l.ellipsize(line, true)
+			} else if l.Width > 0 && l.measure(line) > l.Width {
+				line = l.ellipsize(line, false)
+			}
+		}
+
+		w := l.measure(line)
+		tlines[i] = TextLine{Text: line, Width: w}
+		switch l.Align {
+		case AlignCenter:
+			tlines[i].X = (l.Width - w) / 2
+		case AlignRight:
+			tlines[i].X = l.Width - w
+		}
+		if tlines[i].X < 0 {
+			tlines[i].X = 0
+		}
+	}
+	return tlines, nil
+}
+
+// Extents returns the width and height of the text given when laid out.
+// The width is the width of the widest line, and the height is the line
+// spacing multiplied by the number of lines.
+func (l *TextLayout) Extents(text string) (int, int, error) {
+	lines, err := l.Lines(text)
+	if err != nil {
+		return 0, 0, err
+	}
+	width := 0
+	for _, line := range lines {
+		if w := line.X + line.Width; w > width {
+			width = w
+		}
+	}
+	return width, len(lines) * l.lineHeight(), nil
+}
+
+// TextLayout draws text laid out by 'layout' on to the image, with the top
+// left corner of the box at (x, y). The baseline of the first line is at the
+// same position as the baseline of text drawn by Text.
+// Finally, the (x, y) coordinate of the bottom right corner of the laid out
+// text is returned.
+func (im *Image) TextLayout(x, y int, clr color.Color, layout *TextLayout,
+	text string) (int, int, error) {
+
+	lines, err := layout.Lines(text)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	textClr := image.NewUniform(clr)
+	height := layout.lineHeight()
+	baseline := y + int(raster.Fix32(layout.Size*256)>>8)
+	width := 0
+	for i, line := range lines {
+		pt := freetype.Pt(x+line.X, baseline+i*height)
+		for _, run := range layout.runs(line.Text) {
+			var err error
+			pt, err = face(run.font, layout.Size).DrawString(im, textClr,
+				pt, run.text)
+			if err != nil {
+				return 0, 0, err
+			}
+		}
+		if w := line.X + line.Width; w > width {
+			width = w
+		}
+	}
+	return x + width, y + len(lines)*height, nil
+}
+
+// lineHeight returns the distance between the baselines of two lines in
+// pixels.
+func (l *TextLayout) lineHeight() int {
+	spacing := l.LineSpacing
+	if spacing == 0 {
+		spacing = 1.2
+	}
+	return int(l.Size*spacing + 0.5)
+}
+
+// scale returns the number of 26.6 fixed point units in 1 em, as computed by
+// a freetype context with a DPI of 72.
+func (l *TextLayout) scale() int32 {
+	return int32(l.Size * 64)
+}
+
+// fontFor returns the first font in the fallback chain with a glyph for 'r'.
+// There must be at least one font.
+func (l *TextLayout) fontFor(r rune) *truetype.Font {
+	for _, font := range l.Fonts {
+		if font.Index(r) != 0 {
+			return font
+		}
+	}
+	return l.Fonts[0]
+}
+
+// runs splits a line into runs of characters that are drawn with the same
+// font.
+func (l *TextLayout) runs(text string) []textRun {
+	var runs []textRun
+	start := 0
+	var cur *truetype.Font
+	for i, r := range text {
+		font := l.fontFor(r)
+		if font != cur && i > start {
+			runs = append(runs, textRun{cur, text[start:i]})
+			start = i
+		}
+		cur = font
+	}
+	if start < len(text) {
+		runs = append(runs, textRun{cur, text[start:]})
+	}
+	return runs
+}
+
+// measure returns the width of a line of text in pixels, rounded up.
+// Like DrawString, kerning is only applied between glyphs of the same font.
+func (l *TextLayout) measure(text string) int {
+	scale := l.scale()
+	var width raster.Fix32
+	for _, run := range l.runs(text) {
+		prev, hasPrev := truetype.Index(0), false
+		for _, r := range run.text {
+			index := run.font.Index(r)
+			if hasPrev {
+				width += raster.Fix32(
+					run.font.Kerning(scale, prev, index)) << 2
+			}
+			width += raster.Fix32(
+				run.font.HMetric(scale, index).AdvanceWidth) << 2
+			prev, hasPrev = index, true
+		}
+	}
+	return int((width + 255) >> 8)
+}
+
+// wrap breaks a paragraph into lines that fit in the width of the layout.
+// Lines are broken at spaces when possible, and within words otherwise.
+func (l *TextLayout) wrap(para string) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Split(para, " ") {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if l.measure(candidate) <= l.Width {
+			line = candidate
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+
+		// The word doesn't fit on a line of its own, so break it up.
+		for l.measure(word) > l.Width {
+			n := l.fit(word)
+			lines = append(lines, word[:n])
+			word = word[n:]
+		}
+		line = word
+	}
+	return append(lines, line)
+}
+
+// fit returns the length in bytes of the longest prefix of 'text' that fits
+// in the width of the layout. At least one character is always included, so
+// that wrapping makes progress.
+func (l *TextLayout) fit(text string) int {
+	n := 0
+	for i, r := range text {
+		end := i + len(string(r))
+		if n > 0 && l.measure(text[:end]) > l.Width {
+			break
+		}
+		n = end
+	}
+	return n
+}
+
+// ellipsize truncates a line so that it ends with an ellipsis and fits in
+// the width of the layout. If 'always' is true, the ellipsis is added even if
+// the line already fits, to show that lines after it were dropped.
+// If not even the ellipsis fits, an empty string is returned.
+func (l *TextLayout) ellipsize(line string, always bool) string {
+	if !always && (l.Width <= 0 || l.measure(line) <= l.Width) {
+		return line
+	}
+
+	dots := string(ellipsis)
+	if l.fontFor(ellipsis).Index(ellipsis) == 0 {
+		dots = ellipsisASCII
+	}
+
+	runes := []rune(strings.TrimRight(line, " "))
+	for len(runes) > 0 {
+		s := strings.TrimRight(string(runes), " ") + dots
+		if l.Width <= 0 || l.measure(s) <= l.Width {
+			return s
+		}
+		runes = runes[:len(runes)-1]
+	}
+	if l.Width <= 0 || l.measure(dots) <= l.Width {
+		return dots
+	}
+	return ""
+}
@@ -7,7 +7,6 @@ import (
 	"image/draw"
 	"math"
 
-	"github.com/BurntSushi/graphics-go/graphics"
 	"github.com/BurntSushi/xgb/xproto"
 
 	"github.com/BurntSushi/xgbutil"
@@ -20,11 +19,18 @@ xgraphics/util.go contains a variety of image manipulation functions that
 are not specific to xgraphics.Image.
 */
 
-// Scale is a simple wrapper around graphics.Scale.
+// Scale returns any image scaled to the size provided with the bicubic
+// filter. The result is an *Image that isn't associated with an X connection.
+// (Use ScaleWith on an *Image to pick a different filter.)
 func Scale(img image.Image, width, height int) draw.Image {
-	dimg := image.NewRGBA(image.Rect(0, 0, width, height))
-	graphics.Scale(dimg, img)
+	src, ok := img.(*Image)
+	if !ok {
+		src = newImage(nil, img.Bounds())
+		convert(src, img)
+	}
 
+	dimg := newImage(nil, image.Rect(0, 0, width, height))
+	scaleInto(dimg, src, ScaleBicubic)
 	return dimg
 }
 
@@ -32,15 +38,18 @@ func Scale(img image.Image, width, height int) draw.Image {
 // existingAlpha = existingAlpha * (givenAlpha / 100.0)
 func Alpha(dest *Image, alpha int) {
 	r := dest.Bounds()
-
-	var a, x, y, i int
-	for x = r.Min.X; x < r.Max.X; x++ {
-		for y = r.Min.Y; y < r.Max.Y; y++ {
-			i = dest.PixOffset(x, y)
-			a = int(dest.Pix[i+3])
-			dest.Pix[i+3] = uint8((a * alpha) / 100)
+	dest.Damage(r)
+
+	parallel(r, func(band image.Rectangle) {
+		var a, x, y, i int
+		for y = band.Min.Y; y < band.Max.Y; y++ {
+			for x = band.Min.X; x < band.Max.X; x++ {
+				i = dest.PixOffset(x, y)
+				a = int(dest.Pix[i+3])
+				dest.Pix[i+3] = uint8((a * alpha) / 100)
+			}
 		}
-	}
+	})
 }
 
 // Blend alpha blends the src image (starting at the spt Point) into the
@@ -49,29 +58,46 @@ func Alpha(dest *Image, alpha int) {
 // instead. (It's more efficient.)
 // Blend does not (currently) blend with the destination's alpha channel,
 // only the source's alpha channel.
+// Large images are only split between multiple goroutines when src is an
+// *Image, since other images may not be safe to read from multiple
+// goroutines. (See SetWorkers.)
 func Blend(dest *Image, src image.Image, sp image.Point) {
 	rsrc, dsrc := src.Bounds(), dest.Bounds()
 	_, smxx, _, smxy := rsrc.Min.X, rsrc.Max.X, rsrc.Min.Y, rsrc.Max.Y
 	dmnx, dmxx, dmny, dmxy := dsrc.Min.X, dsrc.Max.X, dsrc.Min.Y, dsrc.Max.Y
 
-	var sx, dx, sy, dy int
-	var sr, sg, sb, sa uint32
-	var bgra BGRA
-	var alpha float64
-	for sx, dx = sp.X, dmnx; sx < smxx && dx < dmxx; sx, dx = sx+1, dx+1 {
-		for sy, dy = sp.Y, dmny; sy < smxy && dy < dmxy; sy, dy = sy+1, dy+1 {
-			sr, sg, sb, sa = src.At(sx, sy).RGBA()
-			bgra = dest.At(dx, dy).(BGRA)
-			alpha = float64(uint8(sa)) / 255.0
-
-			dest.SetBGRA(dx, dy, BGRA{
-				blend(uint8(bgra.B), uint8(sb), alpha),
-				blend(uint8(bgra.G), uint8(sg), alpha),
-				blend(uint8(bgra.R), uint8(sr), alpha),
-				0xff,
-			})
-		}
+	dest.Damage(image.Rect(dmnx, dmny,
+		dmnx+smxx-sp.X, dmny+smxy-sp.Y))
+
+	// Each band of destination rows is blended with the source rows that
+	// line up with it.
+	rows := image.Rect(dmnx, dmny, dmxx, dmxy)
+	if max := dmny + smxy - sp.Y; max < rows.Max.Y {
+		rows.Max.Y = max
+	}
+	split := serial
+	if _, ok := src.(*Image); ok {
+		split = parallel
 	}
+	split(rows, func(band image.Rectangle) {
+		var sx, dx, sy, dy, i int
+		var sr, sg, sb, sa uint32
+		var alpha float64
+		sy = sp.Y + band.Min.Y - dmny
+		for dy = band.Min.Y; dy < band.Max.Y; sy, dy = sy+1, dy+1 {
+			sx, dx = sp.X, dmnx
+			for ; sx < smxx && dx < dmxx; sx, dx = sx+1, dx+1 {
+				sr, sg, sb, sa = src.At(sx, sy).RGBA()
+				alpha = float64(uint8(sa)) / 255.0
+
+				i = dest.PixOffset(dx, dy)
+				dest.Pix[i+0] = blend(dest.Pix[i+0], uint8(sb), alpha)
+				dest.Pix[i+1] = blend(dest.Pix[i+1], uint8(sg), alpha)
+				dest.Pix[i+2] = blend(dest.Pix[i+2], uint8(sr), alpha)
+				dest.Pix[i+3] = 0xff
+			}
+		}
+	})
 }
 
 // BlendBgColor blends the Image (receiver) into the background color
@@ -81,21 +107,22 @@ func BlendBgColor(dest *Image, c color.Color) {
 	r := dest.Bounds()
 	cr32, cg32, cb32, _ := c.RGBA()
 	cr, cg, cb := uint8(cr32), uint8(cg32), uint8(cb32)
-
-	var bgra BGRA
-	var alpha float64
-	for x := r.Min.X; x < r.Max.X; x++ {
-		for y := r.Min.Y; y < r.Max.Y; y++ {
-			bgra = dest.At(x, y).(BGRA)
-			alpha = float64(bgra.A) / 255.0
-			dest.SetBGRA(x, y, BGRA{
-				B: blend(cb, bgra.B, alpha),
-				G: blend(cg, bgra.G, alpha),
-				R: blend(cr, bgra.R, alpha),
-				A: 0xff,
-			})
+	dest.Damage(r)
+
+	parallel(r, func(band image.Rectangle) {
+		var x, y, i int
+		var alpha float64
+		for y = band.Min.Y; y < band.Max.Y; y++ {
+			for x = band.Min.X; x < band.Max.X; x++ {
+				i = dest.PixOffset(x, y)
+				alpha = float64(dest.Pix[i+3]) / 255.0
+				dest.Pix[i+0] = blend(cb, dest.Pix[i+0], alpha)
+				dest.Pix[i+1] = blend(cg, dest.Pix[i+1], alpha)
+				dest.Pix[i+2] = blend(cr, dest.Pix[i+2], alpha)
+				dest.Pix[i+3] = 0xff
+			}
 		}
-	}
+	})
 }
 
 // Blend returns the blended alpha color for src and dest colors.
@@ -131,24 +158,34 @@ func FreePixmap(X *xgbutil.XUtil, pixid xproto.Pixmap) {
 // the size specified.
 // If there are no icons in _NET_WM_ICON, then WM_HINTS will be checked for
 // an icon.
-// If an icon is found from either one and doesn't match the size
-// specified, it will be scaled to that size.
+// If there isn't one there either, the icon of the window's application is
+// looked up in the icon theme by the window's WM_CLASS. (See ThemeIconPath
+// and WmClassIconName.)
+// If an icon is found and doesn't match the size specified, it will be
+// scaled to that size.
 // If the width and height are 0, then the largest icon will be returned with
 // no scaling.
 // If an icon is not found, an error is returned.
 func FindIcon(X *xgbutil.XUtil, wid xproto.Window,
 	width, height int) (*Image, error) {
 
-	var ewmhErr, icccmErr error
+	var ewmhErr, icccmErr, themeErr error
 
 	// First try to get a EWMH style icon.
 	icon, ewmhErr := findIconEwmh(X, wid, width, height)
 	if ewmhErr != nil { // now look for an icccm-style icon
 		icon, icccmErr = findIconIcccm(X, wid)
-		if icccmErr != nil {
-			return nil, fmt.Errorf("Neither a EWMH-style or ICCCM-style icon "+
-				"could be found for window id %x because: %s *AND* %s",
-				wid, ewmhErr, icccmErr)
+	}
+	if ewmhErr != nil && icccmErr != nil { // and then in the icon theme
+		var class *icccm.WmClass
+		class, themeErr = icccm.WmClassGet(X, wid)
+		if themeErr == nil {
+			icon, themeErr = findIconTheme(X, class, width, height)
+		}
+		if themeErr != nil {
+			return nil, fmt.Errorf("Neither a EWMH-style, ICCCM-style or "+
+				"themed icon could be found for window id %x because: "+
+				"%s *AND* %s *AND* %s", wid, ewmhErr, icccmErr, themeErr)
 		}
 	}
 
@@ -156,7 +193,7 @@ func FindIcon(X *xgbutil.XUtil, wid xproto.Window,
 	// If the size doesn't match what's preferred, scale it.
 	if width != 0 && height != 0 {
 		if icon.Bounds().Dx() != width || icon.Bounds().Dy() != height {
-			icon = icon.Scale(width, height)
+			icon = icon.ScaleWith(width, height, ScaleLanczos)
 		}
 	}
 	return icon, nil
@@ -0,0 +1,548 @@
+package xgraphics
+
+/*
+xgraphics/vector.go contains anti-aliased drawing primitives: lines,
+rectangles, rounded rectangles, circles, polygons and arbitrary paths, filled
+or stroked with a solid color or a gradient.
+
+Shapes are rasterized with freetype-go's rasterizer (the same one used to
+draw text), which computes the exact coverage of each pixel. Pixels are then
+blended directly into the BGRA data of the image, so drawing is as fast as
+possible and only the rectangle covered by a shape is marked as modified.
+
+Coordinates are floating point, and pixel (x, y) covers the square from
+(x, y) to (x+1, y+1). So a 1 pixel wide horizontal line is crisp when drawn
+at y = 10.5, and blurry (2 half covered rows) when drawn at y = 10. Shapes
+are clipped to the bounds of the image, which makes drawing to a sub-image
+the same as drawing to the part of its parent that it covers.
+*/
+
+import (
+	"image"
+	"math"
+
+	"github.com/BurntSushi/freetype-go/freetype/raster"
+)
+
+// Paint is the source of color for filling and stroking shapes. A BGRA value
+// paints a solid color, while LinearGradient and RadialGradient paint
+// gradients.
+type Paint interface {
+	// BGRAAt returns the color of the pixel (x, y).
+	BGRAAt(x, y int) BGRA
+}
+
+// BGRAAt satisfies the Paint interface, so that a BGRA value paints a solid
+// color.
+func (c BGRA) BGRAAt(x, y int) BGRA {
+	return c
+}
+
+// GradientStop is a color at a position in a gradient. Offset is in the range
+// [0, 1], where 0 is the start of the gradient and 1 is the end.
+type GradientStop struct {
+	Offset float64
+	Color  BGRA
+}
+
+// LinearGradient paints colors that change along the line from (X0, Y0) to
+// (X1, Y1). Stops must be sorted by offset. Pixels before the start or past
+// the end of the line have the color of the first or last stop.
+type LinearGradient struct {
+	X0, Y0, X1, Y1 float64
+	Stops          []GradientStop
+}
+
+// BGRAAt satisfies the Paint interface.
+func (g *LinearGradient) BGRAAt(x, y int) BGRA {
+	dx, dy := g.X1-g.X0, g.Y1-g.Y0
+	length := dx*dx + dy*dy
+	if length == 0 {
+		return gradientAt(g.Stops, 0)
+	}
+	px, py := float64(x)+0.5-g.X0, float64(y)+0.5-g.Y0
+	return gradientAt(g.Stops, (px*dx+py*dy)/length)
+}
+
+// RadialGradient paints colors that change with the distance from (X, Y).
+// The first stop is at the center and the last stop is at Radius. Stops must
+// be sorted by offset.
+type RadialGradient struct {
+	X, Y, Radius float64
+	Stops        []GradientStop
+}
+
+// BGRAAt satisfies the Paint interface.
+func (g *RadialGradient) BGRAAt(x, y int) BGRA {
+	if g.Radius <= 0 {
+		return gradientAt(g.Stops, 1)
+	}
+	px, py := float64(x)+0.5-g.X, float64(y)+0.5-g.Y
+	return gradientAt(g.Stops, math.Sqrt(px*px+py*py)/g.Radius)
+}
+
+// gradientAt returns the color at offset 't' of a gradient. Colors are
+// interpolated with premultiplied alpha, so that a gradient to a transparent
+// color doesn't darken in the middle.
+func gradientAt(stops []GradientStop, t float64) BGRA {
+	switch {
+	case len(stops) == 0:
+		return BGRA{}
+	case t <= stops[0].Offset:
+		return stops[0].Color
+	case t >= stops[len(stops)-1].Offset:
+		return stops[len(stops)-1].Color
+	}
+
+	i := 1
+	for i < len(stops)-1 && stops[i].Offset < t {
+		i++
+	}
+	s0, s1 := stops[i-1], stops[i]
+	f := 0.0
+	if s1.Offset > s0.Offset {
+		f = (t - s0.Offset) / (s1.Offset - s0.Offset)
+	}
+
+	a0, a1 := float64(s0.Color.A), float64(s1.Color.A)
+	a := a0 + (a1-a0)*f
+	if a == 0 {
+		return BGRA{}
+	}
+	mix := func(c0, c1 uint8) uint8 {
+		v := (float64(c0)*a0 + (float64(c1)*a1-float64(c0)*a0)*f) / a
+		return uint8(math.Min(255, math.Max(0, v+0.5)))
+	}
+	return BGRA{
+		B: mix(s0.Color.B, s1.Color.B),
+		G: mix(s0.Color.G, s1.Color.G),
+		R: mix(s0.Color.R, s1.Color.R),
+		A: uint8(a + 0.5),
+	}
+}
+
+// Path is a shape made of lines and quadratic Bézier curves, which can be
+// filled or stroked. A path may contain several sub-paths, each of which
+// starts with MoveTo. Paths are filled with the non-zero winding rule, unless
+// EvenOdd is true. (In which case overlapping sub-paths make holes.)
+type Path struct {
+	EvenOdd bool
+
+	subpaths []subpath
+}
+
+// subpath is a sequence of connected segments. Each segment is either a line
+// (with one point) or a quadratic curve (with a control point and an end
+// point), starting from the end of the previous segment.
+type subpath struct {
+	start    point
+	segments [][]point
+	closed   bool
+}
+
+// point is a point in floating point coordinates.
+type point struct {
+	x, y float64
+}
+
+// NewPath returns an empty path.
+func NewPath() *Path {
+	return &Path{}
+}
+
+// MoveTo starts a new sub-path at (x, y).
+func (p *Path) MoveTo(x, y float64) {
+	p.subpaths = append(p.subpaths, subpath{start: point{x, y}})
+}
+
+// LineTo adds a line from the current point to (x, y). If there is no
+// current point, it is the same as MoveTo.
+func (p *Path) LineTo(x, y float64) {
+	if len(p.subpaths) == 0 {
+		p.MoveTo(x, y)
+		return
+	}
+	p.add(point{x, y})
+}
+
+// QuadTo adds a quadratic Bézier curve from the current point to (x, y),
+// with the control point (cx, cy).
+func (p *Path) QuadTo(cx, cy, x, y float64) {
+	if len(p.subpaths) == 0 {
+		p.MoveTo(cx, cy)
+	}
+	p.add(point{cx, cy}, point{x, y})
+}
+
+// ArcTo adds an elliptical arc around (cx, cy) with the radii given, from the
+// angle 'start' to the angle 'end' (in radians, clockwise from the positive
+// x axis, since y increases downwards). A line is added from the current
+// point to the start of the arc. If there is no current point, the arc starts
+// a new sub-path.
+func (p *Path) ArcTo(cx, cy, rx, ry, start, end float64) {
+	x0, y0 := cx+rx*math.Cos(start), cy+ry*math.Sin(start)
+	if len(p.subpaths) == 0 {
+		p.MoveTo(x0, y0)
+	} else {
+		p.LineTo(x0, y0)
+	}
+
+	// Each piece of the arc (of at most 45 degrees) is approximated by a
+	// quadratic curve whose control point is where the tangents at its ends
+	// meet. The error is less than 0.03% of the radius.
+	n := int(math.Ceil(math.Abs(end-start) / (math.Pi / 4)))
+	if n == 0 {
+		return
+	}
+	step := (end - start) / float64(n)
+	k := 1 / math.Cos(step/2)
+	for i := 1; i <= n; i++ {
+		a := start + step*float64(i)
+		mid := a - step/2
+		p.QuadTo(cx+k*rx*math.Cos(mid), cy+k*ry*math.Sin(mid),
+			cx+rx*math.Cos(a), cy+ry*math.Sin(a))
+	}
+}
+
+// Close closes the current sub-path with a line back to its start. Closed
+// sub-paths are stroked without caps at their start and end.
+func (p *Path) Close() {
+	if len(p.subpaths) == 0 {
+		return
+	}
+	sp := &p.subpaths[len(p.subpaths)-1]
+	if last := sp.current(); last != sp.start {
+		sp.segments = append(sp.segments, []point{sp.start})
+	}
+	sp.closed = true
+}
+
+// Bounds returns the smallest rectangle of pixels that contains every point
+// (including control points) of the path.
+func (p *Path) Bounds() image.Rectangle {
+	minx, miny := math.Inf(1), math.Inf(1)
+	maxx, maxy := math.Inf(-1), math.Inf(-1)
+	for _, sp := range p.subpaths {
+		pts := []point{sp.start}
+		for _, seg := range sp.segments {
+			pts = append(pts, seg...)
+		}
+		for _, pt := range pts {
+			minx, miny = math.Min(minx, pt.x), math.Min(miny, pt.y)
+			maxx, maxy = math.Max(maxx, pt.x), math.Max(maxy, pt.y)
+		}
+	}
+	if minx > maxx {
+		return image.Rectangle{}
+	}
+	return image.Rect(int(math.Floor(minx)), int(math.Floor(miny)),
+		int(math.Ceil(maxx)), int(math.Ceil(maxy)))
+}
+
+// add appends a segment to the current sub-path.
+func (p *Path) add(pts ...point) {
+	sp := &p.subpaths[len(p.subpaths)-1]
+	if sp.closed {
+		// Drawing after Close starts a new sub-path at the same point.
+		p.MoveTo(sp.start.x, sp.start.y)
+		sp = &p.subpaths[len(p.subpaths)-1]
+	}
+	sp.segments = append(sp.segments, pts)
+}
+
+// current returns the current point of a sub-path.
+func (sp *subpath) current() point {
+	if len(sp.segments) == 0 {
+		return sp.start
+	}
+	seg := sp.segments[len(sp.segments)-1]
+	return seg[len(seg)-1]
+}
+
+// RectanglePath returns a path around the rectangle with its top left corner
+// at (x, y) and the size given.
+func RectanglePath(x, y, width, height float64) *Path {
+	p := NewPath()
+	p.MoveTo(x, y)
+	p.LineTo(x+width, y)
+	p.LineTo(x+width, y+height)
+	p.LineTo(x, y+height)
+	p.Close()
+	return p
+}
+
+// RoundedRectanglePath returns a path around the rectangle with its top left
+// corner at (x, y) and the size given, with corners rounded with 'radius'.
+// The radius is limited to half the width or height of the rectangle.
+func RoundedRectanglePath(x, y, width, height, radius float64) *Path {
+	radius = math.Min(radius, math.Min(width, height)/2)
+	if radius <= 0 {
+		return RectanglePath(x, y, width, height)
+	}
+
+	p := NewPath()
+	r := radius
+	p.ArcTo(x+width-r, y+r, r, r, -math.Pi/2, 0)
+	p.ArcTo(x+width-r, y+height-r, r, r, 0, math.Pi/2)
+	p.ArcTo(x+r, y+height-r, r, r, math.Pi/2, math.Pi)
+	p.ArcTo(x+r, y+r, r, r, math.Pi, 3*math.Pi/2)
+	p.Close()
+	return p
+}
+
+// EllipsePath returns a path around the ellipse centered at (cx, cy) with
+// the radii given.
+func EllipsePath(cx, cy, rx, ry float64) *Path {
+	p := NewPath()
+	p.ArcTo(cx, cy, rx, ry, 0, 2*math.Pi)
+	p.Close()
+	return p
+}
+
+// PolygonPath returns a closed path through the points given.
+func PolygonPath(points ...image.Point) *Path {
+	p := NewPath()
+	for _, pt := range points {
+		p.LineTo(float64(pt.X), float64(pt.Y))
+	}
+	p.Close()
+	return p
+}
+
+// Fill fills the inside of a path with 'paint'.
+func (im *Image) Fill(path *Path, paint Paint) {
+	r := im.rasterizer()
+	r.UseNonZeroWinding = !path.EvenOdd
+	im.addPath(r, path, 0)
+	im.paint(r, path.Bounds(), paint)
+}
+
+// Stroke draws the outline of a path with a line 'width' pixels wide,
+// centered on the path. The ends of open sub-paths are square (butt caps),
+// and segments are joined with round joins.
+//
+// Rectangles, rounded rectangles and circles are better stroked with
+// StrokeRectangle, StrokeRoundedRectangle and StrokeCircle, which have
+// sharp (or correctly rounded) outer corners.
+func (im *Image) Stroke(path *Path, width float64, paint Paint) {
+	if width <= 0 {
+		return
+	}
+	r := im.rasterizer()
+	r.UseNonZeroWinding = true
+	im.addPath(r, path, width)
+
+	hw := int(math.Ceil(width / 2))
+	im.paint(r, path.Bounds().Inset(-hw), paint)
+}
+
+// Line draws a line from (x0, y0) to (x1, y1) that is 'width' pixels wide.
+func (im *Image) Line(x0, y0, x1, y1, width float64, paint Paint) {
+	p := NewPath()
+	p.MoveTo(x0, y0)
+	p.LineTo(x1, y1)
+	im.Stroke(p, width, paint)
+}
+
+// FillRectangle fills the rectangle with its top left corner at (x, y) and
+// the size given.
+func (im *Image) FillRectangle(x, y, width, height float64, paint Paint) {
+	im.Fill(RectanglePath(x, y, width, height), paint)
+}
+
+// StrokeRectangle draws a border 'lineWidth' pixels wide, centered on the
+// outline of a rectangle. (So a 1 pixel border inside the pixels of an
+// image.Rectangle r is drawn at r.Min + 0.5, with the size of r minus 1.)
+func (im *Image) StrokeRectangle(x, y, width, height, lineWidth float64,
+	paint Paint) {
+
+	hw := lineWidth / 2
+	p := RectanglePath(x-hw, y-hw, width+lineWidth, height+lineWidth)
+	if width > lineWidth && height > lineWidth {
+		p.append(RectanglePath(x+hw, y+hw,
+			width-lineWidth, height-lineWidth))
+	}
+	p.EvenOdd = true
+	im.Fill(p, paint)
+}
+
+// FillRoundedRectangle fills a rectangle with rounded corners. (See
+// RoundedRectanglePath.)
+func (im *Image) FillRoundedRectangle(x, y, width, height, radius float64,
+	paint Paint) {
+
+	im.Fill(RoundedRectanglePath(x, y, width, height, radius), paint)
+}
+
+// StrokeRoundedRectangle draws a border 'lineWidth' pixels wide, centered on
+// the outline of a rectangle with rounded corners.
+func (im *Image) StrokeRoundedRectangle(x, y, width, height, radius,
+	lineWidth float64, paint Paint) {
+
+	radius = math.Min(radius, math.Min(width, height)/2)
+	hw := lineWidth / 2
+	p := RoundedRectanglePath(x-hw, y-hw, width+lineWidth,
+		height+lineWidth, radius+hw)
+	if width > lineWidth && height > lineWidth {
+		p.append(RoundedRectanglePath(x+hw, y+hw,
+			width-lineWidth, height-lineWidth, radius-hw))
+	}
+	p.EvenOdd = true
+	im.Fill(p, paint)
+}
+
+// FillCircle fills the circle centered at (cx, cy) with the radius given.
+func (im *Image) FillCircle(cx, cy, radius float64, paint Paint) {
+	im.Fill(EllipsePath(cx, cy, radius, radius), paint)
+}
+
+// StrokeCircle draws a ring 'lineWidth' pixels wide, centered on the outline
+// of a circle.
+func (im *Image) StrokeCircle(cx, cy, radius, lineWidth float64,
+	paint Paint) {
+
+	hw := lineWidth / 2
+	p := EllipsePath(cx, cy, radius+hw, radius+hw)
+	if radius > hw {
+		p.append(EllipsePath(cx, cy, radius-hw, radius-hw))
+	}
+	p.EvenOdd = true
+	im.Fill(p, paint)
+}
+
+// FillPolygon fills the polygon through the points given.
+func (im *Image) FillPolygon(points []image.Point, paint Paint) {
+	im.Fill(PolygonPath(points...), paint)
+}
+
+// append adds the sub-paths of another path to this one.
+func (p *Path) append(q *Path) {
+	p.subpaths = append(p.subpaths, q.subpaths...)
+}
+
+// rasterizer returns a rasterizer covering the bounds of the image.
+func (im *Image) rasterizer() *raster.Rasterizer {
+	r := raster.NewRasterizer(im.Rect.Dx(), im.Rect.Dy())
+	r.Dx, r.Dy = im.Rect.Min.X, im.Rect.Min.Y
+	return r
+}
+
+// addPath adds a path to a rasterizer, translated into the coordinates of
+// the rasterizer. If 'width' is not 0, the path is stroked instead.
+func (im *Image) addPath(r *raster.Rasterizer, path *Path, width float64) {
+
+	fix := func(pt point) raster.Point {
+		return raster.Point{
+			X: raster.Fix32((pt.x - float64(im.Rect.Min.X)) * 256),
+			Y: raster.Fix32((pt.y - float64(im.Rect.Min.Y)) * 256),
+		}
+	}
+	for _, sp := range path.subpaths {
+		segments := sp.segments
+		start := sp.start
+		if width != 0 && sp.closed && len(segments) > 0 {
+			// Start (and end) stroking closed sub-paths in the middle of
+			// their first segment, so that the two butt caps meet exactly
+			// and every corner gets a join.
+			first, second := splitSegment(start, segments[0])
+			start = first[len(first)-1]
+			segments = append(append([][]point{second}, segments[1:]...),
+				first)
+		}
+
+		var q raster.Path
+		q.Start(fix(start))
+		for _, seg := range segments {
+			switch len(seg) {
+			case 1:
+				q.Add1(fix(seg[0]))
+			case 2:
+				q.Add2(fix(seg[0]), fix(seg[1]))
+			}
+		}
+		if width != 0 {
+			r.AddStroke(q, raster.Fix32(width*256),
+				raster.ButtCapper, raster.RoundJoiner)
+		} else {
+			r.AddPath(q)
+		}
+	}
+}
+
+// splitSegment splits a segment starting at 'from' in half. The first half
+// starts at 'from', and the second half starts at the end of the first.
+func splitSegment(from point, seg []point) ([]point, []point) {
+	mid := func(a, b point) point {
+		return point{(a.x + b.x) / 2, (a.y + b.y) / 2}
+	}
+	if len(seg) == 1 {
+		m := mid(from, seg[0])
+		return []point{m}, []point{seg[0]}
+	}
+
+	// de Casteljau's algorithm at t = 0.5
+	c, end := seg[0], seg[1]
+	c1, c2 := mid(from, c), mid(c, end)
+	m := mid(c1, c2)
+	return []point{c1, m}, []point{c2, end}
+}
+
+// paint blends 'paint' into the image, using the coverage of each pixel
+// computed by the rasterizer. 'bounds' is the area that may be painted, which
+// is marked as modified.
+func (im *Image) paint(r *raster.Rasterizer, bounds image.Rectangle,
+	paint Paint) {
+
+	bounds = bounds.Intersect(im.Rect)
+	if bounds.Empty() {
+		return
+	}
+	im.Damage(bounds)
+
+	solid, isSolid := paint.(BGRA)
+	r.Rasterize(raster.PainterFunc(func(spans []raster.Span, done bool) {
+		for _, s := range spans {
+			if s.Y < im.Rect.Min.Y || s.Y >= im.Rect.Max.Y {
+				continue
+			}
+			x0, x1 := s.X0, s.X1
+			if x0 < im.Rect.Min.X {
+				x0 = im.Rect.Min.X
+			}
+			if x1 > im.Rect.Max.X {
+				x1 = im.Rect.Max.X
+			}
+
+			cov := s.A >> 16
+			i := im.PixOffset(x0, s.Y)
+			for x := x0; x < x1; x, i = x+1, i+4 {
+				c := solid
+				if !isSolid {
+					c = paint.BGRAAt(x, s.Y)
+				}
+				blendOver(im.Pix[i:i+4], c, cov)
+			}
+		}
+	}))
+}
+
+// blendOver blends the color 'c' with a coverage of 'cov' (from 0 to 0xffff)
+// over the BGRA pixel 'dst', using straight (not premultiplied) alpha.
+func blendOver(dst []uint8, c BGRA, cov uint32) {
+	sa := uint32(c.A) * cov / 0xff
+	if sa == 0 {
+		return
+	}
+	if sa == 0xffff {
+		dst[0], dst[1], dst[2], dst[3] = c.B, c.G, c.R, 0xff
+		return
+	}
+
+	da := uint32(dst[3]) * 0x101
+	dw := da * (0xffff - sa) / 0xffff
+	oa := sa + dw
+	dst[0] = uint8((uint32(c.B)*sa + uint32(dst[0])*dw) / oa)
+	dst[1] = uint8((uint32(c.G)*sa + uint32(dst[1])*dw) / oa)
+	dst[2] = uint8((uint32(c.R)*sa + uint32(dst[2])*dw) / oa)
+	dst[3] = uint8(oa / 0x101)
+}
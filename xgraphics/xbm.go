@@ -0,0 +1,205 @@
+package xgraphics
+
+/*
+xgraphics/xbm.go contains a decoder and an encoder for XBM (X BitMap) images,
+which are used by older X applications for cursors, masks and icons.
+
+An XBM image is C source code with the width and height of the image, and an
+array of bytes with one bit for each pixel. Each row is padded to a whole
+byte, and the least significant bit of each byte is the leftmost pixel. For
+example:
+
+	#define plus_width 3
+	#define plus_height 3
+	static unsigned char plus_bits[] = {
+	   0x02, 0x07, 0x02};
+
+The older X10 format, with an array of 16 bit shorts instead of bytes, can be
+read too.
+
+Like bitmaps read from X (see NewDrawable), decoded images are alpha masks:
+set bits are opaque black and others are transparent.
+
+The decoder is registered with the image package, so XBM images can be read
+with image.Decode, NewFileName and NewBytes.
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// xbmPalette is the color palette of decoded XBM images. Index 0 is used for
+// unset bits, and index 1 for set bits.
+var xbmPalette = color.Palette{
+	color.NRGBA{0xff, 0xff, 0xff, 0x0},
+	color.NRGBA{0x0, 0x0, 0x0, 0xff},
+}
+
+func init() {
+	image.RegisterFormat("xbm", "#define ", DecodeXbm, DecodeXbmConfig)
+}
+
+// xbmHeader contains the #define values of an XBM image.
+type xbmHeader struct {
+	width, height int
+}
+
+// DecodeXbm reads an XBM image. The image returned is an *image.Paletted,
+// with transparent pixels for unset bits and opaque black pixels for set
+// bits.
+func DecodeXbm(r io.Reader) (image.Image, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	hdr, err := parseXbmHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// The bits are between the braces of the array.
+	start := bytes.IndexByte(data, '{')
+	end := bytes.LastIndex(data, []byte("}"))
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("XBM image has no array of bits.")
+	}
+	bits := 8
+	if bytes.Contains(data[:start], []byte("short")) {
+		bits = 16
+	}
+
+	var values []uint16
+	for _, field := range strings.Split(string(data[start+1:end]), ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		v, err := strconv.ParseUint(field, 0, bits)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid value '%s' in XBM image.", field)
+		}
+		values = append(values, uint16(v))
+	}
+
+	perRow := (hdr.width + bits - 1) / bits
+	if len(values) < perRow*hdr.height {
+		return nil, fmt.Errorf("XBM image has %d values, but %d are "+
+			"expected.", len(values), perRow*hdr.height)
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, hdr.width, hdr.height),
+		xbmPalette)
+	for y := 0; y < hdr.height; y++ {
+		row := values[y*perRow:]
+		for x := 0; x < hdr.width; x++ {
+			if row[x/bits]>>uint(x%bits)&1 != 0 {
+				img.Pix[img.PixOffset(x, y)] = 1
+			}
+		}
+	}
+	return img, nil
+}
+
+// DecodeXbmConfig returns the size of an XBM image without reading its bits.
+func DecodeXbmConfig(r io.Reader) (image.Config, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	hdr, err := parseXbmHeader(data)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{
+		ColorModel: xbmPalette,
+		Width:      hdr.width,
+		Height:     hdr.height,
+	}, nil
+}
+
+// EncodeXbm writes an image as an XBM image, with 'name' as the prefix of the
+// names in it. A bit is set for pixels that are mostly opaque and dark. (So
+// that images decoded by DecodeXbm and black and white images are encoded as
+// expected.)
+func EncodeXbm(w io.Writer, img image.Image, name string) error {
+	r := img.Bounds()
+	name = cName(name)
+	perRow := (r.Dx() + 7) / 8
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "#define %s_width %d\n", name, r.Dx())
+	fmt.Fprintf(bw, "#define %s_height %d\n", name, r.Dy())
+	fmt.Fprintf(bw, "static unsigned char %s_bits[] = {", name)
+
+	n := 0
+	row := make([]byte, perRow)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for i := range row {
+			row[i] = 0
+		}
+		for x := r.Min.X; x < r.Max.X; x++ {
+			cr, cg, cb, ca := img.At(x, y).RGBA()
+			gray := (299*cr + 587*cg + 114*cb) / 1000
+			if ca >= 0x8000 && gray < ca/2 {
+				i := x - r.Min.X
+				row[i/8] |= 1 << uint(i%8)
+			}
+		}
+
+		// Like bitmap(1), write 12 values on each line.
+		for _, b := range row {
+			if n > 0 {
+				bw.WriteString(",")
+			}
+			if n%12 == 0 {
+				bw.WriteString("\n  ")
+			}
+			fmt.Fprintf(bw, " 0x%02x", b)
+			n++
+		}
+	}
+	bw.WriteString("};\n")
+	return bw.Flush()
+}
+
+// parseXbmHeader reads the width and height of an XBM image from its
+// #define lines.
+func parseXbmHeader(data []byte) (xbmHeader, error) {
+	hdr := xbmHeader{-1, -1}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[0] != "#define" {
+			continue
+		}
+		n, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+		switch {
+		case strings.HasSuffix(fields[1], "width"):
+			hdr.width = n
+		case strings.HasSuffix(fields[1], "height"):
+			hdr.height = n
+		}
+	}
+	if !validImageSize(hdr.width, hdr.height) {
+		return xbmHeader{}, fmt.Errorf("XBM image has an invalid or " +
+			"missing width or height.")
+	}
+	return hdr, nil
+}
+
+// validImageSize returns whether the width and height read from an image file
+// are valid, and not too big. (See maxImagePixels.)
+func validImageSize(width, height int) bool {
+	return width >= 0 && height >= 0 &&
+		width <= maxImagePixels && height <= maxImagePixels &&
+		width*height <= maxImagePixels
+}
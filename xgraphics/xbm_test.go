@@ -0,0 +1,126 @@
+package xgraphics
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// TestXbmDecode checks the pixels of decoded XBM images.
+func TestXbmDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		xbm  string
+		want [][]color.NRGBA
+	}{
+		{"plus", `#define plus_width 3
+#define plus_height 3
+static unsigned char plus_bits[] = {
+   0x02, 0x07, 0x02};`, [][]color.NRGBA{
+			{none, black, none},
+			{black, black, black},
+			{none, black, none},
+		}},
+		{"rows padded to bytes", `#define img_width 10
+#define img_height 1
+#define img_x_hot 1
+static char img_bits[] = { 0x01, 0x02 };`, [][]color.NRGBA{
+			{black, none, none, none, none, none, none, none, none, black},
+		}},
+		{"X10 shorts", `#define img_width 17
+#define img_height 1
+static short img_bits[] = {
+   0x8001, 0x0001};`, [][]color.NRGBA{
+			{black, none, none, none, none, none, none, none,
+				none, none, none, none, none, none, none, black, black},
+		}},
+		{"decimal and octal values", `#define img_width 3
+#define img_height 2
+static char img_bits[] = { 5, 02, };`, [][]color.NRGBA{
+			{black, none, black},
+			{none, black, none},
+		}},
+	}
+	for _, test := range tests {
+		img, err := DecodeXbm(strings.NewReader(test.xbm))
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		checkPixels(t, test.name, img, test.want)
+	}
+}
+
+// TestXbmMalformed checks that malformed XBM images are rejected.
+func TestXbmMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		xbm  string
+	}{
+		{"no width", "#define img_height 1\nstatic char img_bits[] = {0};"},
+		{"no height", "#define img_width 1\nstatic char img_bits[] = {0};"},
+		{"negative width",
+			"#define img_width -1\n#define img_height 1\n{0}"},
+		{"huge image",
+			"#define img_width 100000\n#define img_height 100000\n{0}"},
+		{"no array", "#define img_width 1\n#define img_height 1\n"},
+		{"unterminated array",
+			"#define img_width 1\n#define img_height 1\n{0x01"},
+		{"bad value", "#define img_width 1\n#define img_height 1\n{0xzz}"},
+		{"value too big",
+			"#define img_width 1\n#define img_height 1\n{0x100}"},
+		{"too few values",
+			"#define img_width 9\n#define img_height 2\n{0x01, 0x02, 0x03}"},
+	}
+	for _, test := range tests {
+		if _, err := DecodeXbm(strings.NewReader(test.xbm)); err == nil {
+			t.Fatalf("%s: The image was decoded without an error.",
+				test.name)
+		}
+	}
+}
+
+// TestXbmRoundTrip checks that images encoded by EncodeXbm decode to the
+// same image, for widths that do and don't fill the last byte of each row.
+func TestXbmRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, width := range []int{1, 7, 8, 9, 16, 30} {
+		src := image.NewNRGBA(image.Rect(-2, 4, width-2, 4+5))
+		want := make([][]color.NRGBA, 5)
+		for y := range want {
+			want[y] = make([]color.NRGBA, width)
+			for x := range want[y] {
+				switch rng.Intn(3) {
+				case 0:
+					want[y][x] = black
+					src.SetNRGBA(x-2, 4+y, black)
+				case 1:
+					// Light pixels aren't set.
+					src.SetNRGBA(x-2, 4+y, white)
+				}
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := EncodeXbm(&buf, src, "round-trip"); err != nil {
+			t.Fatalf("Width %d: %s", width, err)
+		}
+		img, format, err := image.Decode(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("Width %d: %s\n%s", width, err, buf.String())
+		}
+		if format != "xbm" {
+			t.Fatalf("Width %d: The image was decoded as '%s'.",
+				width, format)
+		}
+		checkPixels(t, "round trip", img, want)
+
+		config, err := DecodeXbmConfig(bytes.NewReader(buf.Bytes()))
+		if err != nil || config.Width != width || config.Height != 5 {
+			t.Fatalf("Width %d: DecodeXbmConfig returned %dx%d (%v).",
+				width, config.Width, config.Height, err)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package xgraphics
+
+/*
+xgraphics/xpm.go contains a decoder and an encoder for XPM (X PixMap) images,
+which many older X applications use for their icons.
+
+An XPM image is a C array of strings, after a comment with the text "XPM".
+The first string has the width, height, number of colors and number of
+characters per pixel. Each of the next strings maps a sequence of characters
+to a color, and the rest are the rows of the image. For example:
+
+	static char *plus[] = {
+	"3 3 2 1",
+	"  c None",
+	"x c #ff0000",
+	" x ",
+	"xxx",
+	" x "
+	};
+
+Colors can be given for different kinds of displays ("c" for color, "g" for
+grayscale and "m" for monochrome). The color one is used when it's there.
+Colors are either hexadecimal RGB values, "None" (which is transparent) or
+the name of a color from X11's rgb.txt (see rgb_auto.go).
+
+The decoder is registered with the image package, so XPM images can be read
+with image.Decode, NewFileName and NewBytes.
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// xpmMagic is the comment that every XPM file starts with.
+const xpmMagic = "/* XPM */"
+
+// xpmChars are the characters used for pixels by EncodeXpm. They're the same
+// as those used by libXpm, and exclude the characters that would need to be
+// escaped in a C string.
+const xpmChars = " .XoO+@#$%&*=-;:>,<1234567890qwertyuipasdfghjklzxcvbnm" +
+	"MNBVCZASDFGHJKLPIUYTREWQ!~^/()_`'][{}|"
+
+// maxImagePixels is the largest number of pixels in an image read by the XPM
+// and XBM decoders. It guards against allocating huge images for corrupt
+// files.
+const maxImagePixels = 1 << 26
+
+func init() {
+	image.RegisterFormat("xpm", xpmMagic, DecodeXpm, DecodeXpmConfig)
+}
+
+// xpmHeader is the first string of an XPM image.
+type xpmHeader struct {
+	width, height, ncolors, cpp int
+}
+
+// DecodeXpm reads an XPM image. The image returned is an *image.NRGBA.
+func DecodeXpm(r io.Reader) (image.Image, error) {
+	strs, err := readXpmStrings(r, -1)
+	if err != nil {
+		return nil, err
+	}
+	hdr, err := parseXpmHeader(strs)
+	if err != nil {
+		return nil, err
+	}
+	// The header values are bounded by parseXpmHeader, but are compared to
+	// the number of strings one at a time anyway, so that nothing overflows.
+	if len(strs)-1 < hdr.ncolors || len(strs)-1-hdr.ncolors < hdr.height {
+		return nil, fmt.Errorf("XPM image has %d strings, but %d colors "+
+			"and %d rows are expected.", len(strs), hdr.ncolors, hdr.height)
+	}
+
+	colors := make(map[string]color.NRGBA, hdr.ncolors)
+	for _, s := range strs[1 : 1+hdr.ncolors] {
+		if len(s) < hdr.cpp {
+			return nil, fmt.Errorf("Invalid XPM color '%s'.", s)
+		}
+		c, err := parseXpmColorSpec(s[hdr.cpp:])
+		if err != nil {
+			return nil, err
+		}
+		colors[s[:hdr.cpp]] = c
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, hdr.width, hdr.height))
+	for y, row := range strs[1+hdr.ncolors : 1+hdr.ncolors+hdr.height] {
+		if len(row) < hdr.width*hdr.cpp {
+			return nil, fmt.Errorf("Row %d of XPM image is too short.", y)
+		}
+		for x := 0; x < hdr.width; x++ {
+			key := row[x*hdr.cpp : (x+1)*hdr.cpp]
+			c, ok := colors[key]
+			if !ok {
+				return nil, fmt.Errorf("XPM image has pixel '%s' at "+
+					"(%d, %d), which isn't a color.", key, x, y)
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img, nil
+}
+
+// DecodeXpmConfig returns the size of an XPM image without reading all of
+// it.
+func DecodeXpmConfig(r io.Reader) (image.Config, error) {
+	strs, err := readXpmStrings(r, 1)
+	if err != nil {
+		return image.Config{}, err
+	}
+	hdr, err := parseXpmHeader(strs)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{
+		ColorModel: color.NRGBAModel,
+		Width:      hdr.width,
+		Height:     hdr.height,
+	}, nil
+}
+
+// EncodeXpm writes an image as an XPM image, with 'name' as the name of the
+// C array. Pixels with an alpha value less than 128 are transparent ("None"),
+// and other pixels are opaque, since XPM doesn't support translucency.
+func EncodeXpm(w io.Writer, img image.Image, name string) error {
+	r := img.Bounds()
+
+	// Find all the colors used. A negative value means transparent.
+	var colors []int32
+	index := make(map[int32]int)
+	pixels := make([]int, 0, r.Dx()*r.Dy())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			rgb := int32(-1)
+			if c.A >= 0x80 {
+				rgb = int32(c.R)<<16 | int32(c.G)<<8 | int32(c.B)
+			}
+			i, ok := index[rgb]
+			if !ok {
+				i = len(colors)
+				index[rgb] = i
+				colors = append(colors, rgb)
+			}
+			pixels = append(pixels, i)
+		}
+	}
+
+	// Use as few characters per pixel as possible.
+	cpp := 1
+	for n := len(xpmChars); n < len(colors); n *= len(xpmChars) {
+		cpp++
+	}
+	keys := make([]string, len(colors))
+	for i := range colors {
+		key := make([]byte, cpp)
+		for j, k := cpp-1, i; j >= 0; j, k = j-1, k/len(xpmChars) {
+			key[j] = xpmChars[k%len(xpmChars)]
+		}
+		keys[i] = string(key)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s\nstatic char *%s[] = {\n", xpmMagic, cName(name))
+	fmt.Fprintf(bw, "\"%d %d %d %d\",\n", r.Dx(), r.Dy(), len(colors), cpp)
+	for i, rgb := range colors {
+		if rgb < 0 {
+			fmt.Fprintf(bw, "\"%s c None\",\n", keys[i])
+		} else {
+			fmt.Fprintf(bw, "\"%s c #%06x\",\n", keys[i], rgb)
+		}
+	}
+	for y := 0; y < r.Dy(); y++ {
+		bw.WriteByte('"')
+		for _, i := range pixels[y*r.Dx() : (y+1)*r.Dx()] {
+			bw.WriteString(keys[i])
+		}
+		if y < r.Dy()-1 {
+			bw.WriteString("\",\n")
+		} else {
+			bw.WriteString("\"\n")
+		}
+	}
+	bw.WriteString("};\n")
+	return bw.Flush()
+}
+
+// readXpmStrings returns the C strings in an XPM image, skipping comments.
+// If 'max' isn't negative, at most 'max' strings are returned.
+func readXpmStrings(r io.Reader, max int) ([]string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte(xpmMagic)) {
+		return nil, fmt.Errorf("Not an XPM image.")
+	}
+
+	var strs []string
+	for i := len(xpmMagic); i < len(data) && max != 0; i++ {
+		switch {
+		case data[i] == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				return nil, fmt.Errorf("Unterminated comment in XPM image.")
+			}
+			i += end + 3
+		case data[i] == '"':
+			var s []byte
+			for i++; i < len(data) && data[i] != '"'; i++ {
+				if data[i] == '\\' && i+1 < len(data) {
+					i++
+				}
+				s = append(s, data[i])
+			}
+			if i >= len(data) {
+				return nil, fmt.Errorf("Unterminated string in XPM image.")
+			}
+			strs = append(strs, string(s))
+			max--
+		}
+	}
+	if len(strs) == 0 {
+		return nil, fmt.Errorf("XPM image has no strings.")
+	}
+	return strs, nil
+}
+
+// parseXpmHeader parses the first string of an XPM image.
+func parseXpmHeader(strs []string) (xpmHeader, error) {
+	fields := strings.Fields(strs[0])
+	if len(fields) < 4 {
+		return xpmHeader{}, fmt.Errorf("Invalid XPM header '%s'.", strs[0])
+	}
+
+	var nums [4]int
+	for i := range nums {
+		n, err := strconv.Atoi(fields[i])
+		if err != nil || n < 0 {
+			return xpmHeader{}, fmt.Errorf("Invalid XPM header '%s'.",
+				strs[0])
+		}
+		nums[i] = n
+	}
+	hdr := xpmHeader{nums[0], nums[1], nums[2], nums[3]}
+	if hdr.cpp < 1 || hdr.cpp > 8 || !validImageSize(hdr.width, hdr.height) ||
+		hdr.ncolors > maxImagePixels {
+
+		return xpmHeader{}, fmt.Errorf("Invalid XPM header '%s'.", strs[0])
+	}
+	return hdr, nil
+}
+
+// parseXpmColorSpec parses the colors of a pixel (after its characters) and
+// returns the one for color displays, or the next best one.
+func parseXpmColorSpec(spec string) (color.NRGBA, error) {
+	values := make(map[string]string)
+	key := ""
+	for _, word := range strings.Fields(spec) {
+		switch word {
+		case "c", "g", "g4", "m", "s":
+			key = word
+			values[key] = ""
+			continue
+		}
+		if key == "" {
+			continue
+		}
+		if values[key] != "" {
+			values[key] += " "
+		}
+		values[key] += word
+	}
+
+	for _, key := range []string{"c", "g", "g4", "m"} {
+		if v, ok := values[key]; ok {
+			return parseXpmColor(v)
+		}
+	}
+	return color.NRGBA{}, fmt.Errorf("Invalid XPM color '%s'.", spec)
+}
+
+// parseXpmColor parses a color, which is "None", a hexadecimal RGB value or
+// the name of a color in rgb.txt.
+func parseXpmColor(s string) (color.NRGBA, error) {
+	if strings.EqualFold(s, "none") {
+		return color.NRGBA{}, nil
+	}
+	if strings.HasPrefix(s, "#") {
+		return parseHexColor(s[1:])
+	}
+
+	name := strings.ToLower(strings.Replace(s, " ", "", -1))
+	rgb, ok := rgbColors[name]
+	if !ok {
+		return color.NRGBA{}, fmt.Errorf("Unknown color '%s'.", s)
+	}
+	return color.NRGBA{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), 0xff},
+		nil
+}
+
+// parseHexColor parses a color with 1 to 4 hexadecimal digits for each of
+// red, green and blue. (Like 'fff' or 'ffffff'.) Only the 8 most significant
+// bits of each are used.
+func parseHexColor(s string) (color.NRGBA, error) {
+	if len(s) == 0 || len(s)%3 != 0 || len(s) > 12 {
+		return color.NRGBA{}, fmt.Errorf("Invalid color '#%s'.", s)
+	}
+
+	n := len(s) / 3
+	var rgb [3]uint8
+	for i := range rgb {
+		v, err := strconv.ParseUint(s[i*n:(i+1)*n], 16, 16)
+		if err != nil {
+			return color.NRGBA{}, fmt.Errorf("Invalid color '#%s'.", s)
+		}
+		switch n {
+		case 1:
+			rgb[i] = uint8(v * 0x11)
+		default:
+			rgb[i] = uint8(v >> uint(4*(n-2)))
+		}
+	}
+	return color.NRGBA{rgb[0], rgb[1], rgb[2], 0xff}, nil
+}
+
+// cName turns 'name' into a valid C identifier, for the names of the arrays
+// in XPM and XBM images.
+func cName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "image"
+	}
+	return string(b)
+}
@@ -0,0 +1,195 @@
+package xgraphics
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// xpmPlus is the example in xpm.go.
+const xpmPlus = `/* XPM */
+static char *plus[] = {
+"3 3 2 1",
+"  c None",
+"x c #ff0000",
+" x ",
+"xxx",
+" x "
+};
+`
+
+var (
+	red   = color.NRGBA{0xff, 0, 0, 0xff}
+	none  = color.NRGBA{}
+	black = color.NRGBA{0, 0, 0, 0xff}
+	white = color.NRGBA{0xff, 0xff, 0xff, 0xff}
+)
+
+// TestXpmDecode checks the pixels of decoded XPM images.
+func TestXpmDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		xpm  string
+		want [][]color.NRGBA
+	}{
+		{"plus", xpmPlus, [][]color.NRGBA{
+			{none, red, none},
+			{red, red, red},
+			{none, red, none},
+		}},
+		{"two characters per pixel and comments", `/* XPM */
+/* a comment */
+static char *img[] = {
+/* width height ncolors cpp */
+"2 1 2 2",
+"aa c #000",
+"ab c white",
+"abaa"
+};`, [][]color.NRGBA{{white, black}}},
+		{"mono and gray colors", `/* XPM */
+static char *img[] = {
+"3 1 3 1",
+"a m black",
+"b g white m black",
+"c s symbolic c #ffff00000000 m white",
+"abc"
+};`, [][]color.NRGBA{{black, white, red}}},
+		{"color names with spaces", `/* XPM */
+static char *img[] = {
+"1 1 1 1",
+"a c Navy Blue",
+"a"
+};`, [][]color.NRGBA{{{0, 0, 0x80, 0xff}}}},
+		{"quotes in strings", `/* XPM */
+static char *img[] = {
+"2 1 2 1",
+"\" c None",
+"\\ c #00f",
+"\"\\"
+};`, [][]color.NRGBA{{none, {0, 0, 0xff, 0xff}}}},
+		{"empty", `/* XPM */
+static char *img[] = {
+"0 0 0 1"
+};`, nil},
+	}
+	for _, test := range tests {
+		img, err := DecodeXpm(strings.NewReader(test.xpm))
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		checkPixels(t, test.name, img, test.want)
+	}
+}
+
+// TestXpmMalformed checks that malformed XPM images are rejected.
+func TestXpmMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		xpm  string
+	}{
+		{"no magic", `static char *img[] = {"1 1 1 1", "a c None", "a"};`},
+		{"no strings", "/* XPM */\nstatic char *img[] = {};"},
+		{"unterminated comment", `/* XPM */ /* {"1 1 1 1"`},
+		{"unterminated string", `/* XPM */ {"1 1 1 1", "a c None`},
+		{"short header", `/* XPM */ {"1 1 1", "a c None", "a"}`},
+		{"negative width", `/* XPM */ {"-1 1 1 1", "a c None", "a"}`},
+		{"no characters per pixel", `/* XPM */ {"1 1 1 0", "a c None", "a"}`},
+		{"too many characters per pixel",
+			`/* XPM */ {"1 1 1 9", "aaaaaaaaa c None", "aaaaaaaaa"}`},
+		{"huge image", `/* XPM */ {"100000 100000 1 1", "a c None"}`},
+		{"huge color count",
+			`/* XPM */ {"1 1 9223372036854775807 1", "a c None", "a"}`},
+		{"missing colors", `/* XPM */ {"1 1 3 1", "a c None", "a"}`},
+		{"missing rows", `/* XPM */ {"1 2 1 1", "a c None", "a"}`},
+		{"short row", `/* XPM */ {"2 1 1 1", "a c None", "a"}`},
+		{"short color", `/* XPM */ {"1 1 1 2", "a", "aa"}`},
+		{"unknown pixel", `/* XPM */ {"1 1 1 1", "a c None", "b"}`},
+		{"unknown color", `/* XPM */ {"1 1 1 1", "a c nocolor", "a"}`},
+		{"no color", `/* XPM */ {"1 1 1 1", "a", "a"}`},
+		{"bad hex color", `/* XPM */ {"1 1 1 1", "a c #ff00", "a"}`},
+		{"bad hex digits", `/* XPM */ {"1 1 1 1", "a c #gggggg", "a"}`},
+	}
+	for _, test := range tests {
+		if _, err := DecodeXpm(strings.NewReader(test.xpm)); err == nil {
+			t.Fatalf("%s: The image was decoded without an error.",
+				test.name)
+		}
+	}
+}
+
+// TestXpmRoundTrip checks that images encoded by EncodeXpm decode to the
+// same image, with enough colors to need more than one character per pixel.
+func TestXpmRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, ncolors := range []int{1, 2, len(xpmChars), len(xpmChars) + 1,
+		500} {
+
+		palette := make([]color.NRGBA, ncolors)
+		for i := range palette {
+			palette[i] = color.NRGBA{uint8(rng.Intn(256)),
+				uint8(rng.Intn(256)), uint8(rng.Intn(256)), 0xff}
+		}
+		palette[0] = none
+
+		// The image doesn't start at (0, 0), to check that the encoder
+		// handles its bounds. Every color is used at least once.
+		src := image.NewNRGBA(image.Rect(3, 5, 3+31, 5+17))
+		want := make([][]color.NRGBA, 17)
+		for y := range want {
+			want[y] = make([]color.NRGBA, 31)
+			for x := range want[y] {
+				want[y][x] = palette[rng.Intn(ncolors)]
+				if i := y*31 + x; i < ncolors {
+					want[y][x] = palette[i]
+				}
+				src.SetNRGBA(3+x, 5+y, want[y][x])
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := EncodeXpm(&buf, src, "round trip"); err != nil {
+			t.Fatalf("%d colors: %s", ncolors, err)
+		}
+		img, format, err := image.Decode(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("%d colors: %s\n%s", ncolors, err, buf.String())
+		}
+		if format != "xpm" {
+			t.Fatalf("%d colors: The image was decoded as '%s'.",
+				ncolors, format)
+		}
+		checkPixels(t, "round trip", img, want)
+
+		config, err := DecodeXpmConfig(bytes.NewReader(buf.Bytes()))
+		if err != nil || config.Width != 31 || config.Height != 17 {
+			t.Fatalf("%d colors: DecodeXpmConfig returned %dx%d (%v).",
+				ncolors, config.Width, config.Height, err)
+		}
+	}
+}
+
+// checkPixels fails the test if the pixels of 'img' aren't 'want', a slice
+// of rows. Transparent pixels only need to be transparent.
+func checkPixels(t *testing.T, name string, img image.Image,
+	want [][]color.NRGBA) {
+
+	t.Helper()
+
+	r := img.Bounds()
+	if r.Dy() != len(want) || (len(want) > 0 && r.Dx() != len(want[0])) {
+		t.Fatalf("%s: The image is %dx%d.", name, r.Dx(), r.Dy())
+	}
+	for y, row := range want {
+		for x, wc := range row {
+			c := color.NRGBAModel.Convert(
+				img.At(r.Min.X+x, r.Min.Y+y)).(color.NRGBA)
+			if c != wc && !(c.A == 0 && wc.A == 0) {
+				t.Fatalf("%s: The pixel at (%d, %d) is %v, but %v was "+
+					"expected.", name, x, y, c, wc)
+			}
+		}
+	}
+}
@@ -3,6 +3,7 @@ package xgraphics
 import (
 	"fmt"
 	"image"
+	"sync"
 
 	"github.com/BurntSushi/xgb/xproto"
 
@@ -52,6 +53,8 @@ func (im *Image) XSurfaceSet(wid xproto.Window) error {
 // do any drawing.) You only need to call this if you're using XDraw/XExpPaint.
 // If you're using XSurfaceSet/XDraw/XPaint, then CreatePixmap is called for
 // you automatically.
+// If the image is an ARGB image, the pixmap has a depth of 32 bits.
+// Otherwise, it has the depth of the root window.
 func (im *Image) CreatePixmap() error {
 	// Generate the pixmap id.
 	pid, err := xproto.NewPixmapId(im.X.Conn())
@@ -59,8 +62,9 @@ func (im *Image) CreatePixmap() error {
 		return err
 	}
 
-	// Now actually create the pixmap.
-	err = xproto.CreatePixmapChecked(im.X.Conn(), im.X.Screen().RootDepth,
+	// Now actually create the pixmap. It must have the same depth as the
+	// windows it's painted to.
+	err = xproto.CreatePixmapChecked(im.X.Conn(), im.depth(),
 		pid, xproto.Drawable(im.X.RootWin()),
 		uint16(im.Bounds().Dx()), uint16(im.Bounds().Dy())).Check()
 	if err != nil {
@@ -72,6 +76,149 @@ func (im *Image) CreatePixmap() error {
 	return nil
 }
 
+// depth returns the depth of the pixmap created by CreatePixmap for this
+// image.
+func (im *Image) depth() byte {
+	if im.ARGB {
+		return 32
+	}
+	return im.X.Screen().RootDepth
+}
+
+// gcKey identifies a graphics context in the gcs cache.
+type gcKey struct {
+	X     *xgbutil.XUtil
+	depth byte
+}
+
+// gcs caches the graphics contexts used to draw to pixmaps that don't have
+// the depth of the root window, like the pixmaps of ARGB images.
+var (
+	gcs    = make(map[gcKey]xproto.Gcontext)
+	gcsLck = &sync.Mutex{}
+)
+
+// gc returns a graphics context that can be used to draw to the image's
+// pixmap, and to copy it to windows with the same depth. The GC of the XUtil
+// has the depth of the root window, and PutImage and CopyArea fail with a
+// BadMatch error when it's used with drawables of any other depth. So a GC
+// is created for each other depth the first time it's needed, on the image's
+// pixmap. (Which must exist.)
+func (im *Image) gc() (xproto.Gcontext, error) {
+	depth := im.depth()
+	if depth == im.X.Screen().RootDepth {
+		return im.X.GC(), nil
+	}
+
+	gcsLck.Lock()
+	defer gcsLck.Unlock()
+
+	key := gcKey{im.X, depth}
+	if gc, ok := gcs[key]; ok {
+		return gc, nil
+	}
+
+	gc, err := xproto.NewGcontextId(im.X.Conn())
+	if err != nil {
+		return 0, err
+	}
+	err = xproto.CreateGCChecked(im.X.Conn(), gc, xproto.Drawable(im.Pixmap),
+		0, nil).Check()
+	if err != nil {
+		return 0, err
+	}
+	gcs[key] = gc
+	return gc, nil
+}
+
+// CreateBitmap creates a bitmap (a pixmap with a depth of 1) the size of the
+// image, where the pixels for which 'set' returns true are set. Bitmaps are
+// used as masks, like for icons and cursors. The image's own pixmap isn't
+// touched.
+// The caller is responsible for freeing the bitmap with FreePixmap.
+func (im *Image) CreateBitmap(set func(c BGRA) bool) (xproto.Pixmap, error) {
+	X := im.X
+	width, height := im.Rect.Dx(), im.Rect.Dy()
+
+	format := GetFormat(X, 1)
+	if format == nil || format.BitsPerPixel != 1 {
+		return 0, fmt.Errorf("The X server doesn't support bitmaps with " +
+			"one bit per pixel.")
+	}
+
+	pid, err := xproto.NewPixmapId(X.Conn())
+	if err != nil {
+		return 0, err
+	}
+	err = xproto.CreatePixmapChecked(X.Conn(), 1, pid,
+		xproto.Drawable(X.RootWin()), uint16(width), uint16(height)).Check()
+	if err != nil {
+		return 0, err
+	}
+
+	// The GC of the XUtil has the depth of the root window, so a GC is needed
+	// just for drawing bitmaps.
+	gc, err := xproto.NewGcontextId(X.Conn())
+	if err != nil {
+		FreePixmap(X, pid)
+		return 0, err
+	}
+	err = xproto.CreateGCChecked(X.Conn(), gc, xproto.Drawable(pid),
+		0, nil).Check()
+	if err != nil {
+		FreePixmap(X, pid)
+		return 0, err
+	}
+	defer xproto.FreeGC(X.Conn(), gc)
+
+	// This is the inverse of reading bitmaps in readDrawableData.
+	pad := int(X.Setup().BitmapFormatScanlinePad)
+	paddedWidth := width
+	if width%pad != 0 {
+		paddedWidth = width + pad - (width % pad)
+	}
+	unit := int(X.Setup().BitmapFormatScanlineUnit) / 8
+	msbByte := X.Setup().ImageByteOrder == xproto.ImageOrderMSBFirst
+	msbBit := X.Setup().BitmapFormatBitOrder == xproto.ImageOrderMSBFirst
+
+	stride := paddedWidth / 8
+	data := make([]byte, stride*height)
+	for y := 0; y < height; y++ {
+		row := im.Pix[im.PixOffset(im.Rect.Min.X, im.Rect.Min.Y+y):]
+		for x := 0; x < width; x++ {
+			c := BGRA{B: row[4*x], G: row[4*x+1], R: row[4*x+2], A: row[4*x+3]}
+			if !set(c) {
+				continue
+			}
+			j, bit := x/8, uint(x%8)
+			if msbByte && unit > 1 {
+				j = j - j%unit + unit - 1 - j%unit
+			}
+			if msbBit {
+				bit = 7 - bit
+			}
+			data[y*stride+j] |= 1 << bit
+		}
+	}
+
+	// Like in xdraw, the data may need to be split up into multiple requests.
+	rowsPer := (xgbutil.MaxReqSize - 28) / stride
+	for y := 0; y < height; y += rowsPer {
+		rows := rowsPer
+		if y+rows > height {
+			rows = height - y
+		}
+		err = xproto.PutImageChecked(X.Conn(), xproto.ImageFormatZPixmap,
+			xproto.Drawable(pid), gc, uint16(width), uint16(rows),
+			0, int16(y), 0, 1, data[y*stride:(y+rows)*stride]).Check()
+		if err != nil {
+			FreePixmap(X, pid)
+			return 0, err
+		}
+	}
+	return pid, nil
+}
+
 // XPaint will write the contents of the pixmap to a window.
 // Note that painting will do nothing if XDraw hasn't been called.
 // XPaint is what switches the buffer (drawn to using XDraw) into the window
@@ -96,8 +243,13 @@ func (im *Image) XExpPaint(wid xproto.Window, x, y int) {
 	if im.Pixmap == 0 {
 		return
 	}
+	gc, err := im.gc()
+	if err != nil {
+		xgbutil.Logger.Printf("Could not paint image: %s", err)
+		return
+	}
 	xproto.CopyArea(im.X.Conn(),
-		xproto.Drawable(im.Pixmap), xproto.Drawable(wid), im.X.GC(),
+		xproto.Drawable(im.Pixmap), xproto.Drawable(wid), gc,
 		int16(im.Rect.Min.X), int16(im.Rect.Min.Y),
 		int16(x), int16(y),
 		uint16(im.Rect.Dx()), uint16(im.Rect.Dy()))
@@ -130,8 +282,16 @@ func (im *Image) XPaintRects(wid xproto.Window, rects ...image.Rectangle) {
 //
 // If you're using sub-images to update a particular region of the image, XDraw
 // is where you'll see the performance benefit (not XPaint).
+//
+// When the X server supports the MIT-SHM extension and is running on the same
+// machine, large images are sent through shared memory instead of PutImage
+// requests. This is done automatically. (See ShmAvailable.)
 func (im *Image) XDraw() {
-	im.xdraw(false)
+	// Since PutImage requests are unchecked, the only errors possible here
+	// come from an X server configuration we don't support.
+	if err := im.xdraw(false); err != nil {
+		xgbutil.Logger.Printf("Could not draw image: %s", err)
+	}
 }
 
 // XDrawChecked is the same as XDraw, but issues PutImageChecked requests
@@ -142,19 +302,40 @@ func (im *Image) XDrawChecked() error {
 }
 
 func (im *Image) xdraw(checked bool) error {
+	// Figure out what the image data should look like to the X server.
+	// See format.go.
+	format, err := im.format()
+	if err != nil {
+		return err
+	}
+
+	gc, err := im.gc()
+	if err != nil {
+		return err
+	}
+
+	// Large images are sent through shared memory when possible.
+	// See shm.go.
+	if sent, err := im.xdrawShm(format, gc, checked); sent {
+		if err == nil {
+			im.drawn()
+		}
+		return err
+	}
+
 	width, height := im.Rect.Dx(), im.Rect.Dy()
+	stride := format.stride(width)
 
-	// Put the raw image data into its own slice.
-	// If this isn't a sub-image, then skip because it isn't necessary.
+	// Put the raw image data into its own slice, converting it to the X
+	// server's pixel format if necessary.
+	// If this isn't a sub-image and no conversion is necessary, then skip
+	// because it isn't necessary.
 	var data []uint8
-	if !im.Subimg {
+	if !im.Subimg && format.native() {
 		data = im.Pix
 	} else {
-		data = make([]uint8, width*height*4)
-		for y := im.Rect.Min.Y; y < im.Rect.Max.Y; y++ {
-			i := (y - im.Rect.Min.Y) * width * 4
-			copy(data[i:i+4*width], im.Pix[im.PixOffset(im.Rect.Min.X, y):])
-		}
+		data = make([]uint8, stride*height)
+		format.encode(data, stride, im)
 	}
 
 	// X's max request size (by default) is (2^16) * 4 = 262144 bytes, which
@@ -163,8 +344,8 @@ func (im *Image) xdraw(checked bool) error {
 	// rows of the image we'll send in each request. If a single row of an
 	// image exceeds the max request length, we're in trouble.  N.B. The
 	// constant 28 comes from the fixed size part of a PutImage request.
-	rowsPer := (xgbutil.MaxReqSize - 28) / (width * 4)
-	bytesPer := rowsPer * width * 4
+	rowsPer := (xgbutil.MaxReqSize - 28) / stride
+	bytesPer := rowsPer * stride
 
 	// The start x position of what we're sending. Doesn't change.
 	xpos := im.Rect.Min.X
@@ -190,26 +371,27 @@ func (im *Image) xdraw(checked bool) error {
 		}
 
 		toSend = data[start:end]
-		heightPer = len(toSend) / 4 / width
+		heightPer = len(toSend) / stride
 
 		if checked {
 			err := xproto.PutImageChecked(
 				im.X.Conn(), xproto.ImageFormatZPixmap,
-				xproto.Drawable(im.Pixmap), im.X.GC(),
+				xproto.Drawable(im.Pixmap), gc,
 				uint16(width), uint16(heightPer), int16(xpos), int16(ypos),
-				0, 24, toSend).Check()
+				0, format.depth, toSend).Check()
 			if err != nil {
 				return err
 			}
 		} else {
 			xproto.PutImage(im.X.Conn(), xproto.ImageFormatZPixmap,
-				xproto.Drawable(im.Pixmap), im.X.GC(),
+				xproto.Drawable(im.Pixmap), gc,
 				uint16(width), uint16(heightPer), int16(xpos), int16(ypos),
-				0, 24, toSend)
+				0, format.depth, toSend)
 		}
 		start = end
 		ypos += rowsPer
 	}
+	im.drawn()
 
 	return nil
 }
@@ -0,0 +1,283 @@
+package xinput
+
+/*
+xinput/callback.go defines a callback type for each XInput 2 event, in the
+same style as xevent/callback.go.
+
+Callbacks for device events (key, button, motion and touch events), crossing
+and focus events and touch ownership events are attached to the event window.
+Callbacks for raw, hierarchy, property and device changed events should be
+attached to xevent.NoWindow. Init must be called before any callbacks are
+connected.
+*/
+
+import (
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+)
+
+type KeyPressFun func(xu *xgbutil.XUtil, event KeyPressEvent)
+
+func (callback KeyPressFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), KeyPress, win, callback)
+}
+
+func (callback KeyPressFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(KeyPressEvent))
+}
+
+type KeyReleaseFun func(xu *xgbutil.XUtil, event KeyReleaseEvent)
+
+func (callback KeyReleaseFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), KeyRelease, win, callback)
+}
+
+func (callback KeyReleaseFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(KeyReleaseEvent))
+}
+
+type ButtonPressFun func(xu *xgbutil.XUtil, event ButtonPressEvent)
+
+func (callback ButtonPressFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), ButtonPress, win, callback)
+}
+
+func (callback ButtonPressFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(ButtonPressEvent))
+}
+
+type ButtonReleaseFun func(xu *xgbutil.XUtil, event ButtonReleaseEvent)
+
+func (callback ButtonReleaseFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), ButtonRelease, win, callback)
+}
+
+func (callback ButtonReleaseFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(ButtonReleaseEvent))
+}
+
+type MotionFun func(xu *xgbutil.XUtil, event MotionEvent)
+
+func (callback MotionFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), Motion, win, callback)
+}
+
+func (callback MotionFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(MotionEvent))
+}
+
+type TouchBeginFun func(xu *xgbutil.XUtil, event TouchBeginEvent)
+
+func (callback TouchBeginFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), TouchBegin, win, callback)
+}
+
+func (callback TouchBeginFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(TouchBeginEvent))
+}
+
+type TouchUpdateFun func(xu *xgbutil.XUtil, event TouchUpdateEvent)
+
+func (callback TouchUpdateFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), TouchUpdate, win, callback)
+}
+
+func (callback TouchUpdateFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(TouchUpdateEvent))
+}
+
+type TouchEndFun func(xu *xgbutil.XUtil, event TouchEndEvent)
+
+func (callback TouchEndFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), TouchEnd, win, callback)
+}
+
+func (callback TouchEndFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(TouchEndEvent))
+}
+
+type RawKeyPressFun func(xu *xgbutil.XUtil, event RawKeyPressEvent)
+
+func (callback RawKeyPressFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), RawKeyPress, win, callback)
+}
+
+func (callback RawKeyPressFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(RawKeyPressEvent))
+}
+
+type RawKeyReleaseFun func(xu *xgbutil.XUtil, event RawKeyReleaseEvent)
+
+func (callback RawKeyReleaseFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), RawKeyRelease, win, callback)
+}
+
+func (callback RawKeyReleaseFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(RawKeyReleaseEvent))
+}
+
+type RawButtonPressFun func(xu *xgbutil.XUtil, event RawButtonPressEvent)
+
+func (callback RawButtonPressFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), RawButtonPress, win, callback)
+}
+
+func (callback RawButtonPressFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(RawButtonPressEvent))
+}
+
+type RawButtonReleaseFun func(xu *xgbutil.XUtil, event RawButtonReleaseEvent)
+
+func (callback RawButtonReleaseFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), RawButtonRelease, win, callback)
+}
+
+func (callback RawButtonReleaseFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(RawButtonReleaseEvent))
+}
+
+type RawMotionFun func(xu *xgbutil.XUtil, event RawMotionEvent)
+
+func (callback RawMotionFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), RawMotion, win, callback)
+}
+
+func (callback RawMotionFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(RawMotionEvent))
+}
+
+type RawTouchBeginFun func(xu *xgbutil.XUtil, event RawTouchBeginEvent)
+
+func (callback RawTouchBeginFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), RawTouchBegin, win, callback)
+}
+
+func (callback RawTouchBeginFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(RawTouchBeginEvent))
+}
+
+type RawTouchUpdateFun func(xu *xgbutil.XUtil, event RawTouchUpdateEvent)
+
+func (callback RawTouchUpdateFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), RawTouchUpdate, win, callback)
+}
+
+func (callback RawTouchUpdateFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(RawTouchUpdateEvent))
+}
+
+type RawTouchEndFun func(xu *xgbutil.XUtil, event RawTouchEndEvent)
+
+func (callback RawTouchEndFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), RawTouchEnd, win, callback)
+}
+
+func (callback RawTouchEndFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(RawTouchEndEvent))
+}
+
+type HierarchyChangedFun func(xu *xgbutil.XUtil, event HierarchyChangedEvent)
+
+func (callback HierarchyChangedFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), HierarchyChanged, win, callback)
+}
+
+func (callback HierarchyChangedFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(HierarchyChangedEvent))
+}
+
+type PropertyFun func(xu *xgbutil.XUtil, event PropertyEvent)
+
+func (callback PropertyFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), Property, win, callback)
+}
+
+func (callback PropertyFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(PropertyEvent))
+}
+
+type EnterFun func(xu *xgbutil.XUtil, event EnterEvent)
+
+func (callback EnterFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), Enter, win, callback)
+}
+
+func (callback EnterFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(EnterEvent))
+}
+
+type LeaveFun func(xu *xgbutil.XUtil, event LeaveEvent)
+
+func (callback LeaveFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), Leave, win, callback)
+}
+
+func (callback LeaveFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(LeaveEvent))
+}
+
+type FocusInFun func(xu *xgbutil.XUtil, event FocusInEvent)
+
+func (callback FocusInFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), FocusIn, win, callback)
+}
+
+func (callback FocusInFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(FocusInEvent))
+}
+
+type FocusOutFun func(xu *xgbutil.XUtil, event FocusOutEvent)
+
+func (callback FocusOutFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), FocusOut, win, callback)
+}
+
+func (callback FocusOutFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(FocusOutEvent))
+}
+
+type TouchOwnershipFun func(xu *xgbutil.XUtil, event TouchOwnershipEvent)
+
+func (callback TouchOwnershipFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), TouchOwnership, win, callback)
+}
+
+func (callback TouchOwnershipFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(TouchOwnershipEvent))
+}
+
+type DeviceChangedFun func(xu *xgbutil.XUtil, event DeviceChangedEvent)
+
+func (callback DeviceChangedFun) Connect(xu *xgbutil.XUtil,
+	win xproto.Window) {
+	xevent.ConnectGeneric(xu, opcode(xu), DeviceChanged, win, callback)
+}
+
+func (callback DeviceChangedFun) Run(xu *xgbutil.XUtil, event interface{}) {
+	callback(xu, event.(DeviceChangedEvent))
+}
@@ -0,0 +1,313 @@
+package xinput
+
+/*
+xinput/device.go contains functions for listing input devices and the classes
+(keys, buttons, valuators, scrolling and touch) they support.
+*/
+
+import (
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+)
+
+// How a device is used. Master devices correspond to the on-screen pointer
+// and keyboard focus, while slave devices are physical devices attached to
+// a master. Floating slaves are physical devices that aren't attached to
+// any master device.
+const (
+	MasterPointer  = 1
+	MasterKeyboard = 2
+	SlavePointer   = 3
+	SlaveKeyboard  = 4
+	FloatingSlave  = 5
+)
+
+// Types of device classes.
+const (
+	KeyClass      = 0
+	ButtonClass   = 1
+	ValuatorClass = 2
+	ScrollClass   = 3
+	TouchClass    = 8
+)
+
+// Types of scroll classes.
+const (
+	ScrollTypeVertical   = 1
+	ScrollTypeHorizontal = 2
+)
+
+// Flags of scroll classes. ScrollFlagNoEmulation means that the server does
+// not emulate button 4-7 presses for the scroll axis, and
+// ScrollFlagPreferred marks the preferred axis when a device has more than
+// one scroll axis in the same direction.
+const (
+	ScrollFlagNoEmulation = 1 << 0
+	ScrollFlagPreferred   = 1 << 1
+)
+
+// Device describes a single input device. Attachment is the master device
+// of a slave device, or the paired master device of a master device.
+// The remaining fields describe the classes of the device. (Not all devices
+// have every class.)
+type Device struct {
+	Id         int
+	Name       string
+	Use        int
+	Attachment int
+	Enabled    bool
+
+	// Keycodes contains the keycodes the device can send.
+	Keycodes []uint32
+
+	// ButtonLabels contains an atom for each button describing it. The atom
+	// may be 0 if the button isn't labeled.
+	ButtonLabels []xproto.Atom
+
+	Valuators []Valuator
+	Scrolls   []Scroll
+
+	// MaxTouches is the maximum number of simultaneous touches supported by
+	// the device. It is 0 if the device doesn't support touch. TouchMode is
+	// either TouchModeDirect (touchscreens) or TouchModeDependent
+	// (touchpads).
+	MaxTouches int
+	TouchMode  int
+}
+
+// Touch modes of touch devices.
+const (
+	TouchModeDirect    = 1
+	TouchModeDependent = 2
+)
+
+// Valuator describes an axis of a device.
+// Label is an atom describing the axis (like "Rel X" or "Abs Pressure").
+// Mode is 0 for relative axes and 1 for absolute axes.
+type Valuator struct {
+	Number     int
+	Label      xproto.Atom
+	Min, Max   float64
+	Value      float64
+	Resolution uint32
+	Mode       int
+}
+
+// Scroll describes a valuator that is used for smooth scrolling.
+// Number is the number of the valuator. Increment is the change in the
+// valuator's value that corresponds to one scroll "click".
+type Scroll struct {
+	Number    int
+	Type      int
+	Flags     uint32
+	Increment float64
+}
+
+// IsPointer returns true if the device is a master or slave pointer.
+func (d *Device) IsPointer() bool {
+	return d.Use == MasterPointer || d.Use == SlavePointer
+}
+
+// IsKeyboard returns true if the device is a master or slave keyboard.
+func (d *Device) IsKeyboard() bool {
+	return d.Use == MasterKeyboard || d.Use == SlaveKeyboard
+}
+
+// IsMaster returns true if the device is a master pointer or keyboard.
+func (d *Device) IsMaster() bool {
+	return d.Use == MasterPointer || d.Use == MasterKeyboard
+}
+
+// QueryDevice returns information about the device with the id given.
+// If 'deviceid' is AllDevices or AllMasterDevices, information about every
+// device (or every master device) is returned.
+func QueryDevice(xu *xgbutil.XUtil, deviceid int) ([]*Device, error) {
+	body := make([]byte, 4)
+	xgb.Put16(body[0:], uint16(deviceid))
+
+	buf, err := request(xu, xiQueryDevice, body, true)
+	if err != nil {
+		return nil, err
+	}
+
+	num := int(xgb.Get16(buf[8:]))
+	devices := make([]*Device, num)
+	b := 32
+	for i := 0; i < num; i++ {
+		devices[i], b = readDevice(buf, b)
+	}
+	return devices, nil
+}
+
+// Devices returns every input device known to the X server.
+func Devices(xu *xgbutil.XUtil) ([]*Device, error) {
+	return QueryDevice(xu, AllDevices)
+}
+
+// Pointers returns every master and slave pointer device.
+func Pointers(xu *xgbutil.XUtil) ([]*Device, error) {
+	devices, err := Devices(xu)
+	if err != nil {
+		return nil, err
+	}
+
+	pointers := make([]*Device, 0, len(devices))
+	for _, d := range devices {
+		if d.IsPointer() {
+			pointers = append(pointers, d)
+		}
+	}
+	return pointers, nil
+}
+
+// Keyboards returns every master and slave keyboard device.
+func Keyboards(xu *xgbutil.XUtil) ([]*Device, error) {
+	devices, err := Devices(xu)
+	if err != nil {
+		return nil, err
+	}
+
+	keyboards := make([]*Device, 0, len(devices))
+	for _, d := range devices {
+		if d.IsKeyboard() {
+			keyboards = append(keyboards, d)
+		}
+	}
+	return keyboards, nil
+}
+
+// readDevice reads a single device (and its classes) starting at byte 'b'
+// of an XIQueryDevice reply. It returns the device and the position of the
+// next device.
+func readDevice(buf []byte, b int) (*Device, int) {
+	d := &Device{
+		Id:         int(xgb.Get16(buf[b:])),
+		Use:        int(xgb.Get16(buf[b+2:])),
+		Attachment: int(xgb.Get16(buf[b+4:])),
+		Enabled:    buf[b+10] == 1,
+	}
+	numClasses := int(xgb.Get16(buf[b+6:]))
+	nameLen := int(xgb.Get16(buf[b+8:]))
+	b += 12
+
+	d.Name = string(buf[b : b+nameLen])
+	b += xgb.Pad(nameLen)
+
+	for i := 0; i < numClasses; i++ {
+		typ := int(xgb.Get16(buf[b:]))
+		size := 4 * int(xgb.Get16(buf[b+2:]))
+		readClass(d, typ, buf[b:b+size])
+		b += size
+	}
+	return d, b
+}
+
+// readClass reads a single device class into 'd'. Unknown classes are
+// ignored.
+func readClass(d *Device, typ int, buf []byte) {
+	switch typ {
+	case KeyClass:
+		num := int(xgb.Get16(buf[6:]))
+		d.Keycodes = make([]uint32, num)
+		for i := range d.Keycodes {
+			d.Keycodes[i] = xgb.Get32(buf[8+4*i:])
+		}
+	case ButtonClass:
+		num := int(xgb.Get16(buf[6:]))
+		b := 8 + 4*((num+31)/32) // skip the button state mask
+		d.ButtonLabels = make([]xproto.Atom, num)
+		for i := range d.ButtonLabels {
+			d.ButtonLabels[i] = xproto.Atom(xgb.Get32(buf[b+4*i:]))
+		}
+	case ValuatorClass:
+		d.Valuators = append(d.Valuators, Valuator{
+			Number:     int(xgb.Get16(buf[6:])),
+			Label:      xproto.Atom(xgb.Get32(buf[8:])),
+			Min:        fp3232(buf[12:]),
+			Max:        fp3232(buf[20:]),
+			Value:      fp3232(buf[28:]),
+			Resolution: xgb.Get32(buf[36:]),
+			Mode:       int(buf[40]),
+		})
+	case ScrollClass:
+		d.Scrolls = append(d.Scrolls, Scroll{
+			Number:    int(xgb.Get16(buf[6:])),
+			Type:      int(xgb.Get16(buf[8:])),
+			Flags:     xgb.Get32(buf[12:]),
+			Increment: fp3232(buf[16:]),
+		})
+	case TouchClass:
+		d.TouchMode = int(buf[6])
+		d.MaxTouches = int(buf[7])
+	}
+}
+
+// ScrollTracker turns the valuators of smooth scrolling devices into scroll
+// deltas. The valuators of a scroll axis report an absolute position, so
+// the previous position must be remembered to compute how far the device
+// scrolled.
+// A ScrollTracker should be refreshed with Reset whenever the device
+// hierarchy changes or when the pointer enters the window (since the
+// position may have changed while events weren't reported).
+type ScrollTracker struct {
+	scrolls map[int][]Scroll
+	last    map[int]map[int]float64
+}
+
+// NewScrollTracker creates a ScrollTracker for the scroll classes of the
+// devices given. Usually, the devices come from Devices.
+func NewScrollTracker(devices []*Device) *ScrollTracker {
+	st := &ScrollTracker{}
+	st.Reset(devices)
+	return st
+}
+
+// Reset forgets all previous scroll positions and uses the scroll classes
+// of the devices given.
+func (st *ScrollTracker) Reset(devices []*Device) {
+	st.scrolls = make(map[int][]Scroll, len(devices))
+	st.last = make(map[int]map[int]float64, len(devices))
+	for _, d := range devices {
+		if len(d.Scrolls) > 0 {
+			st.scrolls[d.Id] = d.Scrolls
+			st.last[d.Id] = make(map[int]float64, len(d.Scrolls))
+		}
+	}
+}
+
+// Delta returns how far the device that generated the event given scrolled
+// horizontally and vertically, in units of scroll "clicks". (Positive values
+// are down and to the right.) Fractional values are common with touchpads.
+// The first event for each scroll axis only records the position and
+// reports no movement.
+func (st *ScrollTracker) Delta(ev *DeviceEvent) (dx, dy float64) {
+	scrolls, ok := st.scrolls[ev.SourceId]
+	if !ok {
+		return 0, 0
+	}
+
+	last := st.last[ev.SourceId]
+	for _, scroll := range scrolls {
+		val, ok := ev.Valuators[scroll.Number]
+		if !ok {
+			continue
+		}
+
+		prev, seen := last[scroll.Number]
+		last[scroll.Number] = val
+		if !seen || scroll.Increment == 0 {
+			continue
+		}
+
+		delta := (val - prev) / scroll.Increment
+		switch scroll.Type {
+		case ScrollTypeHorizontal:
+			dx += delta
+		case ScrollTypeVertical:
+			dy += delta
+		}
+	}
+	return dx, dy
+}
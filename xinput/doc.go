@@ -0,0 +1,96 @@
+/*
+Package xinput provides support for the events of the X Input extension,
+version 2 (XInput 2). Namely, it can select XInput 2 events on windows and
+delivers them to callback functions in xevent's main event loop.
+
+XInput 2 events carry information that core events do not have: which device
+generated an event, sub-pixel pointer positions, valuator (axis) values for
+smooth scrolling and tablets, touch events and raw (unaccelerated) device
+events that are reported regardless of which window has focus or a grab.
+
+The xinput package can also list input devices and their capabilities, report
+when devices are added or removed (hotplugging) and read or change device
+properties. (Like the acceleration settings of a libinput pointer.)
+
+XGB does not have bindings for the X Input extension, so the few requests
+needed are encoded by the xinput package itself.
+
+Usage
+
+Before using anything in this package, Init must be called. It initializes
+the extension, announces XInput 2 support to the X server and registers a
+decoder for XInput 2 events with the xevent package.
+
+XInput 2 events are not selected with xwindow.Window.Listen. Instead, use
+SelectEvents with the window, the device (or AllDevices/AllMasterDevices) and
+the event types you're interested in. Callbacks are attached just like
+callbacks for core events in the xevent package, except that callback types
+are defined in this package. Callbacks for raw events must be attached to
+xevent.NoWindow, since raw events are not reported relative to a window.
+
+Devices
+
+Devices returns every input device known to the X server, while Pointers and
+Keyboards only return pointer or keyboard devices. Master devices are the
+virtual pointers and keyboards that are seen on the screen, while slave
+devices are physical devices attached to them.
+
+To find out when devices are added or removed, select HierarchyChanged events
+on the root window for AllDevices and connect a HierarchyChangedFun callback
+to xevent.NoWindow. For example, to reapply keyboard settings whenever a
+keyboard is plugged in:
+
+	xinput.HierarchyChangedFun(
+		func(X *xgbutil.XUtil, ev xinput.HierarchyChangedEvent) {
+			for _, info := range ev.Changed(xinput.SlaveAdded) {
+				if info.Use == xinput.SlaveKeyboard {
+					reapplyKeyboardSettings(info.DeviceId)
+				}
+			}
+		}).Connect(X, xevent.NoWindow)
+	err := xinput.SelectEvents(X, X.RootWin(), xinput.AllDevices,
+		xinput.HierarchyChanged)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+HierarchyChanged events are longer than 32 bytes, so (like most XInput 2
+events) they can only be selected on connections made with xgbutil.NewConn or
+xgbutil.NewConnDisplay. (See Caveats.)
+
+Smooth scrolling devices report scrolling as valuators in device events.
+ScrollTracker can be used with the scroll classes of each device to turn
+them into scroll deltas.
+
+A quick example
+
+To print the unaccelerated movement of every pointer device:
+
+	if err := xinput.Init(X); err != nil {
+		log.Fatal(err)
+	}
+	xinput.RawMotionFun(
+		func(X *xgbutil.XUtil, ev xinput.RawMotionEvent) {
+			fmt.Printf("device %d moved by %v\n",
+				ev.SourceId, ev.RawValuators)
+		}).Connect(X, xevent.NoWindow)
+	err := xinput.SelectEvents(X, X.RootWin(), xinput.AllMasterDevices,
+		xinput.RawMotion)
+	if err != nil {
+		log.Fatal(err)
+	}
+	xevent.Main(X)
+
+Complete examples named 'xinput-events' and 'xinput-devices' can be found in
+the examples directory of the xgbutil package.
+
+Caveats
+
+XInput 2 events are sent through the X Generic Event Extension, and are
+usually longer than the 32 bytes of a core event. XGB on its own reads exactly
+32 bytes for every event, which leaves the rest on the socket and breaks the
+connection. Only connections made with xgbutil.NewConn or
+xgbutil.NewConnDisplay read these events completely, so SelectEvents returns
+an error on connections made with xgbutil.NewConnXgb.
+*/
+package xinput
@@ -0,0 +1,692 @@
+package xinput
+
+/*
+xinput/events.go contains the XInput 2 event types and the decoder that turns
+generic events read by xevent into them.
+*/
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+)
+
+// XInput 2 event types. These are used with SelectEvents and EventMask.
+const (
+	DeviceChanged    = 1
+	KeyPress         = 2
+	KeyRelease       = 3
+	ButtonPress      = 4
+	ButtonRelease    = 5
+	Motion           = 6
+	Enter            = 7
+	Leave            = 8
+	FocusIn          = 9
+	FocusOut         = 10
+	HierarchyChanged = 11
+	Property         = 12
+	RawKeyPress      = 13
+	RawKeyRelease    = 14
+	RawButtonPress   = 15
+	RawButtonRelease = 16
+	RawMotion        = 17
+	TouchBegin       = 18
+	TouchUpdate      = 19
+	TouchEnd         = 20
+	TouchOwnership   = 21
+	RawTouchBegin    = 22
+	RawTouchUpdate   = 23
+	RawTouchEnd      = 24
+)
+
+// Flags that may be set in the Flags field of device and raw events.
+// KeyRepeat only applies to key events, PointerEmulated only applies to
+// button and motion events and the Touch* flags only apply to touch events.
+const (
+	KeyRepeat             = 1 << 16
+	PointerEmulated       = 1 << 16
+	TouchPendingEnd       = 1 << 16
+	TouchEmulatingPointer = 1 << 17
+)
+
+// Flags describing what changed in a HierarchyChanged event. They are used
+// in the Flags field of HierarchyChangedEvent and HierarchyInfo.
+const (
+	MasterAdded    = 1 << 0
+	MasterRemoved  = 1 << 1
+	SlaveAdded     = 1 << 2
+	SlaveRemoved   = 1 << 3
+	SlaveAttached  = 1 << 4
+	SlaveDetached  = 1 << 5
+	DeviceEnabled  = 1 << 6
+	DeviceDisabled = 1 << 7
+)
+
+// Why a DeviceChanged event was sent. SlaveSwitch means that a different
+// slave device now sends events through the master device. (So the classes
+// of the master device are now those of the slave device.) DeviceChange means
+// that the classes of the device itself changed.
+const (
+	SlaveSwitch  = 1
+	DeviceChange = 2
+)
+
+// Modes of crossing and focus events that XInput 2 adds to the modes of core
+// events (xproto.NotifyMode*). They are sent when a passive grab activates
+// or deactivates.
+const (
+	NotifyPassiveGrab   = 4
+	NotifyPassiveUngrab = 5
+)
+
+// What happened to a device property in a Property event.
+const (
+	PropertyDeleted  = 0
+	PropertyCreated  = 1
+	PropertyModified = 2
+)
+
+// ModifierInfo contains the state of the modifiers when an event was
+// generated.
+type ModifierInfo struct {
+	Base, Latched, Locked, Effective uint32
+}
+
+// GroupInfo contains the state of the keyboard groups when an event was
+// generated.
+type GroupInfo struct {
+	Base, Latched, Locked, Effective uint8
+}
+
+// DeviceEvent is the representation shared by XInput 2 key, button, motion
+// and touch events.
+// DeviceId is the device that the event is reported for (typically a master
+// device) and SourceId is the physical (slave) device that generated it.
+// Detail is the keycode, button or touch id, depending on the event type.
+// Positions are in sub-pixel precision.
+// Valuators maps axis numbers to their current values. Only axes that have
+// changed (or that are otherwise reported by the server) are present. When a
+// device supports smooth scrolling, its scroll axes show up here. (Use the
+// scroll classes of the device to interpret them.)
+type DeviceEvent struct {
+	Sequence           uint16
+	DeviceId, SourceId int
+	Time               xproto.Timestamp
+	Detail             uint32
+	Root, Event, Child xproto.Window
+	RootX, RootY       float64
+	EventX, EventY     float64
+	Flags              uint32
+	Mods               ModifierInfo
+	Group              GroupInfo
+	Buttons            []uint32
+	Valuators          map[int]float64
+}
+
+// newDeviceEvent decodes a key, button, motion or touch event.
+func newDeviceEvent(buf []byte) *DeviceEvent {
+	ev := &DeviceEvent{
+		Sequence: xgb.Get16(buf[2:]),
+		DeviceId: int(xgb.Get16(buf[10:])),
+		Time:     xproto.Timestamp(xgb.Get32(buf[12:])),
+		Detail:   xgb.Get32(buf[16:]),
+		Root:     xproto.Window(xgb.Get32(buf[20:])),
+		Event:    xproto.Window(xgb.Get32(buf[24:])),
+		Child:    xproto.Window(xgb.Get32(buf[28:])),
+		RootX:    fp1616(xgb.Get32(buf[32:])),
+		RootY:    fp1616(xgb.Get32(buf[36:])),
+		EventX:   fp1616(xgb.Get32(buf[40:])),
+		EventY:   fp1616(xgb.Get32(buf[44:])),
+		SourceId: int(xgb.Get16(buf[52:])),
+		Flags:    xgb.Get32(buf[56:]),
+		Mods: ModifierInfo{
+			Base:      xgb.Get32(buf[60:]),
+			Latched:   xgb.Get32(buf[64:]),
+			Locked:    xgb.Get32(buf[68:]),
+			Effective: xgb.Get32(buf[72:]),
+		},
+		Group: GroupInfo{
+			Base:      buf[76],
+			Latched:   buf[77],
+			Locked:    buf[78],
+			Effective: buf[79],
+		},
+	}
+
+	buttonsLen := int(xgb.Get16(buf[48:]))
+	valuatorsLen := int(xgb.Get16(buf[50:]))
+
+	b := 80
+	ev.Buttons = make([]uint32, buttonsLen)
+	for i := range ev.Buttons {
+		ev.Buttons[i] = xgb.Get32(buf[b:])
+		b += 4
+	}
+	ev.Valuators, _ = readValuators(buf[b:], valuatorsLen)
+	return ev
+}
+
+// ButtonDown returns whether the button given was held down when the event
+// was generated.
+func (ev *DeviceEvent) ButtonDown(button int) bool {
+	if button/32 >= len(ev.Buttons) {
+		return false
+	}
+	return ev.Buttons[button/32]&(1<<uint(button%32)) > 0
+}
+
+func (ev *DeviceEvent) String() string {
+	return fmt.Sprintf("{Sequence: %d, DeviceId: %d, SourceId: %d, "+
+		"Time: %d, Detail: %d, Root: %d, Event: %d, Child: %d, "+
+		"Root: (%f, %f), Event: (%f, %f), Flags: %x, Valuators: %v}",
+		ev.Sequence, ev.DeviceId, ev.SourceId, ev.Time, ev.Detail,
+		ev.Root, ev.Event, ev.Child, ev.RootX, ev.RootY, ev.EventX, ev.EventY,
+		ev.Flags, ev.Valuators)
+}
+
+// RawEvent is the representation shared by XInput 2 raw events. Raw events
+// are reported before any pointer acceleration or transformation is applied,
+// and are only ever reported on the root window. (They are not tied to any
+// window, so callbacks for them should be connected to xevent.NoWindow.)
+// Valuators contains the (possibly transformed) axis values, while
+// RawValuators contains the values as reported by the device.
+type RawEvent struct {
+	Sequence           uint16
+	DeviceId, SourceId int
+	Time               xproto.Timestamp
+	Detail             uint32
+	Flags              uint32
+	Valuators          map[int]float64
+	RawValuators       map[int]float64
+}
+
+// newRawEvent decodes a raw key, button, motion or touch event.
+func newRawEvent(buf []byte) *RawEvent {
+	ev := &RawEvent{
+		Sequence: xgb.Get16(buf[2:]),
+		DeviceId: int(xgb.Get16(buf[10:])),
+		Time:     xproto.Timestamp(xgb.Get32(buf[12:])),
+		Detail:   xgb.Get32(buf[16:]),
+		SourceId: int(xgb.Get16(buf[20:])),
+		Flags:    xgb.Get32(buf[24:]),
+	}
+
+	// The raw values follow the transformed values and use the same mask.
+	var axes []int
+	valuatorsLen := int(xgb.Get16(buf[22:]))
+	ev.Valuators, axes = readValuators(buf[32:], valuatorsLen)
+
+	b := 32 + 4*valuatorsLen + 8*len(axes)
+	ev.RawValuators = make(map[int]float64, len(axes))
+	for _, axis := range axes {
+		ev.RawValuators[axis] = fp3232(buf[b:])
+		b += 8
+	}
+	return ev
+}
+
+func (ev *RawEvent) String() string {
+	return fmt.Sprintf("{Sequence: %d, DeviceId: %d, SourceId: %d, "+
+		"Time: %d, Detail: %d, Flags: %x, Valuators: %v, RawValuators: %v}",
+		ev.Sequence, ev.DeviceId, ev.SourceId, ev.Time, ev.Detail, ev.Flags,
+		ev.Valuators, ev.RawValuators)
+}
+
+// readValuators reads a valuator mask that is 'maskLen' 4 byte units long
+// followed by a 32.32 fixed point value for every bit set in the mask.
+// It returns the values keyed by axis number and the axis numbers in the
+// order they appear.
+func readValuators(buf []byte, maskLen int) (map[int]float64, []int) {
+	axes := make([]int, 0)
+	for axis := 0; axis < maskLen*32; axis++ {
+		if xgb.Get32(buf[4*(axis/32):])&(1<<uint(axis%32)) > 0 {
+			axes = append(axes, axis)
+		}
+	}
+
+	b := 4 * maskLen
+	vals := make(map[int]float64, len(axes))
+	for _, axis := range axes {
+		vals[axis] = fp3232(buf[b:])
+		b += 8
+	}
+	return vals, axes
+}
+
+// HierarchyChangedEvent is sent whenever devices are added, removed,
+// attached, detached, enabled or disabled. (i.e., when a USB keyboard is
+// plugged in.) Flags is the union of the flags of each device in Infos.
+// Infos contains the state of *every* device after the change.
+// Hierarchy events are not reported relative to a window, so callbacks for
+// them should be connected to xevent.NoWindow. (They still need to be
+// selected on a window, usually the root window, with AllDevices.)
+type HierarchyChangedEvent struct {
+	Sequence uint16
+	DeviceId int
+	Time     xproto.Timestamp
+	Flags    uint32
+	Infos    []HierarchyInfo
+}
+
+// HierarchyInfo is the state of a single device in a hierarchy event.
+// Flags describes what changed for this particular device (and is 0 if
+// nothing changed).
+type HierarchyInfo struct {
+	DeviceId   int
+	Attachment int
+	Use        int
+	Enabled    bool
+	Flags      uint32
+}
+
+// newHierarchyChangedEvent decodes a hierarchy event.
+func newHierarchyChangedEvent(buf []byte) HierarchyChangedEvent {
+	ev := HierarchyChangedEvent{
+		Sequence: xgb.Get16(buf[2:]),
+		DeviceId: int(xgb.Get16(buf[10:])),
+		Time:     xproto.Timestamp(xgb.Get32(buf[12:])),
+		Flags:    xgb.Get32(buf[16:]),
+	}
+
+	ev.Infos = make([]HierarchyInfo, int(xgb.Get16(buf[20:])))
+	for i := range ev.Infos {
+		b := 32 + 12*i
+		ev.Infos[i] = HierarchyInfo{
+			DeviceId:   int(xgb.Get16(buf[b:])),
+			Attachment: int(xgb.Get16(buf[b+2:])),
+			Use:        int(buf[b+4]),
+			Enabled:    buf[b+5] == 1,
+			Flags:      xgb.Get32(buf[b+8:]),
+		}
+	}
+	return ev
+}
+
+// Changed returns the devices that had any of the flags given set.
+// For example, to get every device that was just plugged in:
+//
+//	ev.Changed(xinput.SlaveAdded)
+func (ev HierarchyChangedEvent) Changed(flags uint32) []HierarchyInfo {
+	infos := make([]HierarchyInfo, 0)
+	for _, info := range ev.Infos {
+		if info.Flags&flags > 0 {
+			infos = append(infos, info)
+		}
+	}
+	return infos
+}
+
+func (ev HierarchyChangedEvent) String() string {
+	return fmt.Sprintf("HierarchyChanged {Sequence: %d, DeviceId: %d, "+
+		"Time: %d, Flags: %x, Infos: %v}",
+		ev.Sequence, ev.DeviceId, ev.Time, ev.Flags, ev.Infos)
+}
+
+// PropertyEvent is sent whenever a device property is created, modified or
+// deleted. What is one of PropertyDeleted, PropertyCreated or
+// PropertyModified. Like hierarchy events, callbacks for property events
+// should be connected to xevent.NoWindow.
+type PropertyEvent struct {
+	Sequence uint16
+	DeviceId int
+	Time     xproto.Timestamp
+	Property xproto.Atom
+	What     int
+}
+
+// newPropertyEvent decodes a device property event.
+func newPropertyEvent(buf []byte) PropertyEvent {
+	return PropertyEvent{
+		Sequence: xgb.Get16(buf[2:]),
+		DeviceId: int(xgb.Get16(buf[10:])),
+		Time:     xproto.Timestamp(xgb.Get32(buf[12:])),
+		Property: xproto.Atom(xgb.Get32(buf[16:])),
+		What:     int(buf[20]),
+	}
+}
+
+func (ev PropertyEvent) String() string {
+	return fmt.Sprintf("Property {Sequence: %d, DeviceId: %d, Time: %d, "+
+		"Property: %d, What: %d}",
+		ev.Sequence, ev.DeviceId, ev.Time, ev.Property, ev.What)
+}
+
+// CrossingEvent is the representation shared by XInput 2 enter, leave, focus
+// in and focus out events. Mode and Detail have the same values as in core
+// crossing events (see xproto.NotifyMode* and xproto.NotifyDetail*), except
+// that Mode may also be NotifyPassiveGrab or NotifyPassiveUngrab.
+// Buttons is the state of the buttons, like in DeviceEvent.
+type CrossingEvent struct {
+	Sequence           uint16
+	DeviceId, SourceId int
+	Time               xproto.Timestamp
+	Mode, Detail       int
+	Root, Event, Child xproto.Window
+	RootX, RootY       float64
+	EventX, EventY     float64
+	SameScreen, Focus  bool
+	Mods               ModifierInfo
+	Group              GroupInfo
+	Buttons            []uint32
+}
+
+// newCrossingEvent decodes an enter, leave, focus in or focus out event.
+func newCrossingEvent(buf []byte) *CrossingEvent {
+	ev := &CrossingEvent{
+		Sequence:   xgb.Get16(buf[2:]),
+		DeviceId:   int(xgb.Get16(buf[10:])),
+		Time:       xproto.Timestamp(xgb.Get32(buf[12:])),
+		SourceId:   int(xgb.Get16(buf[16:])),
+		Mode:       int(buf[18]),
+		Detail:     int(buf[19]),
+		Root:       xproto.Window(xgb.Get32(buf[20:])),
+		Event:      xproto.Window(xgb.Get32(buf[24:])),
+		Child:      xproto.Window(xgb.Get32(buf[28:])),
+		RootX:      fp1616(xgb.Get32(buf[32:])),
+		RootY:      fp1616(xgb.Get32(buf[36:])),
+		EventX:     fp1616(xgb.Get32(buf[40:])),
+		EventY:     fp1616(xgb.Get32(buf[44:])),
+		SameScreen: buf[48] == 1,
+		Focus:      buf[49] == 1,
+		Mods: ModifierInfo{
+			Base:      xgb.Get32(buf[52:]),
+			Latched:   xgb.Get32(buf[56:]),
+			Locked:    xgb.Get32(buf[60:]),
+			Effective: xgb.Get32(buf[64:]),
+		},
+		Group: GroupInfo{
+			Base:      buf[68],
+			Latched:   buf[69],
+			Locked:    buf[70],
+			Effective: buf[71],
+		},
+	}
+
+	ev.Buttons = make([]uint32, int(xgb.Get16(buf[50:])))
+	for i := range ev.Buttons {
+		ev.Buttons[i] = xgb.Get32(buf[72+4*i:])
+	}
+	return ev
+}
+
+func (ev *CrossingEvent) String() string {
+	return fmt.Sprintf("{Sequence: %d, DeviceId: %d, SourceId: %d, "+
+		"Time: %d, Mode: %d, Detail: %d, Root: %d, Event: %d, Child: %d, "+
+		"Root: (%f, %f), Event: (%f, %f), SameScreen: %v, Focus: %v}",
+		ev.Sequence, ev.DeviceId, ev.SourceId, ev.Time, ev.Mode, ev.Detail,
+		ev.Root, ev.Event, ev.Child, ev.RootX, ev.RootY, ev.EventX, ev.EventY,
+		ev.SameScreen, ev.Focus)
+}
+
+// DeviceChangedEvent is sent when the classes of a device change, or when a
+// different slave device starts sending events through a master device.
+// (See SlaveSwitch and DeviceChange.) Device has the new classes of the
+// device. (Only its Id and its class fields are set.) Like hierarchy events,
+// callbacks for device changed events should be connected to
+// xevent.NoWindow.
+type DeviceChangedEvent struct {
+	Sequence           uint16
+	DeviceId, SourceId int
+	Time               xproto.Timestamp
+	Reason             int
+	Device             *Device
+}
+
+// newDeviceChangedEvent decodes a device changed event.
+func newDeviceChangedEvent(buf []byte) DeviceChangedEvent {
+	ev := DeviceChangedEvent{
+		Sequence: xgb.Get16(buf[2:]),
+		DeviceId: int(xgb.Get16(buf[10:])),
+		Time:     xproto.Timestamp(xgb.Get32(buf[12:])),
+		SourceId: int(xgb.Get16(buf[18:])),
+		Reason:   int(buf[20]),
+	}
+
+	ev.Device = &Device{Id: ev.DeviceId}
+	b := 32
+	for i := 0; i < int(xgb.Get16(buf[16:])); i++ {
+		typ := int(xgb.Get16(buf[b:]))
+		size := 4 * int(xgb.Get16(buf[b+2:]))
+		readClass(ev.Device, typ, buf[b:b+size])
+		b += size
+	}
+	return ev
+}
+
+func (ev DeviceChangedEvent) String() string {
+	return fmt.Sprintf("DeviceChanged {Sequence: %d, DeviceId: %d, "+
+		"SourceId: %d, Time: %d, Reason: %d}",
+		ev.Sequence, ev.DeviceId, ev.SourceId, ev.Time, ev.Reason)
+}
+
+// TouchOwnershipEvent is sent to a client that selected TouchOwnership
+// events when it becomes the owner of a touch sequence, after every client
+// before it (like a window manager with a grab) rejected the touch.
+// TouchId is the touch id of the sequence (the Detail of its touch events).
+type TouchOwnershipEvent struct {
+	Sequence           uint16
+	DeviceId, SourceId int
+	Time               xproto.Timestamp
+	TouchId            uint32
+	Root, Event, Child xproto.Window
+	Flags              uint32
+}
+
+// newTouchOwnershipEvent decodes a touch ownership event.
+func newTouchOwnershipEvent(buf []byte) TouchOwnershipEvent {
+	return TouchOwnershipEvent{
+		Sequence: xgb.Get16(buf[2:]),
+		DeviceId: int(xgb.Get16(buf[10:])),
+		Time:     xproto.Timestamp(xgb.Get32(buf[12:])),
+		TouchId:  xgb.Get32(buf[16:]),
+		Root:     xproto.Window(xgb.Get32(buf[20:])),
+		Event:    xproto.Window(xgb.Get32(buf[24:])),
+		Child:    xproto.Window(xgb.Get32(buf[28:])),
+		SourceId: int(xgb.Get16(buf[32:])),
+		Flags:    xgb.Get32(buf[36:]),
+	}
+}
+
+func (ev TouchOwnershipEvent) String() string {
+	return fmt.Sprintf("TouchOwnership {Sequence: %d, DeviceId: %d, "+
+		"SourceId: %d, Time: %d, TouchId: %d, Root: %d, Event: %d, "+
+		"Child: %d, Flags: %x}",
+		ev.Sequence, ev.DeviceId, ev.SourceId, ev.Time, ev.TouchId,
+		ev.Root, ev.Event, ev.Child, ev.Flags)
+}
+
+type KeyPressEvent struct {
+	*DeviceEvent
+}
+
+type KeyReleaseEvent struct {
+	*DeviceEvent
+}
+
+type ButtonPressEvent struct {
+	*DeviceEvent
+}
+
+type ButtonReleaseEvent struct {
+	*DeviceEvent
+}
+
+type MotionEvent struct {
+	*DeviceEvent
+}
+
+type EnterEvent struct {
+	*CrossingEvent
+}
+
+type LeaveEvent struct {
+	*CrossingEvent
+}
+
+type FocusInEvent struct {
+	*CrossingEvent
+}
+
+type FocusOutEvent struct {
+	*CrossingEvent
+}
+
+type TouchBeginEvent struct {
+	*DeviceEvent
+}
+
+type TouchUpdateEvent struct {
+	*DeviceEvent
+}
+
+type TouchEndEvent struct {
+	*DeviceEvent
+}
+
+type RawKeyPressEvent struct {
+	*RawEvent
+}
+
+type RawKeyReleaseEvent struct {
+	*RawEvent
+}
+
+type RawButtonPressEvent struct {
+	*RawEvent
+}
+
+type RawButtonReleaseEvent struct {
+	*RawEvent
+}
+
+type RawMotionEvent struct {
+	*RawEvent
+}
+
+type RawTouchBeginEvent struct {
+	*RawEvent
+}
+
+type RawTouchUpdateEvent struct {
+	*RawEvent
+}
+
+type RawTouchEndEvent struct {
+	*RawEvent
+}
+
+// decode satisfies xgbutil.GenericDecodeFun. It is registered with xevent
+// in Init, and turns the generic events sent by the XInput extension into
+// the event types defined in this package.
+// Device, crossing, focus and touch ownership events are dispatched to their
+// event window, while raw, hierarchy, property and device changed events are
+// dispatched to xevent.NoWindow.
+func decode(xu *xgbutil.XUtil,
+	ev interface{}) (interface{}, int, xproto.Window) {
+
+	gev := ev.(xevent.GenericEvent)
+	if len(gev.Data) < 32+4*int(gev.Length) {
+		xgbutil.Logger.Printf("ERROR: Only %d bytes of the XInput event "+
+			"%s were read, but %d are required.",
+			len(gev.Data), gev, 32+4*int(gev.Length))
+		return nil, 0, 0
+	}
+
+	evtype := xevent.GenericType(gev.Extension, gev.EvType)
+	switch gev.EvType {
+	case KeyPress:
+		e := KeyPressEvent{newDeviceEvent(gev.Data)}
+		xu.TimeSet(e.Time)
+		return e, evtype, e.Event
+	case KeyRelease:
+		e := KeyReleaseEvent{newDeviceEvent(gev.Data)}
+		xu.TimeSet(e.Time)
+		return e, evtype, e.Event
+	case ButtonPress:
+		e := ButtonPressEvent{newDeviceEvent(gev.Data)}
+		xu.TimeSet(e.Time)
+		return e, evtype, e.Event
+	case ButtonRelease:
+		e := ButtonReleaseEvent{newDeviceEvent(gev.Data)}
+		xu.TimeSet(e.Time)
+		return e, evtype, e.Event
+	case Motion:
+		e := MotionEvent{newDeviceEvent(gev.Data)}
+		xu.TimeSet(e.Time)
+		return e, evtype, e.Event
+	case TouchBegin:
+		e := TouchBeginEvent{newDeviceEvent(gev.Data)}
+		xu.TimeSet(e.Time)
+		return e, evtype, e.Event
+	case TouchUpdate:
+		e := TouchUpdateEvent{newDeviceEvent(gev.Data)}
+		xu.TimeSet(e.Time)
+		return e, evtype, e.Event
+	case TouchEnd:
+		e := TouchEndEvent{newDeviceEvent(gev.Data)}
+		xu.TimeSet(e.Time)
+		return e, evtype, e.Event
+	case Enter:
+		e := EnterEvent{newCrossingEvent(gev.Data)}
+		xu.TimeSet(e.Time)
+		return e, evtype, e.Event
+	case Leave:
+		e := LeaveEvent{newCrossingEvent(gev.Data)}
+		xu.TimeSet(e.Time)
+		return e, evtype, e.Event
+	case FocusIn:
+		e := FocusInEvent{newCrossingEvent(gev.Data)}
+		xu.TimeSet(e.Time)
+		return e, evtype, e.Event
+	case FocusOut:
+		e := FocusOutEvent{newCrossingEvent(gev.Data)}
+		xu.TimeSet(e.Time)
+		return e, evtype, e.Event
+	case TouchOwnership:
+		e := newTouchOwnershipEvent(gev.Data)
+		xu.TimeSet(e.Time)
+		return e, evtype, e.Event
+	case DeviceChanged:
+		e := newDeviceChangedEvent(gev.Data)
+		xu.TimeSet(e.Time)
+		return e, evtype, xevent.NoWindow
+	case HierarchyChanged:
+		e := newHierarchyChangedEvent(gev.Data)
+		xu.TimeSet(e.Time)
+		return e, evtype, xevent.NoWindow
+	case Property:
+		e := newPropertyEvent(gev.Data)
+		xu.TimeSet(e.Time)
+		return e, evtype, xevent.NoWindow
+	case RawKeyPress:
+		return RawKeyPressEvent{newRawEvent(gev.Data)}, evtype, xevent.NoWindow
+	case RawKeyRelease:
+		return RawKeyReleaseEvent{newRawEvent(gev.Data)}, evtype,
+			xevent.NoWindow
+	case RawButtonPress:
+		return RawButtonPressEvent{newRawEvent(gev.Data)}, evtype,
+			xevent.NoWindow
+	case RawButtonRelease:
+		return RawButtonReleaseEvent{newRawEvent(gev.Data)}, evtype,
+			xevent.NoWindow
+	case RawMotion:
+		return RawMotionEvent{newRawEvent(gev.Data)}, evtype, xevent.NoWindow
+	case RawTouchBegin:
+		return RawTouchBeginEvent{newRawEvent(gev.Data)}, evtype,
+			xevent.NoWindow
+	case RawTouchUpdate:
+		return RawTouchUpdateEvent{newRawEvent(gev.Data)}, evtype,
+			xevent.NoWindow
+	case RawTouchEnd:
+		return RawTouchEndEvent{newRawEvent(gev.Data)}, evtype,
+			xevent.NoWindow
+	}
+
+	xgbutil.Logger.Printf("ERROR: UNSUPPORTED XINPUT EVENT TYPE: %d",
+		gev.EvType)
+	return nil, 0, 0
+}
@@ -0,0 +1,197 @@
+package xinput
+
+/*
+xinput/property.go contains functions for reading and writing input device
+properties. Device properties work just like window properties, so property
+replies are returned as *xproto.GetPropertyReply values. This means that the
+PropVal* functions in the xprop package can be used to interpret them.
+(PropValFloats is provided for properties of type FLOAT, which are common
+with input devices.)
+
+For example, to read the pointer acceleration of a libinput device:
+
+	speed, err := xinput.PropValFloats(xinput.GetProperty(X, deviceid,
+		"libinput Accel Speed"))
+*/
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xprop"
+)
+
+// ListProperties returns the names of all properties set on a device.
+func ListProperties(xu *xgbutil.XUtil, deviceid int) ([]string, error) {
+	body := make([]byte, 4)
+	xgb.Put16(body[0:], uint16(deviceid))
+
+	buf, err := request(xu, xiListProps, body, true)
+	if err != nil {
+		return nil, err
+	}
+
+	num := int(xgb.Get16(buf[8:]))
+	names := make([]string, num)
+	for i := 0; i < num; i++ {
+		aid := xproto.Atom(xgb.Get32(buf[32+4*i:]))
+		names[i], err = xprop.AtomName(xu, aid)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+// GetProperty retrieves the property with the name given from a device.
+// An error is returned if the property doesn't exist.
+func GetProperty(xu *xgbutil.XUtil, deviceid int,
+	prop string) (*xproto.GetPropertyReply, error) {
+
+	propAtom, err := xprop.Atm(xu, prop)
+	if err != nil {
+		return nil, err
+	}
+
+	body := make([]byte, 20)
+	xgb.Put16(body[0:], uint16(deviceid))
+	body[2] = 0 // don't delete
+	xgb.Put32(body[4:], uint32(propAtom))
+	xgb.Put32(body[8:], xproto.GetPropertyTypeAny)
+	xgb.Put32(body[12:], 0)         // offset
+	xgb.Put32(body[16:], (1<<32)-1) // length
+
+	buf, err := request(xu, xiGetProperty, body, true)
+	if err != nil {
+		return nil, fmt.Errorf("GetProperty: Error retrieving property '%s' "+
+			"on device %d: %s", prop, deviceid, err)
+	}
+
+	reply := &xproto.GetPropertyReply{
+		Sequence:   xgb.Get16(buf[2:]),
+		Length:     xgb.Get32(buf[4:]),
+		Type:       xproto.Atom(xgb.Get32(buf[8:])),
+		BytesAfter: xgb.Get32(buf[12:]),
+		ValueLen:   xgb.Get32(buf[16:]),
+		Format:     buf[20],
+	}
+	if reply.Format == 0 {
+		return nil, fmt.Errorf("GetProperty: No such property '%s' on "+
+			"device %d.", prop, deviceid)
+	}
+
+	size := int(reply.ValueLen) * (int(reply.Format) / 8)
+	reply.Value = make([]byte, size)
+	copy(reply.Value, buf[32:])
+	return reply, nil
+}
+
+// ChangeProp replaces the value of a property on a device. It works just
+// like xprop.ChangeProp, except 'format' is the number of bits in each item
+// of 'data' and must be one of 8, 16 or 32.
+func ChangeProp(xu *xgbutil.XUtil, deviceid int, format byte, prop string,
+	typ string, data []byte) error {
+
+	propAtom, err := xprop.Atm(xu, prop)
+	if err != nil {
+		return err
+	}
+
+	typAtom, err := xprop.Atm(xu, typ)
+	if err != nil {
+		return err
+	}
+
+	body := make([]byte, 16+len(data))
+	xgb.Put16(body[0:], uint16(deviceid))
+	body[2] = xproto.PropModeReplace
+	body[3] = format
+	xgb.Put32(body[4:], uint32(propAtom))
+	xgb.Put32(body[8:], uint32(typAtom))
+	xgb.Put32(body[12:], uint32(len(data)/(int(format)/8)))
+	copy(body[16:], data)
+
+	_, err = request(xu, xiChangeProp, body, false)
+	return err
+}
+
+// ChangeProp8 is a convenience function for setting a property on a device
+// whose items are 8 bit integers. (Most boolean libinput properties are set
+// like this, with typ "INTEGER".)
+func ChangeProp8(xu *xgbutil.XUtil, deviceid int, prop string, typ string,
+	data ...uint) error {
+
+	buf := make([]byte, len(data))
+	for i, datum := range data {
+		buf[i] = byte(datum)
+	}
+	return ChangeProp(xu, deviceid, 8, prop, typ, buf)
+}
+
+// ChangeProp32 is a convenience function for setting a property on a device
+// whose items are 32 bit integers.
+func ChangeProp32(xu *xgbutil.XUtil, deviceid int, prop string, typ string,
+	data ...uint) error {
+
+	buf := make([]byte, len(data)*4)
+	for i, datum := range data {
+		xgb.Put32(buf[(i*4):], uint32(datum))
+	}
+	return ChangeProp(xu, deviceid, 32, prop, typ, buf)
+}
+
+// ChangePropFloats is a convenience function for setting a property on a
+// device whose items are 32 bit floating point numbers (of type "FLOAT").
+// For example, to set libinput's pointer acceleration:
+//
+//	xinput.ChangePropFloats(X, deviceid, "libinput Accel Speed", -0.5)
+func ChangePropFloats(xu *xgbutil.XUtil, deviceid int, prop string,
+	data ...float64) error {
+
+	buf := make([]byte, len(data)*4)
+	for i, datum := range data {
+		xgb.Put32(buf[(i*4):], math.Float32bits(float32(datum)))
+	}
+	return ChangeProp(xu, deviceid, 32, prop, "FLOAT", buf)
+}
+
+// DeleteProperty removes a property from a device.
+func DeleteProperty(xu *xgbutil.XUtil, deviceid int, prop string) error {
+	propAtom, err := xprop.Atm(xu, prop)
+	if err != nil {
+		return err
+	}
+
+	body := make([]byte, 8)
+	xgb.Put16(body[0:], uint16(deviceid))
+	xgb.Put32(body[4:], uint32(propAtom))
+
+	_, err = request(xu, xiDeleteProp, body, false)
+	return err
+}
+
+// PropValFloats interprets a property reply as a list of 32 bit floating
+// point numbers. (Like xprop's PropVal* functions, it can be composed with
+// GetProperty.)
+func PropValFloats(reply *xproto.GetPropertyReply,
+	err error) ([]float64, error) {
+
+	if err != nil {
+		return nil, err
+	}
+	if reply.Format != 32 {
+		return nil, fmt.Errorf("PropValFloats: Expected format 32 but got %d",
+			reply.Format)
+	}
+
+	floats := make([]float64, reply.ValueLen)
+	for i := range floats {
+		bits := xgb.Get32(reply.Value[4*i:])
+		floats[i] = float64(math.Float32frombits(bits))
+	}
+	return floats, nil
+}
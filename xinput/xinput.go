@@ -0,0 +1,234 @@
+package xinput
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xevent"
+)
+
+// ExtName is the name of the X Input extension as reported by the X server.
+const ExtName = "XInputExtension"
+
+// The version of XInput 2 that xinput asks for. Touch events and smooth
+// scrolling require 2.2.
+const (
+	MajorVersion = 2
+	MinorVersion = 2
+)
+
+// Special device identifiers that can be used when selecting events.
+// AllDevices selects events from every device (including slave devices),
+// while AllMasterDevices only selects events from master devices.
+const (
+	AllDevices       = 0
+	AllMasterDevices = 1
+)
+
+// XInput 2 request numbers (minor opcodes).
+const (
+	xiSelectEvents  = 46
+	xiQueryVersion  = 47
+	xiQueryDevice   = 48
+	xiListProps     = 56
+	xiChangeProp    = 57
+	xiDeleteProp    = 58
+	xiGetProperty   = 59
+	xiGetSelectedEv = 60
+)
+
+// Init initializes the XInput extension and asks the X server for XInput
+// 2.2 support. It must be called before any other function in this package.
+// An error is returned if the extension isn't present, or if the server does
+// not support at least XInput 2.0.
+// Init also registers a decoder with xevent so that XInput 2 events are
+// dispatched to callbacks in the main event loop.
+func Init(xu *xgbutil.XUtil) error {
+	c := xu.Conn()
+	reply, err := xproto.QueryExtension(c, uint16(len(ExtName)),
+		ExtName).Reply()
+	switch {
+	case err != nil:
+		return err
+	case !reply.Present:
+		return fmt.Errorf("xinput.Init: No extension named %s could be "+
+			"found on the server.", ExtName)
+	}
+
+	c.ExtLock.Lock()
+	c.Extensions[ExtName] = reply.MajorOpcode
+	c.ExtLock.Unlock()
+
+	major, minor, err := QueryVersion(xu, MajorVersion, MinorVersion)
+	if err != nil {
+		return err
+	}
+	if major < 2 {
+		return fmt.Errorf("xinput.Init: XInput 2 is required, but the X "+
+			"server only supports version %d.%d.", major, minor)
+	}
+
+	xevent.GenericDecoderSet(xu, reply.MajorOpcode, decode)
+	return nil
+}
+
+// QueryVersion announces the version of XInput 2 supported by the client
+// and returns the version supported by the X server.
+// (XInput 2 is not enabled for a client until it announces itself with this
+// request, which Init does for you.)
+func QueryVersion(xu *xgbutil.XUtil, major, minor int) (int, int, error) {
+	body := make([]byte, 4)
+	xgb.Put16(body[0:], uint16(major))
+	xgb.Put16(body[2:], uint16(minor))
+
+	buf, err := request(xu, xiQueryVersion, body, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(xgb.Get16(buf[8:])), int(xgb.Get16(buf[10:])), nil
+}
+
+// EventMask returns the XInput 2 event mask corresponding to the event types
+// given. (Event types are the constants for each event in this package, like
+// RawMotion or TouchBegin.)
+func EventMask(evtypes ...int) []uint32 {
+	mask := make([]uint32, 1)
+	for _, evtype := range evtypes {
+		for evtype/32 >= len(mask) {
+			mask = append(mask, 0)
+		}
+		mask[evtype/32] |= 1 << uint(evtype%32)
+	}
+	return mask
+}
+
+// SelectEvents tells X to report the XInput 2 events given for the device
+// specified on the window given. The device may be AllDevices,
+// AllMasterDevices or the id of a particular device.
+// Note that XInput 2 event selection is separate from core event selection
+// (i.e., xwindow.Window.Listen), and that selecting an empty list of events
+// stops reporting XInput 2 events for that device on the window.
+//
+// For example, to get raw motion events for all pointers and smooth
+// scrolling and touch events in some window:
+//
+//	xinput.SelectEvents(X, X.RootWin(), xinput.AllMasterDevices,
+//		xinput.RawMotion)
+//	xinput.SelectEvents(X, win, xinput.AllMasterDevices,
+//		xinput.Motion, xinput.TouchBegin, xinput.TouchUpdate,
+//		xinput.TouchEnd)
+//
+// Most XInput 2 events are longer than 32 bytes, and XGB on its own would
+// break the connection when it receives one. So events can only be selected
+// on connections that read them completely (see
+// xgbutil.XUtil.GenericEventsComplete), and an error is returned otherwise.
+func SelectEvents(xu *xgbutil.XUtil, win xproto.Window, deviceid int,
+	evtypes ...int) error {
+
+	if len(evtypes) > 0 && !xu.GenericEventsComplete() {
+		return fmt.Errorf("xinput.SelectEvents: XInput 2 events can only " +
+			"be read by connections made with xgbutil.NewConn or " +
+			"xgbutil.NewConnDisplay.")
+	}
+
+	mask := EventMask(evtypes...)
+	body := make([]byte, 12+4*len(mask))
+	xgb.Put32(body[0:], uint32(win))
+	xgb.Put16(body[4:], 1) // number of masks
+	xgb.Put16(body[8:], uint16(deviceid))
+	xgb.Put16(body[10:], uint16(len(mask)))
+	for i, m := range mask {
+		xgb.Put32(body[12+4*i:], m)
+	}
+
+	_, err := request(xu, xiSelectEvents, body, false)
+	return err
+}
+
+// SelectedEvents returns the XInput 2 event types selected by this client
+// on the window given, keyed by device id.
+func SelectedEvents(xu *xgbutil.XUtil,
+	win xproto.Window) (map[int][]int, error) {
+
+	body := make([]byte, 4)
+	xgb.Put32(body[0:], uint32(win))
+
+	buf, err := request(xu, xiGetSelectedEv, body, true)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[int][]int)
+	num := int(xgb.Get16(buf[8:]))
+	b := 32
+	for i := 0; i < num; i++ {
+		deviceid := int(xgb.Get16(buf[b:]))
+		maskLen := int(xgb.Get16(buf[b+2:]))
+		b += 4
+
+		evtypes := make([]int, 0)
+		for j := 0; j < maskLen*32; j++ {
+			if xgb.Get32(buf[b+4*(j/32):])&(1<<uint(j%32)) > 0 {
+				evtypes = append(evtypes, j)
+			}
+		}
+		selected[deviceid] = evtypes
+		b += 4 * maskLen
+	}
+	return selected, nil
+}
+
+// opcode returns the major opcode of the XInput extension. It is zero if
+// Init hasn't been called.
+func opcode(xu *xgbutil.XUtil) byte {
+	c := xu.Conn()
+	c.ExtLock.RLock()
+	defer c.ExtLock.RUnlock()
+
+	return c.Extensions[ExtName]
+}
+
+// request sends an XInput request with the given minor opcode and body.
+// XGB doesn't have bindings for the XInput extension, so the requests are
+// encoded by hand. 'body' is padded to a multiple of 4 bytes. Every request
+// is checked. If 'reply' is true, the raw bytes of the reply are returned.
+func request(xu *xgbutil.XUtil, minor byte, body []byte,
+	reply bool) ([]byte, error) {
+
+	major := opcode(xu)
+	if major == 0 {
+		return nil, fmt.Errorf("The XInput extension has not been " +
+			"initialized. Please call xinput.Init first.")
+	}
+
+	size := xgb.Pad(4 + len(body))
+	buf := make([]byte, size)
+	buf[0] = major
+	buf[1] = minor
+	xgb.Put16(buf[2:], uint16(size/4))
+	copy(buf[4:], body)
+
+	c := xu.Conn()
+	cookie := c.NewCookie(true, reply)
+	c.NewRequest(buf, cookie)
+	if reply {
+		return cookie.Reply()
+	}
+	return nil, cookie.Check()
+}
+
+// fp1616 converts a 16.16 fixed point number to a float.
+func fp1616(v uint32) float64 {
+	return float64(int32(v)) / 65536.0
+}
+
+// fp3232 converts a 32.32 fixed point number (integral part first) to a
+// float.
+func fp3232(buf []byte) float64 {
+	integral := int32(xgb.Get32(buf))
+	frac := xgb.Get32(buf[4:])
+	return float64(integral) + float64(frac)/(1<<32)
+}
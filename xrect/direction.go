@@ -0,0 +1,135 @@
+package xrect
+
+/*
+xrect/direction.go contains functions for finding rectangles by their
+position relative to other rectangles, like the window to the left of the
+focused window, or the head below the current head.
+*/
+
+// Direction is a direction on the screen, used by Neighbor.
+type Direction int
+
+const (
+	Left Direction = iota
+	Right
+	Up
+	Down
+)
+
+// Neighbor returns the index of the rectangle in 'candidates' that is the
+// best neighbor of 'src' in the direction given, or -1 if there are no
+// rectangles in that direction. A rectangle is in a direction if its center
+// is past the center of 'src' in that direction, and its far edge is past the
+// far edge of 'src'. (So that a rectangle inside of 'src' is never its
+// neighbor.)
+//
+// Rectangles that overlap 'src' in the other axis (like a window to the left
+// whose rows overlap with those of 'src') are always better than those that
+// don't. Of those that do, the closest one is best (measured from the near
+// edge of 'src' to the far edge of the rectangle), and ties are broken by
+// picking the one with the largest overlap. Of those that don't, the one
+// whose center is closest to the center of 'src' is best.
+//
+// This can be used to move the focus between windows with the keyboard, or
+// with a list of heads (like xinerama.Heads) to find the head next to
+// another head.
+func Neighbor(src Rect, candidates []Rect, dir Direction) int {
+	best, bestOverlaps := -1, false
+	bestDist, bestOverlap := 0, 0
+	for i, c := range candidates {
+		if !inDirection(src, c, dir) {
+			continue
+		}
+
+		// Project both rectangles so that 'dir' is always to the right, and
+		// the other axis is vertical.
+		s1, s2, sp1, sp2 := project(src, dir)
+		c1, c2, cp1, cp2 := project(c, dir)
+		overlap := min(sp2, cp2) - max(sp1, cp1)
+
+		var dist int
+		if overlap > 0 {
+			dist = max(c1-s2, 0)
+		} else {
+			dx := (c1 + c2) - (s1 + s2)
+			dy := (cp1 + cp2) - (sp1 + sp2)
+			dist = dx*dx + dy*dy
+		}
+
+		switch {
+		case best == -1:
+		case overlap > 0 && !bestOverlaps:
+		case overlap > 0 && dist == bestDist && overlap > bestOverlap:
+		case (overlap > 0) == bestOverlaps && dist < bestDist:
+		default:
+			continue
+		}
+		best, bestOverlaps = i, overlap > 0
+		bestDist, bestOverlap = dist, overlap
+	}
+	return best
+}
+
+// inDirection returns whether 'c' is in the direction given from 'src'.
+func inDirection(src, c Rect, dir Direction) bool {
+	s1, s2, _, _ := project(src, dir)
+	c1, c2, _, _ := project(c, dir)
+	return c1+c2 > s1+s2 && c2 > s2
+}
+
+// project returns the start and end of a rectangle along the direction given,
+// and the start and end of it along the other axis. The coordinates are
+// negated for Left and Up, so that they always increase in the direction.
+func project(r Rect, dir Direction) (int, int, int, int) {
+	x, y, w, h := r.Pieces()
+	switch dir {
+	case Left:
+		return -(x + w), -x, y, y + h
+	case Up:
+		return -(y + h), -y, x, x + w
+	case Down:
+		return y, y + h, x, x + w
+	}
+	return x, x + w, y, y + h
+}
+
+// ContainingPoint returns the index of the first rectangle in 'haystack' that
+// contains the point (x, y), or -1 if there is none.
+// This is commonly used to find the head that the pointer is on.
+func ContainingPoint(x, y int, haystack []Rect) int {
+	for i, r := range haystack {
+		rx, ry, rw, rh := r.Pieces()
+		if x >= rx && x < rx+rw && y >= ry && y < ry+rh {
+			return i
+		}
+	}
+	return -1
+}
+
+// BestOverlap is like LargestOverlap, except that when 'needle' doesn't
+// overlap any rectangle in 'haystack', the index of the rectangle closest to
+// it is returned instead. So a window that is off screen still belongs to a
+// head. It only returns -1 if 'haystack' is empty.
+func BestOverlap(needle Rect, haystack []Rect) int {
+	if i := LargestOverlap(needle, haystack); i >= 0 {
+		return i
+	}
+
+	best, bestDist := -1, 0
+	for i, r := range haystack {
+		if dist := distance(needle, r); best == -1 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return best
+}
+
+// distance returns the square of the distance between the closest points of
+// two rectangles.
+func distance(r1, r2 Rect) int {
+	x1, y1, w1, h1 := r1.Pieces()
+	x2, y2, w2, h2 := r2.Pieces()
+	dx := max(max(x2-(x1+w1), x1-(x2+w2)), 0)
+	dy := max(max(y2-(y1+h1), y1-(y2+h2)), 0)
+	return dx*dx + dy*dy
+}
@@ -0,0 +1,84 @@
+package xrect
+
+import (
+	"testing"
+)
+
+// TestNeighbor checks the rectangles picked by Neighbor. The source
+// rectangle is always (100, 100) to (200, 200).
+func TestNeighbor(t *testing.T) {
+	src := New(100, 100, 100, 100)
+	tests := []struct {
+		name       string
+		dir        Direction
+		candidates []Rect
+		want       int
+	}{
+		{"no candidates", Right, nil, -1},
+		{"nothing in the direction", Right, []Rect{
+			New(0, 0, 50, 50), New(120, 300, 10, 10)}, -1},
+		{"inside of the source", Right, []Rect{
+			New(150, 120, 40, 40)}, -1},
+		{"overlapping the source", Right, []Rect{
+			New(180, 100, 100, 100)}, 0},
+		{"touching the source", Down, []Rect{
+			New(100, 200, 10, 10)}, 0},
+		{"closest overlapping", Left, []Rect{
+			New(0, 120, 50, 50), New(60, 180, 30, 100)}, 1},
+		{"overlapping beats closer", Left, []Rect{
+			New(0, 100, 10, 10), New(90, 0, 5, 5)}, 0},
+		{"ties broken by overlap", Right, []Rect{
+			New(210, 100, 20, 10), New(210, 150, 20, 80)}, 1},
+		{"first of equal candidates", Right, []Rect{
+			New(210, 100, 10, 100), New(210, 100, 10, 100)}, 0},
+		{"closest center", Up, []Rect{
+			New(0, 0, 10, 10), New(250, 40, 10, 10)}, 1},
+		{"closest center, down", Down, []Rect{
+			New(300, 300, 10, 10), New(210, 250, 10, 10)}, 1},
+	}
+	for _, test := range tests {
+		if got := Neighbor(src, test.candidates, test.dir); got != test.want {
+			t.Fatalf("%s: Neighbor returned %d, but %d was expected.",
+				test.name, got, test.want)
+		}
+	}
+}
+
+// TestHeadSearch checks ContainingPoint and BestOverlap with two heads side
+// by side.
+func TestHeadSearch(t *testing.T) {
+	heads := []Rect{New(0, 0, 100, 100), New(100, 0, 100, 100)}
+
+	points := []struct {
+		x, y, want int
+	}{
+		{0, 0, 0}, {99, 99, 0}, {100, 0, 1}, {199, 50, 1},
+		{200, 0, -1}, {-1, 5, -1}, {50, 100, -1},
+	}
+	for _, p := range points {
+		if got := ContainingPoint(p.x, p.y, heads); got != p.want {
+			t.Fatalf("ContainingPoint(%d, %d) returned %d, but %d was "+
+				"expected.", p.x, p.y, got, p.want)
+		}
+	}
+
+	rects := []struct {
+		name   string
+		needle Rect
+		want   int
+	}{
+		{"largest overlap", New(20, 10, 100, 10), 0},
+		{"off screen to the right", New(250, 10, 10, 10), 1},
+		{"off screen to the top left", New(-50, -50, 10, 10), 0},
+		{"off screen below", New(150, 300, 10, 10), 1},
+	}
+	for _, r := range rects {
+		if got := BestOverlap(r.needle, heads); got != r.want {
+			t.Fatalf("%s: BestOverlap returned %d, but %d was expected.",
+				r.name, got, r.want)
+		}
+	}
+	if got := BestOverlap(New(0, 0, 10, 10), nil); got != -1 {
+		t.Fatalf("BestOverlap returned %d without rectangles.", got)
+	}
+}
@@ -8,5 +8,34 @@ Some of the main features of this package include finding the area of
 intersection of two rectangles, finding the largest overlap between some
 rectangle and a set of rectangles, applying partial struts to rectangles
 representing all active heads, and a function to subtract two rectangles.
+
+Neighbor finds the rectangle next to another rectangle in a direction, like
+the window to the left of the focused window or the head below the current
+head. ContainingPoint finds the head containing a point (like the pointer),
+and BestOverlap finds the head a window is on (even if it's off screen).
+
+Regions
+
+A Region is an arbitrary set of pixels made of rectangles, like the shape of
+a window or the area of the screen not covered by struts. Regions support
+Union, Intersect, Subtract and Xor, and are always stored as a minimal list
+of non-overlapping rectangles (see Rects). For example, to find the parts of
+the screen not covered by a panel:
+
+	free := xrect.NewRegion(xrect.New(0, 0, 1920, 1080)).Subtract(
+		xrect.NewRegion(xrect.New(0, 0, 1920, 30)))
+
+Edge resistance and snapping
+
+Resistance makes windows that are moved or resized with the mouse stop at
+the edges of heads and of other windows for a while (so that they're easy to
+keep on screen and next to each other), and snap to nearby edges. It is
+meant to be used from the step function of a drag (see mousebind.Drag):
+
+	res := &xrect.Resistance{Heads: heads, Windows: others,
+		HeadResist: 40, WindowResist: 20, Snap: 10}
+	...
+	current = res.Move(current, xrect.New(startX+rootX-startRootX,
+		startY+rootY-startRootY, width, height))
 */
 package xrect
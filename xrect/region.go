@@ -0,0 +1,298 @@
+package xrect
+
+/*
+xrect/region.go contains a Region type, which is an arbitrary area made of
+rectangles, with boolean operations like union and intersection.
+
+A region is stored as a list of bands, from top to bottom. Each band is a
+range of rows, and has a list of spans (ranges of columns) from left to
+right, which don't overlap or touch. Bands don't overlap, and two bands that
+touch never have the same spans. (They would have been merged into one.)
+This is the same representation used by the X server (and pixman), and it
+means that each region has exactly one representation, so regions can be
+compared with Equal.
+*/
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Region is a set of pixels, made of non-overlapping rectangles. The zero
+// value is an empty region. Regions are values: operations on a region
+// return a new region and never modify the region they're called on.
+type Region struct {
+	bands []band
+}
+
+// band is a range of rows [y1, y2) in a region, with the spans of columns in
+// those rows that are in the region.
+type band struct {
+	y1, y2 int
+	spans  []span
+}
+
+// span is a range of columns [x1, x2).
+type span struct {
+	x1, x2 int
+}
+
+// NewRegion returns the region covered by the rectangles given, which may
+// overlap. Rectangles without a positive width and height are ignored.
+func NewRegion(rects ...Rect) Region {
+	var reg Region
+	for _, r := range rects {
+		x, y, w, h := r.Pieces()
+		if w <= 0 || h <= 0 {
+			continue
+		}
+		rreg := Region{[]band{{y, y + h, []span{{x, x + w}}}}}
+		reg = reg.Union(rreg)
+	}
+	return reg
+}
+
+// Union returns the pixels that are in either region.
+func (reg Region) Union(other Region) Region {
+	return combine(reg, other, func(a, b bool) bool { return a || b })
+}
+
+// Intersect returns the pixels that are in both regions.
+func (reg Region) Intersect(other Region) Region {
+	return combine(reg, other, func(a, b bool) bool { return a && b })
+}
+
+// Subtract returns the pixels in this region that aren't in 'other'.
+func (reg Region) Subtract(other Region) Region {
+	return combine(reg, other, func(a, b bool) bool { return a && !b })
+}
+
+// Xor returns the pixels that are in exactly one of the regions.
+func (reg Region) Xor(other Region) Region {
+	return combine(reg, other, func(a, b bool) bool { return a != b })
+}
+
+// Translate returns the region moved by (dx, dy).
+func (reg Region) Translate(dx, dy int) Region {
+	bands := make([]band, len(reg.bands))
+	for i, b := range reg.bands {
+		spans := make([]span, len(b.spans))
+		for j, s := range b.spans {
+			spans[j] = span{s.x1 + dx, s.x2 + dx}
+		}
+		bands[i] = band{b.y1 + dy, b.y2 + dy, spans}
+	}
+	return Region{bands}
+}
+
+// Empty returns whether the region has no pixels.
+func (reg Region) Empty() bool {
+	return len(reg.bands) == 0
+}
+
+// Equal returns whether two regions have the same pixels.
+func (reg Region) Equal(other Region) bool {
+	if len(reg.bands) != len(other.bands) {
+		return false
+	}
+	for i, b := range reg.bands {
+		o := other.bands[i]
+		if b.y1 != o.y1 || b.y2 != o.y2 || !sameSpans(b.spans, o.spans) {
+			return false
+		}
+	}
+	return true
+}
+
+// Contains returns whether the pixel at (x, y) is in the region.
+func (reg Region) Contains(x, y int) bool {
+	i := sort.Search(len(reg.bands), func(i int) bool {
+		return reg.bands[i].y2 > y
+	})
+	if i == len(reg.bands) || reg.bands[i].y1 > y {
+		return false
+	}
+	spans := reg.bands[i].spans
+	j := sort.Search(len(spans), func(j int) bool {
+		return spans[j].x2 > x
+	})
+	return j < len(spans) && spans[j].x1 <= x
+}
+
+// ContainsRect returns whether every pixel of the rectangle is in the region.
+func (reg Region) ContainsRect(r Rect) bool {
+	return NewRegion(r).Subtract(reg).Empty()
+}
+
+// Overlaps returns whether any pixel is in both regions.
+func (reg Region) Overlaps(other Region) bool {
+	return !reg.Intersect(other).Empty()
+}
+
+// Bounds returns the smallest rectangle containing the region. The bounds of
+// an empty region is a rectangle at (0, 0) with no width or height.
+func (reg Region) Bounds() Rect {
+	if reg.Empty() {
+		return New(0, 0, 0, 0)
+	}
+	x1, x2 := reg.bands[0].spans[0].x1, reg.bands[0].spans[0].x2
+	for _, b := range reg.bands {
+		x1 = min(x1, b.spans[0].x1)
+		x2 = max(x2, b.spans[len(b.spans)-1].x2)
+	}
+	y1, y2 := reg.bands[0].y1, reg.bands[len(reg.bands)-1].y2
+	return New(x1, y1, x2-x1, y2-y1)
+}
+
+// Area returns the number of pixels in the region.
+func (reg Region) Area() int {
+	area := 0
+	for _, b := range reg.bands {
+		for _, s := range b.spans {
+			area += (s.x2 - s.x1) * (b.y2 - b.y1)
+		}
+	}
+	return area
+}
+
+// Rects returns the non-overlapping rectangles that make up the region,
+// sorted from top to bottom and then left to right.
+func (reg Region) Rects() []Rect {
+	rects := make([]Rect, 0, len(reg.bands))
+	reg.Each(func(x, y, width, height int) {
+		rects = append(rects, New(x, y, width, height))
+	})
+	return rects
+}
+
+// Each calls 'f' with each of the rectangles that make up the region, in the
+// same order as Rects, without allocating them.
+func (reg Region) Each(f func(x, y, width, height int)) {
+	for _, b := range reg.bands {
+		for _, s := range b.spans {
+			f(s.x1, b.y1, s.x2-s.x1, b.y2-b.y1)
+		}
+	}
+}
+
+func (reg Region) String() string {
+	rects := make([]string, 0, len(reg.bands))
+	reg.Each(func(x, y, width, height int) {
+		rects = append(rects,
+			fmt.Sprintf("[(%d, %d) %dx%d]", x, y, width, height))
+	})
+	return "{" + strings.Join(rects, " ") + "}"
+}
+
+// combine returns the region of the pixels for which 'op' returns true, given
+// whether they are in 'a' and whether they are in 'b'. (op(false, false)
+// must be false.)
+//
+// The rows are cut into slabs at every top and bottom edge of a band in
+// either region, so that the spans of each region are the same in every row
+// of a slab. The spans of the slab are combined, and the slab is merged with
+// the band above it if they have the same spans.
+func combine(a, b Region, op func(a, b bool) bool) Region {
+	ys := make([]int, 0, 2*(len(a.bands)+len(b.bands)))
+	for _, bands := range [][]band{a.bands, b.bands} {
+		for _, bnd := range bands {
+			ys = append(ys, bnd.y1, bnd.y2)
+		}
+	}
+	ys = sortUnique(ys)
+
+	var out []band
+	ia, ib := 0, 0
+	for k := 0; k+1 < len(ys); k++ {
+		y1, y2 := ys[k], ys[k+1]
+		for ia < len(a.bands) && a.bands[ia].y2 <= y1 {
+			ia++
+		}
+		for ib < len(b.bands) && b.bands[ib].y2 <= y1 {
+			ib++
+		}
+
+		var sa, sb []span
+		if ia < len(a.bands) && a.bands[ia].y1 <= y1 {
+			sa = a.bands[ia].spans
+		}
+		if ib < len(b.bands) && b.bands[ib].y1 <= y1 {
+			sb = b.bands[ib].spans
+		}
+
+		spans := combineSpans(sa, sb, op)
+		if len(spans) == 0 {
+			continue
+		}
+		if n := len(out); n > 0 && out[n-1].y2 == y1 &&
+			sameSpans(out[n-1].spans, spans) {
+
+			out[n-1].y2 = y2
+			continue
+		}
+		out = append(out, band{y1, y2, spans})
+	}
+	return Region{out}
+}
+
+// combineSpans is like combine, but for the spans of a single row.
+func combineSpans(a, b []span, op func(a, b bool) bool) []span {
+	xs := make([]int, 0, 2*(len(a)+len(b)))
+	for _, spans := range [][]span{a, b} {
+		for _, s := range spans {
+			xs = append(xs, s.x1, s.x2)
+		}
+	}
+	xs = sortUnique(xs)
+
+	var out []span
+	ia, ib := 0, 0
+	for k := 0; k+1 < len(xs); k++ {
+		x1, x2 := xs[k], xs[k+1]
+		for ia < len(a) && a[ia].x2 <= x1 {
+			ia++
+		}
+		for ib < len(b) && b[ib].x2 <= x1 {
+			ib++
+		}
+
+		inA := ia < len(a) && a[ia].x1 <= x1
+		inB := ib < len(b) && b[ib].x1 <= x1
+		if !op(inA, inB) {
+			continue
+		}
+		if n := len(out); n > 0 && out[n-1].x2 == x1 {
+			out[n-1].x2 = x2
+			continue
+		}
+		out = append(out, span{x1, x2})
+	}
+	return out
+}
+
+// sameSpans returns whether two lists of spans are the same.
+func sameSpans(a, b []span) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// sortUnique sorts the numbers given and removes duplicates, in place.
+func sortUnique(nums []int) []int {
+	sort.Ints(nums)
+	n := 0
+	for i, num := range nums {
+		if i == 0 || num != nums[n-1] {
+			nums[n] = num
+			n++
+		}
+	}
+	return nums[:n]
+}
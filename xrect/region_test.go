@@ -0,0 +1,274 @@
+package xrect
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// The tests compare regions with bitmaps of the pixels in a small area, which
+// are easy to get right. The area includes negative coordinates, and covers
+// every pixel the regions in the tests can have.
+const (
+	bitmapMin  = -4
+	bitmapMax  = 20
+	bitmapSize = bitmapMax - bitmapMin
+)
+
+// bitmap is the set of pixels in [bitmapMin, bitmapMax) in both axes.
+type bitmap [bitmapSize][bitmapSize]bool
+
+// newBitmap returns the bitmap of the pixels covered by the rectangles given.
+func newBitmap(rects []Rect) *bitmap {
+	bm := &bitmap{}
+	for _, r := range rects {
+		x, y, w, h := r.Pieces()
+		for py := y; py < y+h; py++ {
+			for px := x; px < x+w; px++ {
+				bm.set(px, py, true)
+			}
+		}
+	}
+	return bm
+}
+
+// get returns whether the pixel at (x, y) is set. Pixels outside of the
+// bitmap are never set.
+func (bm *bitmap) get(x, y int) bool {
+	if x < bitmapMin || x >= bitmapMax || y < bitmapMin || y >= bitmapMax {
+		return false
+	}
+	return bm[y-bitmapMin][x-bitmapMin]
+}
+
+func (bm *bitmap) set(x, y int, v bool) {
+	bm[y-bitmapMin][x-bitmapMin] = v
+}
+
+// combine returns the bitmap of the pixels for which 'op' returns true.
+func (bm *bitmap) combine(other *bitmap, op func(a, b bool) bool) *bitmap {
+	res := &bitmap{}
+	for y := range bm {
+		for x := range bm[y] {
+			res[y][x] = op(bm[y][x], other[y][x])
+		}
+	}
+	return res
+}
+
+// randomRects returns up to 'n' random rectangles, some of which are empty.
+// They stay at least 2 pixels (the largest translation used) away from the
+// edges of the bitmap.
+func randomRects(rng *rand.Rand, n int) []Rect {
+	rects := make([]Rect, rng.Intn(n+1))
+	for i := range rects {
+		x := bitmapMin + 2 + rng.Intn(bitmapSize-10)
+		y := bitmapMin + 2 + rng.Intn(bitmapSize-10)
+		rects[i] = New(x, y, rng.Intn(8), rng.Intn(8))
+	}
+	return rects
+}
+
+// checkRegion fails the test if 'reg' doesn't have exactly the pixels in
+// 'want', or isn't in canonical form.
+func checkRegion(t *testing.T, what string, reg Region, want *bitmap) {
+	t.Helper()
+
+	area := 0
+	for y := bitmapMin; y < bitmapMax; y++ {
+		for x := bitmapMin; x < bitmapMax; x++ {
+			if reg.Contains(x, y) != want.get(x, y) {
+				t.Fatalf("%s: %s: Contains(%d, %d) is %v.",
+					what, reg, x, y, reg.Contains(x, y))
+			}
+			if want.get(x, y) {
+				area++
+			}
+		}
+	}
+	if reg.Area() != area {
+		t.Fatalf("%s: %s: Area is %d, but %d pixels are set.",
+			what, reg, reg.Area(), area)
+	}
+
+	// The rectangles of the region must cover exactly the pixels in 'want',
+	// each of them once.
+	covered := &bitmap{}
+	for _, r := range reg.Rects() {
+		x, y, w, h := r.Pieces()
+		if w <= 0 || h <= 0 {
+			t.Fatalf("%s: %s: Empty rectangle %s.", what, reg, r)
+		}
+		for py := y; py < y+h; py++ {
+			for px := x; px < x+w; px++ {
+				if covered.get(px, py) {
+					t.Fatalf("%s: %s: Rectangles overlap at (%d, %d).",
+						what, reg, px, py)
+				}
+				covered.set(px, py, true)
+			}
+		}
+	}
+	if *covered != *want {
+		t.Fatalf("%s: %s: Rectangles don't cover the region.", what, reg)
+	}
+
+	checkBounds(t, what, reg, want)
+	checkCanonical(t, what, reg)
+
+	if !NewRegion(reg.Rects()...).Equal(reg) {
+		t.Fatalf("%s: %s: The region isn't equal to the region of its "+
+			"rectangles.", what, reg)
+	}
+}
+
+// checkBounds fails the test if the bounds of 'reg' aren't the smallest
+// rectangle containing the pixels in 'want'.
+func checkBounds(t *testing.T, what string, reg Region, want *bitmap) {
+	t.Helper()
+
+	x1, y1, x2, y2 := bitmapMax, bitmapMax, bitmapMin, bitmapMin
+	for y := bitmapMin; y < bitmapMax; y++ {
+		for x := bitmapMin; x < bitmapMax; x++ {
+			if want.get(x, y) {
+				x1, y1 = min(x1, x), min(y1, y)
+				x2, y2 = max(x2, x+1), max(y2, y+1)
+			}
+		}
+	}
+	wantBounds := New(0, 0, 0, 0)
+	if x2 > x1 {
+		wantBounds = New(x1, y1, x2-x1, y2-y1)
+	}
+
+	bx, by, bw, bh := reg.Bounds().Pieces()
+	wx, wy, ww, wh := wantBounds.Pieces()
+	if bx != wx || by != wy || bw != ww || bh != wh {
+		t.Fatalf("%s: %s: Bounds are %s, but %s was expected.",
+			what, reg, reg.Bounds(), wantBounds)
+	}
+}
+
+// checkCanonical fails the test if the bands of 'reg' aren't in canonical
+// form: sorted from top to bottom without overlapping, with spans sorted from
+// left to right that don't overlap or touch, and without touching bands that
+// have the same spans.
+func checkCanonical(t *testing.T, what string, reg Region) {
+	t.Helper()
+
+	for i, b := range reg.bands {
+		if b.y1 >= b.y2 || len(b.spans) == 0 {
+			t.Fatalf("%s: %s: Band %d is empty.", what, reg, i)
+		}
+		if i > 0 {
+			prev := reg.bands[i-1]
+			if prev.y2 > b.y1 {
+				t.Fatalf("%s: %s: Band %d isn't below band %d.",
+					what, reg, i, i-1)
+			}
+			if prev.y2 == b.y1 && sameSpans(prev.spans, b.spans) {
+				t.Fatalf("%s: %s: Bands %d and %d should be merged.",
+					what, reg, i-1, i)
+			}
+		}
+		for j, s := range b.spans {
+			if s.x1 >= s.x2 {
+				t.Fatalf("%s: %s: Span %d of band %d is empty.",
+					what, reg, j, i)
+			}
+			if j > 0 && b.spans[j-1].x2 >= s.x1 {
+				t.Fatalf("%s: %s: Spans %d and %d of band %d overlap or "+
+					"touch.", what, reg, j-1, j, i)
+			}
+		}
+	}
+}
+
+// checkOps checks every operation on the regions of two sets of rectangles
+// against the same operation on their bitmaps.
+func checkOps(t *testing.T, rects1, rects2 []Rect, dx, dy int) {
+	t.Helper()
+
+	reg1, reg2 := NewRegion(rects1...), NewRegion(rects2...)
+	bm1, bm2 := newBitmap(rects1), newBitmap(rects2)
+
+	checkRegion(t, "NewRegion", reg1, bm1)
+	checkRegion(t, "Union", reg1.Union(reg2),
+		bm1.combine(bm2, func(a, b bool) bool { return a || b }))
+	checkRegion(t, "Intersect", reg1.Intersect(reg2),
+		bm1.combine(bm2, func(a, b bool) bool { return a && b }))
+	checkRegion(t, "Subtract", reg1.Subtract(reg2),
+		bm1.combine(bm2, func(a, b bool) bool { return a && !b }))
+	checkRegion(t, "Xor", reg1.Xor(reg2),
+		bm1.combine(bm2, func(a, b bool) bool { return a != b }))
+
+	moved := &bitmap{}
+	for y := bitmapMin; y < bitmapMax; y++ {
+		for x := bitmapMin; x < bitmapMax; x++ {
+			if bm1.get(x, y) {
+				moved.set(x+dx, y+dy, true)
+			}
+		}
+	}
+	checkRegion(t, "Translate", reg1.Translate(dx, dy), moved)
+
+	overlaps := reg1.Intersect(reg2).Area() > 0
+	if reg1.Overlaps(reg2) != overlaps {
+		t.Fatalf("%s and %s: Overlaps is %v.", reg1, reg2, !overlaps)
+	}
+	if reg1.Union(reg2).Equal(reg1) != containsRects(reg1, reg2.Rects()) {
+		t.Fatalf("%s and %s: ContainsRect disagrees with Union.",
+			reg1, reg2)
+	}
+}
+
+// containsRects returns whether 'reg' contains every rectangle given.
+func containsRects(reg Region, rects []Rect) bool {
+	for _, r := range rects {
+		if !reg.ContainsRect(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// TestRegionRandom checks the operations on regions of random sets of
+// rectangles.
+func TestRegionRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		checkOps(t, randomRects(rng, 6), randomRects(rng, 6),
+			rng.Intn(5)-2, rng.Intn(5)-2)
+	}
+}
+
+// TestRegionPairs checks the operations on every pair of rectangles in a
+// 4x4 area, which covers every way two rectangles can overlap and touch.
+func TestRegionPairs(t *testing.T) {
+	var rects []Rect
+	for x1 := 0; x1 < 4; x1++ {
+		for x2 := x1 + 1; x2 <= 4; x2++ {
+			for y1 := 0; y1 < 4; y1++ {
+				for y2 := y1 + 1; y2 <= 4; y2++ {
+					rects = append(rects, New(x1, y1, x2-x1, y2-y1))
+				}
+			}
+		}
+	}
+	for _, r1 := range rects {
+		for _, r2 := range rects {
+			checkOps(t, []Rect{r1}, []Rect{r2}, 1, -1)
+		}
+	}
+}
+
+// TestRegionEmpty checks the zero value, which is an empty region.
+func TestRegionEmpty(t *testing.T) {
+	var reg Region
+	checkRegion(t, "Region{}", reg, &bitmap{})
+	if !reg.Empty() || !NewRegion(New(1, 1, 0, 5)).Empty() {
+		t.Fatalf("Regions without pixels aren't empty.")
+	}
+	if !reg.Equal(NewRegion()) {
+		t.Fatalf("The zero value isn't equal to NewRegion().")
+	}
+}
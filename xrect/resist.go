@@ -0,0 +1,225 @@
+package xrect
+
+/*
+xrect/resist.go contains edge resistance and snapping for moving and
+resizing windows with the mouse, like in Openbox.
+
+With edge resistance, an edge of a window stops when it reaches the edge of
+a head (so that the window doesn't leave the head) or the edge of another
+window (so that they don't overlap). If the pointer keeps going for more
+than the resistance, the window is let go. With snapping, an edge of a window
+jumps to the edge of a head or of another window when it comes close to it,
+so that windows can be lined up easily.
+*/
+
+// Resistance contains the obstacles that resist moving and resizing windows,
+// and how strongly they resist.
+//
+// Move and Resize take the current geometry of a window and the geometry
+// proposed by a drag, and return the geometry after resistance and
+// snapping. The proposed geometry must be computed from the geometry when the
+// drag began (not the current geometry), or else a resisted window could
+// never be let go. For example, in the step function of mousebind.Drag:
+//
+//	proposed := xrect.New(startX+(rootX-startRootX),
+//		startY+(rootY-startRootY), width, height)
+//	current = resistance.Move(current, proposed)
+//	win.Move(current.X(), current.Y())
+type Resistance struct {
+	// Heads are the areas windows are kept in. (Like the workarea of each
+	// head.) Their edges resist windows leaving them.
+	Heads []Rect
+
+	// Windows are the other windows. Their edges resist windows moving into
+	// them. (The window being moved shouldn't be one of them.)
+	Windows []Rect
+
+	// HeadResist and WindowResist are how far (in pixels) the pointer must go
+	// past the edge of a head or a window before it is let go. Resistance is
+	// turned off when they aren't positive.
+	HeadResist, WindowResist int
+
+	// Snap is how close (in pixels) an edge of a window must be to the edge of
+	// a head or of another window for it to snap to it. Snapping is turned
+	// off when it isn't positive.
+	Snap int
+}
+
+// wall is an edge that resists edges of windows crossing it.
+type wall struct {
+	pos, resist int
+}
+
+// axisEdges are the edges of the obstacles along one axis.
+type axisEdges struct {
+	// lows resist the low (left or top) edges of windows moving to lower
+	// coordinates, and highs resist the high (right or bottom) edges of
+	// windows moving to higher coordinates.
+	lows, highs []wall
+
+	// snaps are the coordinates that edges can snap to.
+	snaps []int
+}
+
+// Move returns the geometry of a window moved from 'current' to 'proposed',
+// after resistance and snapping. Its size is the proposed size.
+func (res *Resistance) Move(current, proposed Rect) Rect {
+	cx, cy, _, _ := current.Pieces()
+	px, py, pw, ph := proposed.Pieces()
+
+	x := res.moveAxis(cx, px, pw, res.edges(false, py, py+ph))
+	y := res.moveAxis(cy, py, ph, res.edges(true, px, px+pw))
+	return New(x, y, pw, ph)
+}
+
+// Resize returns the geometry of a window resized from 'current' to
+// 'proposed', after resistance and snapping. Only the edges that moved are
+// resisted and snapped.
+func (res *Resistance) Resize(current, proposed Rect) Rect {
+	cx, cy, cw, ch := current.Pieces()
+	px, py, pw, ph := proposed.Pieces()
+
+	xedges := res.edges(false, py, py+ph)
+	x1 := res.moveEdge(cx, px, xedges.lows, nil, xedges.snaps)
+	x2 := res.moveEdge(cx+cw, px+pw, nil, xedges.highs, xedges.snaps)
+	yedges := res.edges(true, px, px+pw)
+	y1 := res.moveEdge(cy, py, yedges.lows, nil, yedges.snaps)
+	y2 := res.moveEdge(cy+ch, py+ph, nil, yedges.highs, yedges.snaps)
+	return New(x1, y1, max(x2-x1, 1), max(y2-y1, 1))
+}
+
+// moveAxis returns the new low coordinate of a window moving along one axis
+// from 'cur' to 'prop'. Both of its edges are resisted, and the one that's
+// resisted first wins. If neither is, the edge closest to something to snap
+// to is snapped.
+func (res *Resistance) moveAxis(cur, prop, size int, edges axisEdges) int {
+	if prop == cur {
+		return prop
+	}
+
+	lo := resistEdge(cur, prop, edges.lows, nil)
+	hi := resistEdge(cur+size, prop+size, nil, edges.highs) - size
+	switch {
+	case lo != prop && hi != prop:
+		if abs(lo-cur) < abs(hi-cur) {
+			return lo
+		}
+		return hi
+	case lo != prop:
+		return lo
+	case hi != prop:
+		return hi
+	}
+
+	if delta, ok := res.snapDelta(edges.snaps, prop, prop+size); ok {
+		return prop + delta
+	}
+	return prop
+}
+
+// moveEdge returns the new coordinate of a single edge moving from 'cur' to
+// 'prop', after resistance and snapping.
+func (res *Resistance) moveEdge(cur, prop int, lows, highs []wall,
+	snaps []int) int {
+
+	if prop == cur {
+		return prop
+	}
+	if pos := resistEdge(cur, prop, lows, highs); pos != prop {
+		return pos
+	}
+	if delta, ok := res.snapDelta(snaps, prop); ok {
+		return prop + delta
+	}
+	return prop
+}
+
+// resistEdge returns where an edge moving from 'cur' to 'prop' stops. It is
+// stopped by the first wall it crosses (in 'lows' when moving to lower
+// coordinates, and in 'highs' when moving to higher coordinates) that it
+// hasn't gone past by more than the wall's resistance.
+func resistEdge(cur, prop int, lows, highs []wall) int {
+	stop := prop
+	if prop > cur {
+		for _, w := range highs {
+			if cur <= w.pos && prop > w.pos && prop-w.pos <= w.resist &&
+				w.pos < stop {
+
+				stop = w.pos
+			}
+		}
+	} else {
+		for _, w := range lows {
+			if cur >= w.pos && prop < w.pos && w.pos-prop <= w.resist &&
+				w.pos > stop {
+
+				stop = w.pos
+			}
+		}
+	}
+	return stop
+}
+
+// snapDelta returns the smallest distance that moves one of the positions
+// given on to one of the snap coordinates, if it is no more than Snap.
+func (res *Resistance) snapDelta(snaps []int, positions ...int) (int, bool) {
+	best, found := 0, false
+	if res.Snap <= 0 {
+		return 0, false
+	}
+	for _, s := range snaps {
+		for _, pos := range positions {
+			delta := s - pos
+			if abs(delta) <= res.Snap && (!found || abs(delta) < abs(best)) {
+				best, found = delta, true
+			}
+		}
+	}
+	return best, found
+}
+
+// edges returns the edges of the obstacles along one axis (x, or y if
+// 'vertical' is true) that matter to a window spanning [perp1, perp2) along
+// the other axis. Obstacles only matter if they overlap with the window
+// along the other axis. (For snapping, touching is enough.)
+func (res *Resistance) edges(vertical bool, perp1, perp2 int) axisEdges {
+	var edges axisEdges
+	span := func(r Rect) (int, int, int, int) {
+		x, y, w, h := r.Pieces()
+		if vertical {
+			return y, y + h, x, x + w
+		}
+		return x, x + w, y, y + h
+	}
+
+	for _, head := range res.Heads {
+		lo, hi, p1, p2 := span(head)
+		overlap := min(perp2, p2) - max(perp1, p1)
+		if overlap > 0 && res.HeadResist > 0 {
+			edges.lows = append(edges.lows, wall{lo, res.HeadResist})
+			edges.highs = append(edges.highs, wall{hi, res.HeadResist})
+		}
+		if overlap >= 0 {
+			edges.snaps = append(edges.snaps, lo, hi)
+		}
+	}
+	for _, win := range res.Windows {
+		lo, hi, p1, p2 := span(win)
+		overlap := min(perp2, p2) - max(perp1, p1)
+		if overlap > 0 && res.WindowResist > 0 {
+			edges.lows = append(edges.lows, wall{hi, res.WindowResist})
+			edges.highs = append(edges.highs, wall{lo, res.WindowResist})
+		}
+		if overlap >= 0 {
+			edges.snaps = append(edges.snaps, lo, hi)
+		}
+	}
+	return edges
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
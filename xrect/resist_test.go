@@ -0,0 +1,135 @@
+package xrect
+
+import (
+	"testing"
+)
+
+// newResistance returns the obstacles used by the tests: a 1000x800 head,
+// and a window from (600, 100) to (800, 300).
+func newResistance(headResist, windowResist, snap int) *Resistance {
+	return &Resistance{
+		Heads:        []Rect{New(0, 0, 1000, 800)},
+		Windows:      []Rect{New(600, 100, 200, 200)},
+		HeadResist:   headResist,
+		WindowResist: windowResist,
+		Snap:         snap,
+	}
+}
+
+// TestResistMove checks the positions returned by Move.
+func TestResistMove(t *testing.T) {
+	resist := newResistance(20, 10, 0)
+	snap := newResistance(0, 0, 8)
+	both := newResistance(20, 10, 8)
+
+	tests := []struct {
+		name          string
+		res           *Resistance
+		current, prop Rect
+		want          Rect
+	}{
+		{"no obstacles", resist, New(100, 500, 100, 100),
+			New(150, 520, 100, 100), New(150, 520, 100, 100)},
+		{"not moving", both, New(3, 500, 100, 100),
+			New(3, 500, 100, 100), New(3, 500, 100, 100)},
+
+		// Head resistance.
+		{"head left edge", resist, New(10, 500, 100, 100),
+			New(-15, 500, 100, 100), New(0, 500, 100, 100)},
+		{"head left edge at the threshold", resist, New(10, 500, 100, 100),
+			New(-20, 500, 100, 100), New(0, 500, 100, 100)},
+		{"head left edge past the threshold", resist,
+			New(10, 500, 100, 100), New(-21, 500, 100, 100),
+			New(-21, 500, 100, 100)},
+		{"head right edge", resist, New(890, 500, 100, 100),
+			New(905, 500, 100, 100), New(900, 500, 100, 100)},
+		{"head bottom edge", resist, New(100, 690, 100, 100),
+			New(100, 715, 100, 100), New(100, 700, 100, 100)},
+		{"head corner", resist, New(10, 10, 100, 100),
+			New(-5, -5, 100, 100), New(0, 0, 100, 100)},
+		{"outside of the head", resist, New(-50, 500, 100, 100),
+			New(-60, 500, 100, 100), New(-60, 500, 100, 100)},
+
+		// Window resistance.
+		{"window left edge", resist, New(480, 150, 100, 100),
+			New(505, 150, 100, 100), New(500, 150, 100, 100)},
+		{"window left edge at the threshold", resist,
+			New(480, 150, 100, 100), New(510, 150, 100, 100),
+			New(500, 150, 100, 100)},
+		{"window left edge past the threshold", resist,
+			New(480, 150, 100, 100), New(511, 150, 100, 100),
+			New(511, 150, 100, 100)},
+		{"window right edge", resist, New(820, 150, 100, 100),
+			New(795, 150, 100, 100), New(800, 150, 100, 100)},
+		{"window in other rows", resist, New(480, 400, 100, 100),
+			New(505, 400, 100, 100), New(505, 400, 100, 100)},
+		{"window touching rows", resist, New(480, 300, 100, 100),
+			New(505, 300, 100, 100), New(505, 300, 100, 100)},
+
+		// Snapping.
+		{"snap to head", snap, New(100, 500, 100, 100),
+			New(6, 500, 100, 100), New(0, 500, 100, 100)},
+		{"snap at the threshold", snap, New(100, 500, 100, 100),
+			New(8, 500, 100, 100), New(0, 500, 100, 100)},
+		{"snap past the threshold", snap, New(100, 500, 100, 100),
+			New(9, 500, 100, 100), New(9, 500, 100, 100)},
+		{"snap to window", snap, New(300, 150, 100, 100),
+			New(495, 150, 100, 100), New(500, 150, 100, 100)},
+		{"snap to touching window", snap, New(300, 300, 100, 100),
+			New(493, 300, 100, 100), New(500, 300, 100, 100)},
+		{"snap the closest edge", snap, New(300, 150, 202, 100),
+			New(596, 150, 202, 100), New(598, 150, 202, 100)},
+		{"resistance before snapping", both, New(10, 500, 100, 100),
+			New(-5, 500, 100, 100), New(0, 500, 100, 100)},
+		{"snapping without resistance", both, New(100, 500, 100, 100),
+			New(5, 500, 100, 100), New(0, 500, 100, 100)},
+	}
+	for _, test := range tests {
+		got := test.res.Move(test.current, test.prop)
+		if !sameRect(got, test.want) {
+			t.Fatalf("%s: Move returned %s, but %s was expected.",
+				test.name, got, test.want)
+		}
+	}
+}
+
+// TestResistResize checks the geometries returned by Resize.
+func TestResistResize(t *testing.T) {
+	resist := newResistance(20, 10, 0)
+	snap := newResistance(0, 0, 8)
+
+	tests := []struct {
+		name          string
+		res           *Resistance
+		current, prop Rect
+		want          Rect
+	}{
+		{"head right edge", resist, New(850, 500, 100, 100),
+			New(850, 500, 160, 100), New(850, 500, 150, 100)},
+		{"head right edge past the threshold", resist,
+			New(850, 500, 100, 100), New(850, 500, 171, 100),
+			New(850, 500, 171, 100)},
+		{"head left edge", resist, New(10, 500, 100, 100),
+			New(-10, 500, 120, 100), New(0, 500, 110, 100)},
+		{"window top edge", resist, New(650, 0, 50, 50),
+			New(650, 0, 50, 105), New(650, 0, 50, 100)},
+		{"snap to head", snap, New(100, 500, 100, 100),
+			New(100, 500, 895, 100), New(100, 500, 900, 100)},
+		{"unmoved edges aren't snapped", snap, New(3, 500, 100, 100),
+			New(3, 500, 110, 100), New(3, 500, 110, 100)},
+	}
+	for _, test := range tests {
+		got := test.res.Resize(test.current, test.prop)
+		if !sameRect(got, test.want) {
+			t.Fatalf("%s: Resize returned %s, but %s was expected.",
+				test.name, got, test.want)
+		}
+	}
+}
+
+// sameRect returns whether two rectangles have the same geometry.
+func sameRect(r1, r2 Rect) bool {
+	x1, y1, w1, h1 := r1.Pieces()
+	x2, y2, w2, h2 := r2.Pieces()
+	return x1 == x2 && y1 == y2 && w1 == w2 && h1 == h2
+}
@@ -0,0 +1,142 @@
+package xwindow
+
+/*
+xwindow/argb.go contains functions for creating windows with a 32 bit ARGB
+visual. When a compositing manager is running, the alpha channel of such
+windows is used to blend them with whatever is below them. This is how
+translucent windows (like on screen displays and notifications) are made.
+*/
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+)
+
+// argbColormaps caches the colormap created for the ARGB visual of each
+// connection, so that every ARGB window can share it.
+var (
+	argbColormaps    = make(map[*xgbutil.XUtil]xproto.Colormap)
+	argbColormapsLck = &sync.Mutex{}
+)
+
+// ARGBVisual returns the visual with a depth of 32 bits whose red, green and
+// blue components are each 8 bits wide, leaving 8 bits for alpha.
+// An error is returned if the X server doesn't have such a visual.
+func ARGBVisual(xu *xgbutil.XUtil) (xproto.Visualid, error) {
+	for _, depthInfo := range xu.Screen().AllowedDepths {
+		if depthInfo.Depth != 32 {
+			continue
+		}
+		for _, visual := range depthInfo.Visuals {
+			if visual.Class == xproto.VisualClassTrueColor &&
+				visual.RedMask == 0xff0000 &&
+				visual.GreenMask == 0xff00 &&
+				visual.BlueMask == 0xff {
+
+				return visual.VisualId, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("ARGBVisual: Could not find a 32 bit TrueColor " +
+		"visual with an alpha channel.")
+}
+
+// ARGBColormap returns a colormap for the visual returned by ARGBVisual.
+// Windows using the ARGB visual must have a colormap for that visual. The
+// colormap is created the first time ARGBColormap is called, and the same
+// colormap is returned afterwards.
+func ARGBColormap(xu *xgbutil.XUtil) (xproto.Colormap, error) {
+	argbColormapsLck.Lock()
+	defer argbColormapsLck.Unlock()
+
+	if cmap, ok := argbColormaps[xu]; ok {
+		return cmap, nil
+	}
+
+	visual, err := ARGBVisual(xu)
+	if err != nil {
+		return 0, err
+	}
+
+	cmap, err := xproto.NewColormapId(xu.Conn())
+	if err != nil {
+		return 0, err
+	}
+	err = xproto.CreateColormapChecked(xu.Conn(), xproto.ColormapAllocNone,
+		cmap, xu.RootWin(), visual).Check()
+	if err != nil {
+		return 0, err
+	}
+
+	argbColormaps[xu] = cmap
+	return cmap, nil
+}
+
+// CreateARGB is just like CreateChecked, except the window is created with
+// the ARGB visual (see ARGBVisual) and a depth of 32 bits.
+// The background pixel, border pixel and colormap of the window are set for
+// you, since X requires them for windows that don't use their parent's
+// visual. (The background and border are fully transparent.) If they are
+// included in valueMask, the values provided are used instead.
+//
+// To paint an xgraphics.Image to a window created by CreateARGB, set the
+// image's ARGB field to true before calling XSurfaceSet.
+//
+// An error is returned if the X server doesn't have an ARGB visual. In that
+// case, Create or CreateChecked can be used instead, with the understanding
+// that the window will be opaque.
+func (w *Window) CreateARGB(parent xproto.Window, x, y, width, height,
+	valueMask int, valueList ...uint32) error {
+
+	visual, err := ARGBVisual(w.X)
+	if err != nil {
+		return err
+	}
+	cmap, err := ARGBColormap(w.X)
+	if err != nil {
+		return err
+	}
+
+	valueMask, valueList = mergeValues(valueMask, valueList,
+		xproto.CwBackPixel|xproto.CwBorderPixel|xproto.CwColormap,
+		[]uint32{0, 0, uint32(cmap)})
+
+	return xproto.CreateWindowChecked(w.X.Conn(),
+		32, w.Id, parent,
+		int16(x), int16(y), uint16(width), uint16(height), 0,
+		xproto.WindowClassInputOutput, visual,
+		uint32(valueMask), valueList).Check()
+}
+
+// mergeValues adds the default values in 'defMask' and 'defList' to a value
+// mask and list, unless they are already in the mask. The resulting value
+// list is in the order required by X. (i.e., ordered by mask bit.)
+func mergeValues(mask int, list []uint32,
+	defMask int, defList []uint32) (int, []uint32) {
+
+	values := make(map[uint]uint32, len(list)+len(defList))
+	for bit, i := uint(0), 0; bit < 32; bit++ {
+		if defMask&(1<<bit) > 0 {
+			values[bit] = defList[i]
+			i++
+		}
+	}
+	for bit, i := uint(0), 0; bit < 32 && i < len(list); bit++ {
+		if mask&(1<<bit) > 0 {
+			values[bit] = list[i]
+			i++
+		}
+	}
+
+	merged := make([]uint32, 0, len(values))
+	for bit := uint(0); bit < 32; bit++ {
+		if v, ok := values[bit]; ok {
+			merged = append(merged, v)
+		}
+	}
+	return mask | defMask, merged
+}
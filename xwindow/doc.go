@@ -29,6 +29,25 @@ pixels tall and map the window, use something like:
 You may also want to use CreateChecked instead of Create if you want to see if
 there was an error when creating a window.
 
+Translucent windows
+
+Windows created with CreateARGB use a 32 bit visual with an alpha channel
+(see ARGBVisual), which a compositing manager uses to blend the window with
+whatever is below it. The xgraphics package can paint images with alpha to
+such windows. (See the 'translucent-window' example.)
+
+Shaped windows
+
+With the SHAPE extension, windows don't have to be rectangles. ShapeRects and
+ShapeImage set the bounding shape (the part of the window that is drawn) or
+the input shape (the part that receives pointer events) of a window, and
+ClickThrough makes a window ignore the pointer entirely:
+
+	win.ShapeImage(xwindow.ShapeBounding, img)
+	win.ClickThrough()
+
+ShapeRegion returns the current shape, and ShapeReset removes it.
+
 More examples
 
 The xwindow package is used in many of the examples in the examples directory
@@ -0,0 +1,237 @@
+package xwindow
+
+/*
+xwindow/shape.go contains methods for changing the shape of windows with the
+SHAPE extension.
+
+A window has three shapes, each of which is a region of the window:
+the bounding shape is the part of the window that is drawn at all, the clip
+shape is the part of the window that its contents are drawn in (which
+excludes its border) and the input shape is the part of the window that
+receives pointer events. Pointer events outside of the input shape go to
+whatever is below the window, which is how click-through windows (like on
+screen displays) are made.
+
+Input shapes need SHAPE 1.1, which every X server supporting compositing has.
+*/
+
+import (
+	"fmt"
+	"image"
+	"sync"
+
+	"github.com/BurntSushi/xgb/shape"
+	"github.com/BurntSushi/xgb/xproto"
+
+	"github.com/BurntSushi/xgbutil"
+	"github.com/BurntSushi/xgbutil/xrect"
+)
+
+// The kinds of shapes a window has. (See the SHAPE extension.)
+const (
+	ShapeBounding shape.Kind = shape.SkBounding
+	ShapeClip     shape.Kind = shape.SkClip
+	ShapeInput    shape.Kind = shape.SkInput
+)
+
+// shapeVersion is the version of SHAPE supported by an X server.
+type shapeVersion struct {
+	major, minor uint16
+}
+
+// shapeVersions caches the version of SHAPE for each connection.
+var (
+	shapeVersions    = make(map[*xgbutil.XUtil]shapeVersion)
+	shapeVersionsLck = &sync.Mutex{}
+)
+
+// ShapeRects sets the shape of the kind given (ShapeBounding, ShapeClip or
+// ShapeInput) to the union of the rectangles given, relative to the window's
+// origin. If there are no rectangles, the shape is empty. (Which hides the
+// window for ShapeBounding, and makes it click-through for ShapeInput.)
+func (w *Window) ShapeRects(kind shape.Kind, rects []xrect.Rect) error {
+	xrects := make([]xproto.Rectangle, 0, len(rects))
+	for _, r := range rects {
+		if r.Width() <= 0 || r.Height() <= 0 {
+			continue
+		}
+		xrects = append(xrects, xproto.Rectangle{
+			X:      int16(r.X()),
+			Y:      int16(r.Y()),
+			Width:  uint16(r.Width()),
+			Height: uint16(r.Height()),
+		})
+	}
+	return w.shapeRectangles(kind, xproto.ClipOrderingUnsorted, xrects)
+}
+
+// ShapeImage sets the shape of the kind given to the pixels of the image that
+// have an alpha value of at least 128. The top left corner of the image is
+// placed at the window's origin. This works with any image, including
+// xgraphics.Image values, so that a window can be shaped like the image
+// painted to it.
+func (w *Window) ShapeImage(kind shape.Kind, img image.Image) error {
+	return w.shapeRectangles(kind, xproto.ClipOrderingYXBanded,
+		alphaRectangles(img))
+}
+
+// ClickThrough makes the window ignore the pointer, by making its input shape
+// empty. Pointer events go to the windows below it instead.
+// Use ShapeReset(ShapeInput) to undo it.
+func (w *Window) ClickThrough() error {
+	return w.shapeRectangles(ShapeInput, xproto.ClipOrderingUnsorted, nil)
+}
+
+// ShapeReset removes the shape of the kind given, so that it is the whole
+// window again.
+func (w *Window) ShapeReset(kind shape.Kind) error {
+	if err := shapeInit(w.X, kind); err != nil {
+		return err
+	}
+	return shape.MaskChecked(w.X.Conn(), shape.SoSet, kind, w.Id, 0, 0,
+		0).Check()
+}
+
+// ShapeRegion returns the rectangles that make up the shape of the kind given,
+// relative to the window's origin. A window that hasn't been shaped returns
+// a single rectangle: the whole window. (Including its border for
+// ShapeBounding.)
+func (w *Window) ShapeRegion(kind shape.Kind) ([]xrect.Rect, error) {
+	if err := shapeInit(w.X, kind); err != nil {
+		return nil, err
+	}
+	reply, err := shape.GetRectangles(w.X.Conn(), w.Id, kind).Reply()
+	if err != nil {
+		return nil, err
+	}
+
+	rects := make([]xrect.Rect, len(reply.Rectangles))
+	for i, r := range reply.Rectangles {
+		rects[i] = xrect.New(int(r.X), int(r.Y), int(r.Width), int(r.Height))
+	}
+	return rects, nil
+}
+
+// ShapeListen tells X to report ShapeNotify events for the window, which are
+// sent whenever one of its shapes changes. (See xevent.ShapeNotifyFun.)
+func (w *Window) ShapeListen() error {
+	if err := shapeInit(w.X, ShapeBounding); err != nil {
+		return err
+	}
+	return shape.SelectInputChecked(w.X.Conn(), w.Id, true).Check()
+}
+
+// shapeRectangles sets a shape to the rectangles given. Many rectangles may
+// need to be split up into multiple requests, like image data in xgraphics.
+func (w *Window) shapeRectangles(kind shape.Kind, ordering byte,
+	rects []xproto.Rectangle) error {
+
+	if err := shapeInit(w.X, kind); err != nil {
+		return err
+	}
+
+	// Each rectangle is 8 bytes, after a 16 byte header.
+	perReq := (xgbutil.MaxReqSize - 16) / 8
+	op := shape.Op(shape.SoSet)
+	for start := 0; start == 0 || start < len(rects); start += perReq {
+		end := start + perReq
+		if end > len(rects) {
+			end = len(rects)
+		}
+		err := shape.RectanglesChecked(w.X.Conn(), op, kind, ordering,
+			w.Id, 0, 0, rects[start:end]).Check()
+		if err != nil {
+			return err
+		}
+		op = shape.SoUnion
+	}
+	return nil
+}
+
+// alphaRectangles returns rectangles covering the pixels of the image with
+// an alpha value of at least 128, relative to the top left corner of the
+// image. Rows with the same runs of pixels are merged, so that the
+// rectangles are YX-banded.
+func alphaRectangles(img image.Image) []xproto.Rectangle {
+	b := img.Bounds()
+	var rects, band []xproto.Rectangle
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		// Find the runs of opaque pixels in this row.
+		var runs []xproto.Rectangle
+		for x := b.Min.X; x < b.Max.X; {
+			for x < b.Max.X && !opaque(img, x, y) {
+				x++
+			}
+			start := x
+			for x < b.Max.X && opaque(img, x, y) {
+				x++
+			}
+			if x > start {
+				runs = append(runs, xproto.Rectangle{
+					X:      int16(start - b.Min.X),
+					Y:      int16(y - b.Min.Y),
+					Width:  uint16(x - start),
+					Height: 1,
+				})
+			}
+		}
+
+		// Grow the current band if this row has the same runs.
+		if sameRuns(band, runs) {
+			for i := range band {
+				band[i].Height++
+			}
+			continue
+		}
+		rects = append(rects, band...)
+		band = runs
+	}
+	return append(rects, band...)
+}
+
+// sameRuns returns whether two rows have runs at the same positions.
+func sameRuns(band, runs []xproto.Rectangle) bool {
+	if len(band) == 0 || len(band) != len(runs) {
+		return false
+	}
+	for i := range band {
+		if band[i].X != runs[i].X || band[i].Width != runs[i].Width {
+			return false
+		}
+	}
+	return true
+}
+
+// opaque returns whether the pixel at (x, y) has an alpha value of at least
+// 128.
+func opaque(img image.Image, x, y int) bool {
+	_, _, _, a := img.At(x, y).RGBA()
+	return a >= 0x8000
+}
+
+// shapeInit initializes the SHAPE extension for the connection, if it hasn't
+// been already, and checks that the X server supports the kind of shape
+// given.
+func shapeInit(xu *xgbutil.XUtil, kind shape.Kind) error {
+	shapeVersionsLck.Lock()
+	defer shapeVersionsLck.Unlock()
+
+	version, ok := shapeVersions[xu]
+	if !ok {
+		if err := shape.Init(xu.Conn()); err != nil {
+			return err
+		}
+		reply, err := shape.QueryVersion(xu.Conn()).Reply()
+		if err != nil {
+			return err
+		}
+		version = shapeVersion{reply.MajorVersion, reply.MinorVersion}
+		shapeVersions[xu] = version
+	}
+
+	if kind == ShapeInput && version.major == 1 && version.minor < 1 {
+		return fmt.Errorf("The X server's SHAPE extension (version %d.%d) "+
+			"doesn't support input shapes.", version.major, version.minor)
+	}
+	return nil
+}